@@ -0,0 +1,183 @@
+// Command pv-safe provides operator tooling for the pv-safe webhook.
+//
+//	pv-safe replay [flags] CAPTURE_FILE...
+//
+// replays admission reviews recorded with --capture-file through the webhook handler,
+// against a live cluster or a cluster state snapshot, and reports changed decisions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/automationpi/pv-safe/internal/config"
+	"github.com/automationpi/pv-safe/internal/replay"
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+const usage = `Usage: pv-safe <command> [flags]
+
+Commands:
+  replay    Replay captured admission reviews through the webhook handler
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "replay":
+		os.Exit(runReplay(os.Args[2:], os.Stdout, os.Stderr))
+	case "-h", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runReplay runs the replay command and returns the exit code: 0 when every decision
+// is unchanged, 1 when some decision changed or a request failed, 2 on usage errors
+func runReplay(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	configFile := fs.String("config", "", "Webhook configuration file (YAML) to assess with")
+	kubeconfig := fs.String("kubeconfig", "", "Kubeconfig of the live cluster to assess against")
+	kubeContext := fs.String("context", "", "Kubeconfig context to use")
+	stateFile := fs.String("state", "", "Cluster state snapshot (YAML dump of PVCs, PVs, Namespaces and snapshots) to assess against instead of a live cluster")
+	verbose := fs.Bool("v", false, "Show the webhook handler's log output")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: pv-safe replay [flags] CAPTURE_FILE...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *stateFile != "" && (*kubeconfig != "" || *kubeContext != "") {
+		fmt.Fprintln(stderr, "--state cannot be combined with --kubeconfig or --context")
+		return 2
+	}
+
+	cfg := config.Default()
+	if *configFile != "" {
+		if err := cfg.LoadFile(*configFile); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	var records []webhook.CaptureRecord
+	for _, path := range fs.Args() {
+		fileRecords, err := readCaptureFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		records = append(records, fileRecords...)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	if *verbose {
+		logger = log.New(stderr, "[pv-safe-webhook] ", log.LstdFlags)
+	}
+
+	handler, err := newReplayHandler(cfg, logger, *stateFile, *kubeconfig, *kubeContext, stderr)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	result := replay.Replay(handler, records, stdout)
+	if result.Changed > 0 || result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readCaptureFile reads the records of one capture file
+func readCaptureFile(path string) ([]webhook.CaptureRecord, error) {
+	file, err := os.Open(path) //nolint:gosec // G304: path comes from the command line
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	records, err := webhook.ReadCaptures(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
+
+// newReplayHandler builds a webhook handler configured like the webhook, backed by the
+// state snapshot when one is given and by the live cluster otherwise. Automatic snapshots
+// are never enabled, so replaying has no side effects.
+func newReplayHandler(cfg *config.Config, logger *log.Logger, stateFile, kubeconfig, kubeContext string, stderr io.Writer) (*webhook.Handler, error) {
+	var client kubernetes.Interface
+	var dynamicClient dynamic.Interface
+
+	if stateFile != "" {
+		file, err := os.Open(stateFile) //nolint:gosec // G304: path comes from the command line
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		state, err := replay.LoadState(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stateFile, err)
+		}
+		fmt.Fprintf(stderr, "Loaded state from %s: %s\n", stateFile, state)
+		client, dynamicClient = state.Clients()
+	} else {
+		clientset, restConfig, err := webhook.NewKubernetesClient(kubeconfig, kubeContext)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+		}
+		dynamicClient, err = dynamic.NewForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+		}
+		client = clientset
+	}
+
+	var snapshotChecker *webhook.SnapshotChecker
+	if cfg.Features.Snapshots {
+		snapshotChecker = webhook.NewSnapshotCheckerForClient(dynamicClient, client)
+		snapshotChecker.SetGroupSnapshotsEnabled(cfg.Features.GroupSnapshots)
+	}
+
+	handler := webhook.NewHandler(logger, client, snapshotChecker)
+	if err := cfg.ConfigureHandler(handler); err != nil {
+		return nil, err
+	}
+
+	if len(cfg.BackupProviders.Enabled) > 0 {
+		providers, err := webhook.NewBackupProviders(cfg.BackupProviders.Enabled, dynamicClient,
+			cfg.BackupProviders.VeleroNamespace, cfg.BackupProviders.MaxAge.Duration)
+		if err != nil {
+			return nil, err
+		}
+		for _, provider := range providers {
+			handler.RiskCalculator.AddBackupProvider(provider)
+		}
+	}
+
+	return handler, nil
+}
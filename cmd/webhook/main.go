@@ -1,72 +1,271 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/automationpi/pv-safe/internal/certs"
+	"github.com/automationpi/pv-safe/internal/config"
 	"github.com/automationpi/pv-safe/internal/webhook"
-)
-
-var (
-	port     = flag.String("port", "8443", "Port to listen on")
-	certFile = flag.String("cert-file", "/etc/webhook/certs/tls.crt", "Path to TLS certificate")
-	keyFile  = flag.String("key-file", "/etc/webhook/certs/tls.key", "Path to TLS key")
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
 )
 
 func main() {
-	flag.Parse()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("[pv-safe-webhook] %v", err)
+	}
 
-	logger := log.New(os.Stdout, "[pv-safe-webhook] ", log.LstdFlags|log.Lshortfile)
+	logger, err := webhook.NewLogger(os.Stdout, cfg.LogFormat)
+	if err != nil {
+		log.Fatalf("[pv-safe-webhook] %v", err)
+	}
+
+	// Cancelled on SIGTERM so the server drains before the pod is killed
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
 
 	logger.Println("Starting pv-safe webhook server...")
-	logger.Printf("Listening on port: %s", *port)
-	logger.Printf("TLS cert: %s", *certFile)
-	logger.Printf("TLS key: %s", *keyFile)
+	logger.Printf("Listening on port: %s", cfg.Port)
+	if !cfg.InsecureHTTP {
+		logger.Printf("TLS cert: %s", cfg.CertFile)
+		logger.Printf("TLS key: %s", cfg.KeyFile)
+	}
 
 	logger.Println("Initializing Kubernetes client...")
-	client, config, err := webhook.NewKubernetesClient()
+	if cfg.Kubeconfig != "" || cfg.KubeContext != "" {
+		logger.Printf("Using kubeconfig %q, context %q", cfg.Kubeconfig, cfg.KubeContext)
+	}
+	client, restConfig, err := webhook.NewKubernetesClient(cfg.Kubeconfig, cfg.KubeContext)
 	if err != nil {
 		logger.Fatalf("Failed to create Kubernetes client: %v", err)
 	}
 	logger.Println("Kubernetes client initialized successfully")
 
-	logger.Println("Initializing Snapshot checker...")
-	snapshotChecker, err := webhook.NewSnapshotChecker(config, client)
-	if err != nil {
-		logger.Printf("Warning: Failed to create Snapshot checker: %v", err)
-		logger.Println("Snapshot support will be disabled")
-		snapshotChecker = nil
+	var snapshotChecker *webhook.SnapshotChecker
+	if cfg.Features.Snapshots {
+		logger.Println("Initializing Snapshot checker...")
+		snapshotChecker, err = webhook.NewSnapshotChecker(restConfig, client)
+		if err != nil {
+			logger.Printf("Warning: Failed to create Snapshot checker: %v", err)
+			logger.Println("Snapshot support will be disabled")
+			snapshotChecker = nil
+		} else {
+			snapshotChecker.SetGroupSnapshotsEnabled(cfg.Features.GroupSnapshots)
+			logger.Println("Snapshot checker initialized successfully")
+		}
 	} else {
-		logger.Println("Snapshot checker initialized successfully")
+		logger.Println("Snapshot support disabled by configuration")
 	}
 
 	handler := webhook.NewHandler(logger, client, snapshotChecker)
+	if err := cfg.ConfigureHandler(handler); err != nil {
+		logger.Fatalf("Invalid configuration: %v", err)
+	}
+
+	logger.Printf("Assessment timeout: %s", cfg.AssessmentTimeout)
+	logger.Printf("Failure mode: %s", cfg.FailureMode)
+	logger.Printf("Unassessable PVC policy: %s", cfg.UnknownPVCPolicy)
+	logger.Printf("Bypass label: %s", cfg.BypassLabel)
+	if cfg.MessageTemplatesFile != "" {
+		logger.Printf("Message templates: %s", cfg.MessageTemplatesFile)
+	}
+	if len(cfg.ExcludedNamespaces) > 0 {
+		logger.Printf("Excluded namespaces: %v", cfg.ExcludedNamespaces)
+	}
+	if len(cfg.ContactKeys) > 0 {
+		logger.Printf("Contact keys: %v", cfg.ContactKeys)
+	}
+	logger.Printf("Deletion lock admins: users %v, groups %v", cfg.DeletionLock.AdminUsers, cfg.DeletionLock.AdminGroups)
+	if windows := cfg.ChangeWindows; len(windows.MaintenanceWindows) > 0 || len(windows.Freezes) > 0 {
+		logger.Printf("Change windows: %d maintenance windows, %d freezes, scope %s, time zone %s",
+			len(windows.MaintenanceWindows), len(windows.Freezes), windows.Scope, windows.TimeZone)
+	}
+
+	if len(cfg.BackupProviders.Enabled) > 0 {
+		logger.Println("Initializing backup providers...")
+		dynamicClient, err := dynamic.NewForConfig(restConfig)
+		if err != nil {
+			logger.Fatalf("Failed to create dynamic client: %v", err)
+		}
+		providers, err := webhook.NewBackupProviders(cfg.BackupProviders.Enabled, dynamicClient,
+			cfg.BackupProviders.VeleroNamespace, cfg.BackupProviders.MaxAge.Duration)
+		if err != nil {
+			logger.Fatalf("Failed to create backup providers: %v", err)
+		}
+		for _, provider := range providers {
+			handler.RiskCalculator.AddBackupProvider(provider)
+			logger.Printf("Backup provider enabled: %s", provider.Name())
+		}
+	}
+
+	if cfg.AutoSnapshot.Enabled {
+		logger.Println("Initializing automatic snapshots...")
+		autoSnapshotter, err := webhook.NewAutoSnapshotter(snapshotChecker, cfg.AutoSnapshot.VolumeSnapshotClassName)
+		if err != nil {
+			logger.Fatalf("Failed to enable automatic snapshots: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err = autoSnapshotter.Validate(ctx)
+		cancel()
+		if err != nil {
+			logger.Fatalf("Invalid automatic snapshot configuration: %v", err)
+		}
+		handler.AutoSnapshotter = autoSnapshotter
+		logger.Printf("Automatic snapshots enabled using VolumeSnapshotClass %s", cfg.AutoSnapshot.VolumeSnapshotClassName)
+	}
+
+	if cfg.CircuitBreaker.Enabled {
+		namespace := os.Getenv("POD_NAMESPACE")
+		if namespace == "" {
+			logger.Fatalf("POD_NAMESPACE must be set when the circuit breaker is enabled")
+		}
+		identity := os.Getenv("POD_NAME")
+		if identity == "" {
+			identity, _ = os.Hostname()
+		}
+		breaker := webhook.NewCircuitBreaker(client, logger, webhook.CircuitBreakerOptions{
+			Window:        cfg.CircuitBreaker.Window.Duration,
+			UserLimit:     cfg.CircuitBreaker.UserLimit,
+			ClusterLimit:  cfg.CircuitBreaker.ClusterLimit,
+			Namespace:     namespace,
+			ConfigMapName: cfg.CircuitBreaker.ConfigMapName,
+			Identity:      identity,
+		})
+		handler.CircuitBreaker = breaker
+		// Reported by /readyz?verbose; a tripped breaker must not take the webhook out of service
+		handler.AddReadinessCheck(webhook.ReadinessCheck{
+			Name:     "circuit-breaker",
+			Optional: true,
+			Check: func(ctx context.Context) error {
+				if trip := breaker.Tripped(ctx); trip != nil {
+					return fmt.Errorf("tripped: %s", trip)
+				}
+				return nil
+			},
+		})
+		logger.Printf("Circuit breaker enabled: %d deletions per user, %d in total within %s (ConfigMap %s/%s)",
+			cfg.CircuitBreaker.UserLimit, cfg.CircuitBreaker.ClusterLimit, cfg.CircuitBreaker.Window, namespace, cfg.CircuitBreaker.ConfigMapName)
+	}
+
+	if cfg.Capture.File != "" {
+		recorder, err := webhook.NewRecorder(cfg.Capture.File, int64(cfg.Capture.MaxSizeMB)<<20, cfg.Capture.MaxFiles, logger)
+		if err != nil {
+			logger.Fatalf("Failed to enable admission capture: %v", err)
+		}
+		handler.Recorder = recorder
+		logger.Printf("Capturing redacted admission reviews to %s", cfg.Capture.File)
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/validate", handler)
 	mux.HandleFunc("/healthz", handler.HealthCheck)
-	mux.HandleFunc("/readyz", handler.HealthCheck)
+	mux.HandleFunc("/readyz", handler.ReadyCheck)
+	mux.Handle("/metrics", promhttp.Handler())
 
 	server := &http.Server{
-		Addr:              ":" + *port,
+		Addr:              ":" + cfg.Port,
 		Handler:           mux,
 		ReadHeaderTimeout: 10 * time.Second,
-		TLSConfig: &tls.Config{
-			MinVersion: tls.VersionTLS12,
-		},
+	}
+	serve := server.ListenAndServe
+	scheme := "http"
+	if cfg.InsecureHTTP {
+		logger.Println("WARNING: serving plain HTTP (--insecure-http); use only for local development")
+	} else {
+		server.TLSConfig = newTLSConfig(ctx, logger, client, handler, cfg)
+		// The certificate comes from TLSConfig.GetCertificate so it can be reloaded
+		serve = func() error { return server.ListenAndServeTLS("", "") }
+		scheme = "https"
 	}
 
-	logger.Printf("Webhook server listening on https://0.0.0.0:%s", *port)
+	logger.Printf("Webhook server listening on %s://0.0.0.0:%s", scheme, cfg.Port)
 	logger.Println("Endpoints:")
 	logger.Println("  - POST /validate (admission webhook)")
 	logger.Println("  - GET  /healthz  (health check)")
-	logger.Println("  - GET  /readyz   (readiness check)")
+	logger.Println("  - GET  /readyz   (readiness check, ?verbose for details)")
+	logger.Println("  - GET  /metrics  (Prometheus metrics)")
+
+	if err := webhook.RunServer(ctx, server, serve, handler, cfg.ShutdownDrainPeriod.Duration, cfg.ShutdownTimeout.Duration); err != nil {
+		logger.Fatalf("Server failed: %v", err)
+	}
+	if handler.Recorder != nil {
+		if err := handler.Recorder.Close(); err != nil {
+			logger.Printf("Warning: Failed to close capture file: %v", err)
+		}
+	}
+	stop()
+}
+
+// newTLSConfig loads the serving certificate, optionally generating it first, and
+// reloads it whenever the files change
+func newTLSConfig(ctx context.Context, logger *log.Logger, client kubernetes.Interface, handler *webhook.Handler, cfg *config.Config) *tls.Config {
+	if cfg.SelfManagedCerts.Enabled {
+		startCertManager(ctx, logger, client, cfg)
+	}
+
+	certWatcher, err := certs.NewWatcher(cfg.CertFile, cfg.KeyFile, logger)
+	if err != nil {
+		logger.Fatalf("Failed to load TLS certificate: %v", err)
+	}
+	go func() {
+		if err := certWatcher.Start(ctx); err != nil {
+			logger.Printf("Warning: TLS certificate hot reload disabled: %v", err)
+		}
+	}()
+	handler.AddReadinessCheck(webhook.ReadinessCheck{
+		Name: "tls-certificate",
+		Check: func(context.Context) error {
+			return certWatcher.CheckExpiry(cfg.CertExpiryThreshold.Duration)
+		},
+	})
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certWatcher.GetCertificate,
+	}
+}
+
+// startCertManager starts generating and rotating self-managed certificates and waits
+// until the serving keypair has been written to the configured files
+func startCertManager(ctx context.Context, logger *log.Logger, client kubernetes.Interface, cfg *config.Config) {
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		logger.Fatalf("POD_NAMESPACE must be set when self-managed certificates are enabled")
+	}
+	identity := os.Getenv("POD_NAME")
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+
+	manager := certs.NewManager(client, logger, certs.ManagerOptions{
+		Namespace:         namespace,
+		SecretName:        cfg.SelfManagedCerts.SecretName,
+		WebhookConfigName: cfg.SelfManagedCerts.WebhookConfigName,
+		LeaseName:         cfg.SelfManagedCerts.LeaseName,
+		Identity:          identity,
+		DNSNames:          cfg.SelfManagedCerts.DNSNames,
+		CertFile:          cfg.CertFile,
+		KeyFile:           cfg.KeyFile,
+		CertValidity:      cfg.SelfManagedCerts.Validity.Duration,
+		RenewBefore:       cfg.SelfManagedCerts.RenewBefore.Duration,
+	})
+	go manager.Run(ctx, time.Minute)
 
-	if err := server.ListenAndServeTLS(*certFile, *keyFile); err != nil {
-		logger.Fatalf("Failed to start server: %v", err)
+	logger.Printf("Waiting for self-managed certificate in Secret %s/%s...", namespace, cfg.SelfManagedCerts.SecretName)
+	waitCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	if err := manager.WaitForCertificate(waitCtx, 2*time.Second); err != nil {
+		logger.Fatalf("Failed to obtain self-managed certificate: %v", err)
 	}
+	logger.Println("Self-managed certificate ready")
 }
@@ -0,0 +1,403 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// DefaultCAValidity is the lifetime of a generated CA
+	DefaultCAValidity = 10 * 365 * 24 * time.Hour
+
+	// Secret keys written by the Manager. ca.crt holds the CA bundle: the current CA
+	// first, followed by previous CAs that are still valid.
+	caCertKey  = "ca.crt"
+	caKeyKey   = "ca.key"
+	tlsCertKey = corev1.TLSCertKey
+	tlsKeyKey  = corev1.TLSPrivateKeyKey
+
+	// Leader election timings for the rotation Lease
+	leaseDuration = 30 * time.Second
+	renewDeadline = 15 * time.Second
+	retryPeriod   = 5 * time.Second
+)
+
+// ManagerOptions configures a self-managed certificate Manager
+type ManagerOptions struct {
+	// Namespace holds the Secret and the Lease
+	Namespace string
+	// SecretName is the Secret storing the CA and serving keypairs
+	SecretName string
+	// WebhookConfigName is the ValidatingWebhookConfiguration whose caBundle is kept up to date
+	WebhookConfigName string
+	// LeaseName is the Lease used to elect the replica that creates and rotates certificates
+	LeaseName string
+	// Identity identifies this replica in the Lease
+	Identity string
+	// DNSNames are the names of the webhook Service the serving certificate is valid for
+	DNSNames []string
+	// CertFile and KeyFile are where the serving keypair is written for the Watcher
+	CertFile string
+	KeyFile  string
+	// CertValidity is the lifetime of a serving certificate
+	CertValidity time.Duration
+	// RenewBefore is how long before expiry the CA and serving certificate are replaced
+	RenewBefore time.Duration
+}
+
+// Manager generates the webhook CA and serving certificate into a Secret, keeps the
+// caBundle of the webhook configuration in sync and rotates both before they expire.
+// Only the replica holding the Lease writes; every replica copies the serving keypair
+// from the Secret to disk.
+type Manager struct {
+	client  kubernetes.Interface
+	logger  *log.Logger
+	options ManagerOptions
+
+	// CAValidity is the lifetime of a generated CA
+	CAValidity time.Duration
+
+	// now is the clock used for generation and expiry checks; replaced in tests
+	now func() time.Time
+}
+
+// NewManager creates a certificate Manager
+func NewManager(client kubernetes.Interface, logger *log.Logger, options ManagerOptions) *Manager {
+	return &Manager{
+		client:     client,
+		logger:     logger,
+		options:    options,
+		CAValidity: DefaultCAValidity,
+		now:        time.Now,
+	}
+}
+
+// Reconcile creates or rotates the certificates in the Secret and updates the caBundle.
+// It must only run on the replica holding the Lease.
+func (m *Manager) Reconcile(ctx context.Context) error {
+	secrets := m.client.CoreV1().Secrets(m.options.Namespace)
+
+	secret, err := secrets.Get(ctx, m.options.SecretName, metav1.GetOptions{})
+	exists := err == nil
+	switch {
+	case apierrors.IsNotFound(err):
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      m.options.SecretName,
+				Namespace: m.options.Namespace,
+				Labels:    map[string]string{"app.kubernetes.io/managed-by": "pv-safe"},
+			},
+			Type: corev1.SecretTypeTLS,
+		}
+	case err != nil:
+		return fmt.Errorf("failed to get Secret %s: %w", m.options.SecretName, err)
+	}
+
+	data, changed, err := m.rotate(secret.Data)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		secret.Data = data
+		if exists {
+			_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+		} else {
+			_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write Secret %s: %w", m.options.SecretName, err)
+		}
+		m.logger.Printf("Stored new webhook certificates in Secret %s/%s", m.options.Namespace, m.options.SecretName)
+	}
+
+	return m.updateCABundle(ctx, data[caCertKey])
+}
+
+// rotate returns the Secret data with any missing, invalid or expiring certificate replaced
+func (m *Manager) rotate(current map[string][]byte) (map[string][]byte, bool, error) {
+	now := m.now()
+	renewAt := now.Add(m.options.RenewBefore)
+
+	ca := &KeyPair{CertPEM: firstCertificatePEM(current[caCertKey]), KeyPEM: current[caKeyKey]}
+	caCert, _, err := ca.parse()
+	rotateCA := err != nil || caCert.NotAfter.Before(renewAt)
+
+	bundle := current[caCertKey]
+	if rotateCA {
+		ca, err = GenerateCA("pv-safe-webhook-ca", now, m.CAValidity)
+		if err != nil {
+			return nil, false, err
+		}
+		// Keep trusting the previous CA so replicas still serving its certificate keep working
+		bundle = append(append([]byte{}, ca.CertPEM...), current[caCertKey]...)
+		if caCert != nil {
+			m.logger.Printf("Rotating webhook CA (previous CA expires %s)", caCert.NotAfter.UTC().Format(time.RFC3339))
+		} else {
+			m.logger.Println("Generating webhook CA")
+		}
+	}
+	bundle = pruneExpired(bundle, now)
+
+	serving := &KeyPair{CertPEM: current[tlsCertKey], KeyPEM: current[tlsKeyKey]}
+	if rotateCA || m.needsServingRotation(serving, ca, renewAt) {
+		serving, err = GenerateServing(ca, m.options.DNSNames, now, m.options.CertValidity)
+		if err != nil {
+			return nil, false, err
+		}
+		m.logger.Printf("Generated webhook serving certificate for %v", m.options.DNSNames)
+	}
+
+	data := map[string][]byte{
+		caCertKey:  bundle,
+		caKeyKey:   ca.KeyPEM,
+		tlsCertKey: serving.CertPEM,
+		tlsKeyKey:  serving.KeyPEM,
+	}
+
+	changed := false
+	for key, value := range data {
+		if !bytes.Equal(current[key], value) {
+			changed = true
+		}
+	}
+
+	return data, changed, nil
+}
+
+// needsServingRotation reports whether the serving certificate must be replaced
+func (m *Manager) needsServingRotation(serving, ca *KeyPair, renewAt time.Time) bool {
+	cert, _, err := serving.parse()
+	if err != nil {
+		return true
+	}
+	if cert.NotAfter.Before(renewAt) {
+		return true
+	}
+	if !slices.Equal(cert.DNSNames, m.options.DNSNames) {
+		return true
+	}
+
+	caCert, err := parseCertificate(ca.CertPEM)
+	if err != nil {
+		return true
+	}
+	return cert.CheckSignatureFrom(caCert) != nil
+}
+
+// updateCABundle sets caBundle on every webhook of the ValidatingWebhookConfiguration
+func (m *Manager) updateCABundle(ctx context.Context, bundle []byte) error {
+	configs := m.client.AdmissionregistrationV1().ValidatingWebhookConfigurations()
+
+	config, err := configs.Get(ctx, m.options.WebhookConfigName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get ValidatingWebhookConfiguration %s: %w", m.options.WebhookConfigName, err)
+	}
+
+	changed := false
+	for i := range config.Webhooks {
+		if !bytes.Equal(config.Webhooks[i].ClientConfig.CABundle, bundle) {
+			config.Webhooks[i].ClientConfig.CABundle = bundle
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	if _, err := configs.Update(ctx, config, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update caBundle of %s: %w", m.options.WebhookConfigName, err)
+	}
+	m.logger.Printf("Updated caBundle of ValidatingWebhookConfiguration %s", m.options.WebhookConfigName)
+
+	return nil
+}
+
+// Sync copies the serving keypair from the Secret to CertFile and KeyFile. It reports
+// false without error while the Secret has not been created yet.
+func (m *Manager) Sync(ctx context.Context) (bool, error) {
+	secret, err := m.client.CoreV1().Secrets(m.options.Namespace).Get(ctx, m.options.SecretName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get Secret %s: %w", m.options.SecretName, err)
+	}
+
+	certPEM, keyPEM := secret.Data[tlsCertKey], secret.Data[tlsKeyKey]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return false, nil
+	}
+
+	// Write the key first: the Watcher only reloads when the certificate changes,
+	// so it never pairs a new certificate with the old key
+	if err := writeFileIfChanged(m.options.KeyFile, keyPEM); err != nil {
+		return false, err
+	}
+	if err := writeFileIfChanged(m.options.CertFile, certPEM); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// WaitForCertificate syncs the serving keypair until the Secret provides one
+func (m *Manager) WaitForCertificate(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		ok, err := m.Sync(ctx)
+		if err != nil {
+			m.logger.Printf("Waiting for webhook certificate: %v", err)
+		}
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for Secret %s/%s: %w", m.options.Namespace, m.options.SecretName, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run reconciles the certificates while this replica holds the Lease and syncs the
+// serving keypair to disk on every replica, each interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	go m.runLeaderElection(ctx, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Sync(ctx); err != nil {
+				m.logger.Printf("Failed to sync webhook certificate: %v", err)
+			}
+		}
+	}
+}
+
+// runLeaderElection campaigns for the Lease and reconciles while leading. Leadership
+// is requested again after it is lost.
+func (m *Manager) runLeaderElection(ctx context.Context, interval time.Duration) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: m.options.LeaseName, Namespace: m.options.Namespace},
+		Client:     m.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: m.options.Identity},
+	}
+
+	for ctx.Err() == nil {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   leaseDuration,
+			RenewDeadline:   renewDeadline,
+			RetryPeriod:     retryPeriod,
+			ReleaseOnCancel: true,
+			Name:            m.options.LeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(ctx context.Context) {
+					m.logger.Printf("Acquired certificate Lease %s/%s", m.options.Namespace, m.options.LeaseName)
+					m.reconcileLoop(ctx, interval)
+				},
+				OnStoppedLeading: func() {
+					m.logger.Printf("Released certificate Lease %s/%s", m.options.Namespace, m.options.LeaseName)
+				},
+			},
+		})
+	}
+}
+
+// reconcileLoop calls Reconcile immediately and then every interval until ctx is cancelled
+func (m *Manager) reconcileLoop(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Reconcile(ctx); err != nil {
+			m.logger.Printf("Failed to reconcile webhook certificates: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// firstCertificatePEM returns the first PEM block of a bundle
+func firstCertificatePEM(bundle []byte) []byte {
+	block, _ := pem.Decode(bundle)
+	if block == nil {
+		return nil
+	}
+	return pem.EncodeToMemory(block)
+}
+
+// pruneExpired drops certificates that have expired, and anything that is not a
+// certificate, from a PEM bundle
+func pruneExpired(bundle []byte, now time.Time) []byte {
+	var out []byte
+	for rest := bundle; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return out
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil || now.After(cert.NotAfter) {
+			continue
+		}
+		out = append(out, pem.EncodeToMemory(block)...)
+	}
+}
+
+// writeFileIfChanged atomically replaces path with data unless it already has that content
+func writeFileIfChanged(path string, data []byte) error {
+	existing, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var managerTestNow = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var managerTestDNSNames = []string{"pv-safe-webhook.pv-safe-system.svc"}
+
+func newTestManager(t *testing.T) (*Manager, *fake.Clientset, *time.Time) {
+	t.Helper()
+
+	client := fake.NewClientset(&admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-safe-validating-webhook"},
+		Webhooks:   []admissionregistrationv1.ValidatingWebhook{{Name: "validate.pv-safe.io"}},
+	})
+
+	dir := t.TempDir()
+	now := managerTestNow
+	manager := NewManager(client, log.New(io.Discard, "", 0), ManagerOptions{
+		Namespace:         "pv-safe-system",
+		SecretName:        "pv-safe-webhook-cert",
+		WebhookConfigName: "pv-safe-validating-webhook",
+		LeaseName:         "pv-safe-webhook-cert",
+		Identity:          "replica-0",
+		DNSNames:          managerTestDNSNames,
+		CertFile:          filepath.Join(dir, "tls.crt"),
+		KeyFile:           filepath.Join(dir, "tls.key"),
+		CertValidity:      365 * 24 * time.Hour,
+		RenewBefore:       30 * 24 * time.Hour,
+	})
+	manager.CAValidity = 3 * 365 * 24 * time.Hour
+	manager.now = func() time.Time { return now }
+
+	return manager, client, &now
+}
+
+// storedCertificates returns the serving certificate and the caBundle pool
+func storedCertificates(t *testing.T, client *fake.Clientset) (*x509.Certificate, *x509.CertPool, []byte) {
+	t.Helper()
+
+	secret, err := client.CoreV1().Secrets("pv-safe-system").Get(context.Background(), "pv-safe-webhook-cert", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get Secret: %v", err)
+	}
+	serving, err := parseCertificate(secret.Data[tlsCertKey])
+	if err != nil {
+		t.Fatalf("invalid serving certificate: %v", err)
+	}
+
+	config, err := client.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), "pv-safe-validating-webhook", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get webhook configuration: %v", err)
+	}
+	bundle := config.Webhooks[0].ClientConfig.CABundle
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		t.Fatalf("caBundle contains no certificates: %q", bundle)
+	}
+
+	return serving, pool, bundle
+}
+
+func countCertificates(bundle []byte) int {
+	count := 0
+	for rest := bundle; ; count++ {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			return count
+		}
+	}
+}
+
+func verifyServing(t *testing.T, serving *x509.Certificate, pool *x509.CertPool, at time.Time) {
+	t.Helper()
+
+	_, err := serving.Verify(x509.VerifyOptions{DNSName: managerTestDNSNames[0], Roots: pool, CurrentTime: at})
+	if err != nil {
+		t.Fatalf("serving certificate does not verify against caBundle: %v", err)
+	}
+}
+
+func TestManagerReconcileCreatesCertificates(t *testing.T) {
+	manager, client, _ := newTestManager(t)
+	ctx := context.Background()
+
+	if ok, err := manager.Sync(ctx); ok || err != nil {
+		t.Fatalf("Sync() before Reconcile = %v, %v, want false, nil", ok, err)
+	}
+
+	if err := manager.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	serving, pool, _ := storedCertificates(t, client)
+	verifyServing(t, serving, pool, managerTestNow)
+
+	if ok, err := manager.Sync(ctx); !ok || err != nil {
+		t.Fatalf("Sync() = %v, %v, want true, nil", ok, err)
+	}
+	watcher, err := NewWatcher(manager.options.CertFile, manager.options.KeyFile, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("synced files are not a valid keypair: %v", err)
+	}
+	if !watcher.NotAfter().Equal(serving.NotAfter) {
+		t.Errorf("synced certificate expires %s, want %s", watcher.NotAfter(), serving.NotAfter)
+	}
+
+	// A second replica reconciling the same Secret must not replace anything
+	if err := manager.Reconcile(ctx); err != nil {
+		t.Fatalf("second Reconcile() error = %v", err)
+	}
+	again, _, _ := storedCertificates(t, client)
+	if again.SerialNumber.Cmp(serving.SerialNumber) != 0 {
+		t.Error("second Reconcile() replaced a valid serving certificate")
+	}
+}
+
+func TestManagerRotatesServingCertificateBeforeExpiry(t *testing.T) {
+	manager, client, now := newTestManager(t)
+	ctx := context.Background()
+
+	if err := manager.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	original, _, originalBundle := storedCertificates(t, client)
+
+	*now = original.NotAfter.Add(-7 * 24 * time.Hour)
+	if err := manager.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	rotated, pool, bundle := storedCertificates(t, client)
+	if rotated.SerialNumber.Cmp(original.SerialNumber) == 0 {
+		t.Fatal("serving certificate was not rotated within renewBefore of its expiry")
+	}
+	if string(bundle) != string(originalBundle) {
+		t.Error("caBundle changed although the CA is still valid")
+	}
+	verifyServing(t, rotated, pool, *now)
+}
+
+func TestManagerRotatesCAAndKeepsPreviousTrusted(t *testing.T) {
+	manager, client, now := newTestManager(t)
+	ctx := context.Background()
+
+	if err := manager.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	// Within renewBefore of the CA expiry
+	*now = managerTestNow.Add(manager.CAValidity - 10*24*time.Hour)
+	if err := manager.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	secret, err := client.CoreV1().Secrets("pv-safe-system").Get(ctx, "pv-safe-webhook-cert", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get Secret: %v", err)
+	}
+	caCert, err := parseCertificate(secret.Data[caCertKey])
+	if err != nil {
+		t.Fatalf("invalid CA: %v", err)
+	}
+	if !caCert.NotAfter.Equal(now.Add(manager.CAValidity)) {
+		t.Fatalf("CA expires %s, want a new CA expiring %s", caCert.NotAfter, now.Add(manager.CAValidity))
+	}
+
+	serving, pool, bundle := storedCertificates(t, client)
+	verifyServing(t, serving, pool, *now)
+
+	if got := countCertificates(bundle); got != 2 {
+		t.Errorf("caBundle has %d certificates during rotation, want the new and the previous CA", got)
+	}
+
+	// Once the previous CA has expired it is dropped from the bundle
+	*now = managerTestNow.Add(manager.CAValidity + time.Hour)
+	if err := manager.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	_, _, bundle = storedCertificates(t, client)
+	if got := countCertificates(bundle); got != 1 {
+		t.Errorf("caBundle has %d certificates after the previous CA expired, want 1", got)
+	}
+}
+
+func TestWriteFileIfChangedLeavesNoTemporaryFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tls.crt")
+
+	for _, content := range []string{"first", "first", "second"} {
+		if err := writeFileIfChanged(path, []byte(content)); err != nil {
+			t.Fatalf("writeFileIfChanged() error = %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want only tls.crt", len(entries))
+	}
+	if data, _ := os.ReadFile(path); string(data) != "second" {
+		t.Errorf("file content = %q, want %q", data, "second")
+	}
+}
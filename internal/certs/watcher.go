@@ -0,0 +1,189 @@
+// Package certs manages the TLS serving certificate of the pv-safe webhook.
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// DefaultPollInterval is how often the watcher re-reads the files in case a
+// file system event was missed
+const DefaultPollInterval = time.Minute
+
+// certificateExpiry exposes the expiry of the certificate currently served
+var certificateExpiry = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "pv_safe_certificate_expiry_timestamp_seconds",
+	Help: "Expiry time of the TLS certificate currently served by the webhook, in Unix seconds.",
+})
+
+// Watcher serves a TLS keypair from disk and reloads it when the files change.
+// Kubernetes updates mounted Secrets by swapping a symlink in the mount directory,
+// so the watcher observes the directories holding the files rather than the files.
+type Watcher struct {
+	certFile string
+	keyFile  string
+	logger   *log.Logger
+
+	// PollInterval is the fallback reload interval; zero disables polling
+	PollInterval time.Duration
+
+	mu       sync.RWMutex
+	current  *tls.Certificate
+	certPEM  []byte
+	notAfter time.Time
+
+	// now is the clock used for expiry checks; replaced in tests
+	now func() time.Time
+}
+
+// NewWatcher creates a Watcher and loads the initial keypair
+func NewWatcher(certFile, keyFile string, logger *log.Logger) (*Watcher, error) {
+	w := &Watcher{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		logger:       logger,
+		PollInterval: DefaultPollInterval,
+		now:          time.Now,
+	}
+
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// GetCertificate returns the current keypair; it is meant for tls.Config.GetCertificate
+func (w *Watcher) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current, nil
+}
+
+// NotAfter returns the expiry time of the current certificate
+func (w *Watcher) NotAfter() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.notAfter
+}
+
+// Reload reads the keypair from disk and swaps it in if the certificate changed.
+// On error the previous keypair keeps being served.
+func (w *Watcher) Reload() error {
+	certPEM, err := os.ReadFile(w.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate %s: %w", w.certFile, err)
+	}
+	keyPEM, err := os.ReadFile(w.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key %s: %w", w.keyFile, err)
+	}
+
+	w.mu.RLock()
+	unchanged := w.current != nil && bytes.Equal(certPEM, w.certPEM)
+	w.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("failed to load keypair: %w", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+	cert.Leaf = leaf
+
+	w.mu.Lock()
+	w.current = &cert
+	w.certPEM = certPEM
+	w.notAfter = leaf.NotAfter
+	w.mu.Unlock()
+
+	certificateExpiry.Set(float64(leaf.NotAfter.Unix()))
+	w.logger.Printf("Loaded TLS certificate (serial %s, expires %s, in %s)",
+		leaf.SerialNumber, leaf.NotAfter.UTC().Format(time.RFC3339), leaf.NotAfter.Sub(w.now()).Round(time.Minute))
+
+	return nil
+}
+
+// Start watches the certificate and key directories and reloads the keypair on
+// changes until ctx is cancelled
+func (w *Watcher) Start(ctx context.Context) error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer fsWatcher.Close()
+
+	dirs := map[string]bool{filepath.Dir(w.certFile): true, filepath.Dir(w.keyFile): true}
+	for dir := range dirs {
+		if err := fsWatcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+	}
+
+	var poll <-chan time.Time
+	if w.PollInterval > 0 {
+		ticker := time.NewTicker(w.PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-fsWatcher.Events:
+			if !ok {
+				return nil
+			}
+			w.reloadAndLog()
+		case err, ok := <-fsWatcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.logger.Printf("Certificate watcher error: %v", err)
+		case <-poll:
+			w.reloadAndLog()
+		}
+	}
+}
+
+// reloadAndLog reloads the keypair, logging failures instead of returning them
+func (w *Watcher) reloadAndLog() {
+	if err := w.Reload(); err != nil {
+		w.logger.Printf("Failed to reload TLS certificate, keeping the current one: %v", err)
+	}
+}
+
+// CheckExpiry returns an error if the served certificate expires within minValidity.
+// A zero minValidity disables the check.
+func (w *Watcher) CheckExpiry(minValidity time.Duration) error {
+	if minValidity <= 0 {
+		return nil
+	}
+
+	notAfter := w.NotAfter()
+	remaining := notAfter.Sub(w.now())
+	if remaining < minValidity {
+		return fmt.Errorf("TLS certificate expires at %s (in %s), less than %s away",
+			notAfter.UTC().Format(time.RFC3339), remaining.Round(time.Second), minValidity)
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestKeypair writes a self-signed keypair with the given serial and expiry.
+// Files are written to a temporary name and renamed, like a Secret volume update.
+func writeTestKeypair(t *testing.T, dir string, serial int64, notAfter time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "pv-safe-webhook"},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	files := map[string][]byte{
+		"tls.crt": pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"tls.key": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+	for name, data := range files {
+		tmp := filepath.Join(dir, "."+name+".tmp")
+		if err := os.WriteFile(tmp, data, 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+			t.Fatalf("failed to rename %s: %v", name, err)
+		}
+	}
+}
+
+func newTestWatcher(t *testing.T, dir string) *Watcher {
+	t.Helper()
+
+	w, err := NewWatcher(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("NewWatcher() error = %v", err)
+	}
+	return w
+}
+
+func servedSerial(t *testing.T, w *Watcher) int64 {
+	t.Helper()
+
+	cert, err := w.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate() error = %v", err)
+	}
+	return cert.Leaf.SerialNumber.Int64()
+}
+
+func TestWatcherReloadsRotatedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	expiry := time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second)
+	writeTestKeypair(t, dir, 1, expiry)
+
+	w := newTestWatcher(t, dir)
+	w.PollInterval = 50 * time.Millisecond
+	if got := servedSerial(t, w); got != 1 {
+		t.Fatalf("initial serial = %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- w.Start(ctx) }()
+
+	rotatedExpiry := expiry.Add(30 * 24 * time.Hour)
+	writeTestKeypair(t, dir, 2, rotatedExpiry)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedSerial(t, w) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("rotated certificate was not loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !w.NotAfter().Equal(rotatedExpiry) {
+		t.Errorf("NotAfter() = %s, want %s", w.NotAfter(), rotatedExpiry)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Start() error = %v", err)
+	}
+}
+
+func TestWatcherKeepsCertificateOnInvalidUpdate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestKeypair(t, dir, 1, time.Now().Add(24*time.Hour))
+	w := newTestWatcher(t, dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "tls.crt"), []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to corrupt certificate: %v", err)
+	}
+
+	if err := w.Reload(); err == nil {
+		t.Fatal("Reload() with invalid certificate succeeded, want error")
+	}
+	if got := servedSerial(t, w); got != 1 {
+		t.Errorf("served serial = %d after failed reload, want 1", got)
+	}
+}
+
+func TestWatcherCheckExpiry(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	writeTestKeypair(t, dir, 1, now.Add(48*time.Hour))
+	w := newTestWatcher(t, dir)
+	w.now = func() time.Time { return now }
+
+	tests := []struct {
+		name        string
+		minValidity time.Duration
+		wantErr     bool
+	}{
+		{name: "disabled", minValidity: 0},
+		{name: "enough validity left", minValidity: 24 * time.Hour},
+		{name: "near expiry", minValidity: 72 * time.Hour, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := w.CheckExpiry(tt.minValidity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckExpiry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "expires at 2026-03-03T00:00:00Z") {
+				t.Errorf("CheckExpiry() error = %q, want the expiry time", err)
+			}
+		})
+	}
+}
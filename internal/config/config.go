@@ -0,0 +1,622 @@
+// Package config defines the pv-safe webhook configuration.
+// Settings are read from an optional YAML file (typically mounted from a ConfigMap)
+// and can be overridden by command-line flags. The result is validated at startup.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+const (
+	// LogFormatText writes human-readable log lines
+	LogFormatText = "text"
+	// LogFormatJSON writes one JSON object per log line
+	LogFormatJSON = "json"
+
+	// FailureModeOpen allows deletions when risk assessment fails
+	FailureModeOpen = "open"
+	// FailureModeClosed denies deletions when risk assessment fails
+	FailureModeClosed = "closed"
+
+	// maxAssessmentTimeout is the maximum admission webhook timeout allowed by the API server
+	maxAssessmentTimeout = 30 * time.Second
+)
+
+// Config holds all webhook settings
+type Config struct {
+	Port     string `json:"port"`
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// InsecureHTTP serves plain HTTP without certificates, for local development only
+	InsecureHTTP bool `json:"insecureHTTP"`
+
+	// Kubeconfig and KubeContext select the cluster when running outside a pod;
+	// both empty means in-cluster configuration
+	Kubeconfig  string `json:"kubeconfig"`
+	KubeContext string `json:"kubeContext"`
+	// CertExpiryThreshold fails readiness when the served certificate expires sooner
+	CertExpiryThreshold Duration `json:"certExpiryThreshold"`
+	// ShutdownDrainPeriod keeps serving after SIGTERM while readiness fails, and
+	// ShutdownTimeout bounds the wait for in-flight requests afterwards. Together they
+	// must fit in the pod terminationGracePeriodSeconds.
+	ShutdownDrainPeriod Duration `json:"shutdownDrainPeriod"`
+	ShutdownTimeout     Duration `json:"shutdownTimeout"`
+
+	// AssessmentTimeout bounds the whole risk assessment of one admission request.
+	// It should stay below the ValidatingWebhookConfiguration timeoutSeconds.
+	AssessmentTimeout    Duration `json:"assessmentTimeout"`
+	AssessmentWorkers    int      `json:"assessmentWorkers"`
+	PVCAssessmentTimeout Duration `json:"pvcAssessmentTimeout"`
+	UnknownPVCPolicy     string   `json:"unknownPVCPolicy"`
+	FailureMode          string   `json:"failureMode"`
+
+	LogFormat string `json:"logFormat"`
+
+	BypassLabel        string   `json:"bypassLabel"`
+	ExcludedNamespaces []string `json:"excludedNamespaces"`
+	// MessageTemplatesFile holds Go templates replacing the built-in denial messages and
+	// suggestions; empty keeps the built-in text
+	MessageTemplatesFile string `json:"messageTemplatesFile"`
+	// ContactKeys are annotation or label keys, e.g. "team", read from the PVC, its
+	// workloads and its namespace and shown as contacts in denials
+	ContactKeys []string `json:"contactKeys"`
+
+	SelfManagedCerts SelfManagedCertsConfig `json:"selfManagedCerts"`
+
+	Capture CaptureConfig `json:"capture"`
+
+	DeletionLock DeletionLockConfig `json:"deletionLock"`
+
+	ChangeWindows ChangeWindowsConfig `json:"changeWindows"`
+
+	CircuitBreaker CircuitBreakerConfig `json:"circuitBreaker"`
+
+	Features        Features              `json:"features"`
+	AutoSnapshot    AutoSnapshotConfig    `json:"autoSnapshot"`
+	BackupProviders BackupProvidersConfig `json:"backupProviders"`
+}
+
+// SelfManagedCertsConfig configures certificates generated by the webhook itself
+// instead of being provided by cert-manager or the operator
+type SelfManagedCertsConfig struct {
+	Enabled           bool     `json:"enabled"`
+	SecretName        string   `json:"secretName"`
+	WebhookConfigName string   `json:"webhookConfigName"`
+	LeaseName         string   `json:"leaseName"`
+	DNSNames          []string `json:"dnsNames"`
+	Validity          Duration `json:"validity"`
+	RenewBefore       Duration `json:"renewBefore"`
+}
+
+// CaptureConfig configures recording of admission reviews for `pv-safe replay`
+type CaptureConfig struct {
+	// File is the capture file; empty disables capturing
+	File      string `json:"file"`
+	MaxSizeMB int    `json:"maxSizeMB"`
+	MaxFiles  int    `json:"maxFiles"`
+}
+
+// DeletionLockConfig lists the identities that may remove or change deletion lock
+// annotations; with none, locks cannot be removed while the webhook is running
+type DeletionLockConfig struct {
+	AdminUsers  []string `json:"adminUsers"`
+	AdminGroups []string `json:"adminGroups"`
+}
+
+// ChangeWindowsConfig restricts deletions to maintenance windows and outside change
+// freezes; with neither configured, deletions are never restricted by time
+type ChangeWindowsConfig struct {
+	// TimeZone is the IANA time zone, e.g. "Europe/Berlin", of schedules, of freeze times
+	// without an offset and of denial messages
+	TimeZone string `json:"timeZone"`
+	// Scope is risky (only the bypass label stops working) or all (every deletion is denied)
+	Scope              string                    `json:"scope"`
+	MaintenanceWindows []MaintenanceWindowConfig `json:"maintenanceWindows"`
+	Freezes            []ChangeFreezeConfig      `json:"freezes"`
+}
+
+// MaintenanceWindowConfig is a recurring window during which deletions are allowed
+type MaintenanceWindowConfig struct {
+	Name string `json:"name"`
+	// Schedule is a five-field cron expression of when the window opens
+	Schedule string   `json:"schedule"`
+	Duration Duration `json:"duration"`
+	// TimeZone overrides changeWindows.timeZone for this window
+	TimeZone string `json:"timeZone"`
+}
+
+// ChangeFreezeConfig is a named period during which deletions are denied
+type ChangeFreezeConfig struct {
+	Name string `json:"name"`
+	// Start and End (exclusive) are RFC 3339 times, or times such as "2026-11-27T08:00"
+	// and dates such as "2026-11-27" in changeWindows.timeZone
+	Start string `json:"start"`
+	End   string `json:"end"`
+	// Scope overrides changeWindows.scope for this freeze
+	Scope string `json:"scope"`
+}
+
+// CircuitBreakerConfig configures the mass-deletion circuit breaker. Limits are counted
+// per replica; the trip is recorded in a ConfigMap in the webhook's namespace.
+type CircuitBreakerConfig struct {
+	Enabled bool     `json:"enabled"`
+	Window  Duration `json:"window"`
+	// UserLimit and ClusterLimit are the PVC, PV and Namespace deletions allowed within
+	// window for one user and in total; 0 disables a limit
+	UserLimit     int    `json:"userLimit"`
+	ClusterLimit  int    `json:"clusterLimit"`
+	ConfigMapName string `json:"configMapName"`
+}
+
+// Features toggles optional protection and evidence sources
+type Features struct {
+	Snapshots              bool `json:"snapshots"`
+	GroupSnapshots         bool `json:"groupSnapshots"`
+	StorageClassProtection bool `json:"storageClassProtection"`
+}
+
+// AutoSnapshotConfig configures automatic pre-deletion snapshots
+type AutoSnapshotConfig struct {
+	Enabled                 bool   `json:"enabled"`
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName"`
+}
+
+// BackupProvidersConfig configures external backup evidence
+type BackupProvidersConfig struct {
+	Enabled         []string `json:"enabled"`
+	VeleroNamespace string   `json:"veleroNamespace"`
+	MaxAge          Duration `json:"maxAge"`
+}
+
+// Default returns the configuration used when neither a file nor flags override a setting
+func Default() *Config {
+	return &Config{
+		Port:                 "8443",
+		CertFile:             "/etc/webhook/certs/tls.crt",
+		KeyFile:              "/etc/webhook/certs/tls.key",
+		CertExpiryThreshold:  Duration{24 * time.Hour},
+		ShutdownDrainPeriod:  Duration{5 * time.Second},
+		ShutdownTimeout:      Duration{15 * time.Second},
+		AssessmentTimeout:    Duration{5 * time.Second},
+		AssessmentWorkers:    webhook.DefaultAssessmentWorkers,
+		PVCAssessmentTimeout: Duration{webhook.DefaultPVCAssessmentTimeout},
+		UnknownPVCPolicy:     string(webhook.UnknownPolicyWarn),
+		FailureMode:          FailureModeOpen,
+		LogFormat:            LogFormatText,
+		BypassLabel:          webhook.BypassLabel,
+		SelfManagedCerts: SelfManagedCertsConfig{
+			SecretName:        "pv-safe-webhook-cert",
+			WebhookConfigName: "pv-safe-validating-webhook",
+			LeaseName:         "pv-safe-webhook-cert",
+			Validity:          Duration{365 * 24 * time.Hour},
+			RenewBefore:       Duration{30 * 24 * time.Hour},
+		},
+		Capture: CaptureConfig{
+			MaxSizeMB: 10,
+			MaxFiles:  3,
+		},
+		ChangeWindows: ChangeWindowsConfig{
+			TimeZone: "UTC",
+			Scope:    string(webhook.ChangeScopeRisky),
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			Window:        Duration{time.Minute},
+			UserLimit:     20,
+			ClusterLimit:  50,
+			ConfigMapName: "pv-safe-circuit-breaker",
+		},
+		Features: Features{
+			Snapshots:              true,
+			GroupSnapshots:         true,
+			StorageClassProtection: true,
+		},
+		BackupProviders: BackupProvidersConfig{
+			VeleroNamespace: "velero",
+		},
+	}
+}
+
+// BindFlags registers a command-line flag for every setting, writing into c
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Port, "port", c.Port, "Port to listen on")
+	fs.StringVar(&c.CertFile, "cert-file", c.CertFile, "Path to TLS certificate")
+	fs.StringVar(&c.KeyFile, "key-file", c.KeyFile, "Path to TLS key")
+	fs.BoolVar(&c.InsecureHTTP, "insecure-http", c.InsecureHTTP, "Serve plain HTTP without TLS (local development only; the API server requires HTTPS)")
+	fs.StringVar(&c.Kubeconfig, "kubeconfig", c.Kubeconfig, "Path to a kubeconfig file (default: in-cluster configuration, then $KUBECONFIG or ~/.kube/config)")
+	fs.StringVar(&c.KubeContext, "context", c.KubeContext, "Kubeconfig context to use")
+	fs.Var(&c.ShutdownDrainPeriod, "shutdown-drain-period", "Time to keep serving after SIGTERM while readiness fails")
+	fs.Var(&c.ShutdownTimeout, "shutdown-timeout", "Maximum time to wait for in-flight requests during shutdown")
+	fs.Var(&c.CertExpiryThreshold, "cert-expiry-threshold", "Report not ready when the served certificate expires within this duration (0 disables)")
+
+	fs.Var(&c.AssessmentTimeout, "assessment-timeout", "Timeout for the risk assessment of one admission request")
+	fs.IntVar(&c.AssessmentWorkers, "assessment-workers", c.AssessmentWorkers, "Number of PVCs evaluated in parallel during namespace assessment")
+	fs.Var(&c.PVCAssessmentTimeout, "pvc-assessment-timeout", "Deadline for evaluating a single PVC during namespace assessment")
+	fs.StringVar(&c.UnknownPVCPolicy, "unknown-pvc-policy", c.UnknownPVCPolicy, "How PVCs that cannot be assessed affect namespace deletion: block, warn or allow")
+	fs.StringVar(&c.FailureMode, "failure-mode", c.FailureMode, "Decision when risk assessment fails: open (allow) or closed (deny)")
+
+	fs.BoolVar(&c.SelfManagedCerts.Enabled, "self-managed-certs", c.SelfManagedCerts.Enabled, "Generate and rotate the CA and serving certificate in a Secret and patch the webhook caBundle")
+	fs.StringVar(&c.SelfManagedCerts.SecretName, "cert-secret-name", c.SelfManagedCerts.SecretName, "Secret holding self-managed certificates")
+	fs.StringVar(&c.SelfManagedCerts.WebhookConfigName, "webhook-config-name", c.SelfManagedCerts.WebhookConfigName, "ValidatingWebhookConfiguration whose caBundle is managed")
+	fs.StringVar(&c.SelfManagedCerts.LeaseName, "cert-lease-name", c.SelfManagedCerts.LeaseName, "Lease electing the replica that rotates self-managed certificates")
+	fs.Var((*stringList)(&c.SelfManagedCerts.DNSNames), "cert-dns-names", "Comma-separated DNS names of the webhook Service for self-managed certificates")
+	fs.Var(&c.SelfManagedCerts.Validity, "cert-validity", "Lifetime of self-managed serving certificates")
+	fs.Var(&c.SelfManagedCerts.RenewBefore, "cert-renew-before", "Rotate self-managed certificates this long before they expire")
+
+	fs.StringVar(&c.Capture.File, "capture-file", c.Capture.File, "Record redacted admission reviews and responses to this file for pv-safe replay (empty disables)")
+	fs.IntVar(&c.Capture.MaxSizeMB, "capture-max-size-mb", c.Capture.MaxSizeMB, "Rotate the capture file when it reaches this size in megabytes")
+	fs.IntVar(&c.Capture.MaxFiles, "capture-max-files", c.Capture.MaxFiles, "Number of rotated capture files to keep")
+
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "Log format: text or json")
+
+	fs.StringVar(&c.BypassLabel, "bypass-label", c.BypassLabel, "Label key that forces a deletion when set to \"true\"")
+	fs.Var((*stringList)(&c.ExcludedNamespaces), "excluded-namespaces", "Comma-separated namespaces whose deletions are never assessed")
+	fs.StringVar(&c.MessageTemplatesFile, "message-templates", c.MessageTemplatesFile, "File of Go templates replacing the built-in denial messages and suggestions")
+	fs.Var((*stringList)(&c.ContactKeys), "contact-keys", "Comma-separated annotation or label keys reported as owner contacts in denials")
+	fs.Var((*stringList)(&c.DeletionLock.AdminUsers), "deletion-lock-admin-users", "Comma-separated users allowed to remove or change deletion lock annotations")
+	fs.Var((*stringList)(&c.DeletionLock.AdminGroups), "deletion-lock-admin-groups", "Comma-separated groups allowed to remove or change deletion lock annotations")
+
+	fs.BoolVar(&c.CircuitBreaker.Enabled, "circuit-breaker", c.CircuitBreaker.Enabled, "Deny all deletions once too many PVC, PV and Namespace deletions happen within the window, until reset")
+	fs.Var(&c.CircuitBreaker.Window, "circuit-breaker-window", "Sliding window in which the circuit breaker counts deletions")
+	fs.IntVar(&c.CircuitBreaker.UserLimit, "circuit-breaker-user-limit", c.CircuitBreaker.UserLimit, "Deletions one user may make within the window before the circuit breaker trips (0 disables)")
+	fs.IntVar(&c.CircuitBreaker.ClusterLimit, "circuit-breaker-cluster-limit", c.CircuitBreaker.ClusterLimit, "Deletions allowed within the window in total, per replica, before the circuit breaker trips (0 disables)")
+	fs.StringVar(&c.CircuitBreaker.ConfigMapName, "circuit-breaker-configmap", c.CircuitBreaker.ConfigMapName, "ConfigMap recording a circuit breaker trip; delete it to reset the breaker")
+
+	fs.BoolVar(&c.Features.Snapshots, "enable-snapshots", c.Features.Snapshots, "Accept VolumeSnapshots as backup evidence")
+	fs.BoolVar(&c.Features.GroupSnapshots, "enable-group-snapshots", c.Features.GroupSnapshots, "Accept VolumeGroupSnapshots as backup evidence")
+	fs.BoolVar(&c.Features.StorageClassProtection, "enable-storage-class-protection", c.Features.StorageClassProtection, "Assess StorageClass and CSIDriver deletions")
+
+	fs.BoolVar(&c.AutoSnapshot.Enabled, "auto-snapshot", c.AutoSnapshot.Enabled, "Create VolumeSnapshots for risky PVCs when their deletion is blocked")
+	fs.StringVar(&c.AutoSnapshot.VolumeSnapshotClassName, "auto-snapshot-class", c.AutoSnapshot.VolumeSnapshotClassName, "VolumeSnapshotClass (with Retain deletion policy) used for automatic snapshots")
+
+	fs.Var((*stringList)(&c.BackupProviders.Enabled), "backup-providers", "Comma-separated backup providers consulted as backup evidence (supported: velero)")
+	fs.StringVar(&c.BackupProviders.VeleroNamespace, "velero-namespace", c.BackupProviders.VeleroNamespace, "Namespace containing Velero Backup and PodVolumeBackup resources")
+	fs.Var(&c.BackupProviders.MaxAge, "backup-max-age", "Maximum age of a backup to be accepted as evidence (0 disables the check)")
+}
+
+// ConfigureHandler applies the assessment settings to a webhook handler, loading the
+// message templates if configured
+func (c *Config) ConfigureHandler(handler *webhook.Handler) error {
+	unknownPolicy, err := webhook.ParseUnknownPolicy(c.UnknownPVCPolicy)
+	if err != nil {
+		return err
+	}
+
+	handler.AssessmentTimeout = c.AssessmentTimeout.Duration
+	handler.FailClosed = c.FailureMode == FailureModeClosed
+	handler.ProtectStorageClasses = c.Features.StorageClassProtection
+	handler.SetBypassLabel(c.BypassLabel)
+	for _, namespace := range c.ExcludedNamespaces {
+		handler.ExcludedNamespaces[namespace] = true
+	}
+
+	handler.RiskCalculator.SetUnknownPolicy(unknownPolicy)
+	handler.RiskCalculator.SetConcurrency(c.AssessmentWorkers, c.PVCAssessmentTimeout.Duration)
+	handler.RiskCalculator.SetContactKeys(c.ContactKeys)
+	handler.LockAdminUsers = c.DeletionLock.AdminUsers
+	handler.LockAdminGroups = c.DeletionLock.AdminGroups
+
+	changeWindows, problems := c.ChangeWindows.build()
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid changeWindows: %s", strings.Join(problems, "; "))
+	}
+	handler.ChangeWindows = changeWindows
+
+	if c.MessageTemplatesFile != "" {
+		templates, err := webhook.LoadMessageTemplates(c.MessageTemplatesFile)
+		if err != nil {
+			return err
+		}
+		templates.SetLogger(handler.Logger)
+		handler.RiskCalculator.SetMessageTemplates(templates)
+	}
+
+	return nil
+}
+
+// LoadFile reads a YAML configuration file on top of the current settings.
+// Settings missing from the file keep their current values; unknown keys are rejected.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path) //nolint:gosec // G304: path comes from a trusted flag
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Load builds the configuration from defaults, the optional --config file and flags.
+// Flags given on the command line take precedence over the file.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	cfg.BindFlags(fs)
+	path := fs.String("config", "", "Path to a YAML configuration file")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *path != "" {
+		if err := cfg.LoadFile(*path); err != nil {
+			return nil, err
+		}
+		// Re-apply command-line flags so they override the file
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that all settings are usable
+func (c *Config) Validate() error {
+	var errs []string
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Sprintf("port %q must be a number between 1 and 65535", c.Port))
+	}
+	if !c.InsecureHTTP && (c.CertFile == "" || c.KeyFile == "") {
+		errs = append(errs, "certFile and keyFile are required unless insecureHTTP is set")
+	}
+	if c.InsecureHTTP && c.SelfManagedCerts.Enabled {
+		errs = append(errs, "insecureHTTP cannot be combined with selfManagedCerts")
+	}
+	if c.CertExpiryThreshold.Duration < 0 {
+		errs = append(errs, "certExpiryThreshold must not be negative")
+	}
+	if c.ShutdownDrainPeriod.Duration < 0 || c.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, fmt.Sprintf("shutdownDrainPeriod %s must not be negative and shutdownTimeout %s must be positive",
+			c.ShutdownDrainPeriod, c.ShutdownTimeout))
+	}
+
+	if c.SelfManagedCerts.Enabled {
+		certs := c.SelfManagedCerts
+		if certs.SecretName == "" || certs.WebhookConfigName == "" || certs.LeaseName == "" {
+			errs = append(errs, "selfManagedCerts requires secretName, webhookConfigName and leaseName")
+		}
+		if len(certs.DNSNames) == 0 {
+			errs = append(errs, "selfManagedCerts.dnsNames must list the webhook Service DNS names")
+		}
+		if certs.RenewBefore.Duration <= 0 || certs.RenewBefore.Duration >= certs.Validity.Duration {
+			errs = append(errs, fmt.Sprintf("selfManagedCerts.renewBefore %s must be positive and shorter than validity %s",
+				certs.RenewBefore, certs.Validity))
+		}
+	}
+
+	if c.AssessmentTimeout.Duration <= 0 || c.AssessmentTimeout.Duration > maxAssessmentTimeout {
+		errs = append(errs, fmt.Sprintf("assessmentTimeout %s must be between 0s and %s", c.AssessmentTimeout, maxAssessmentTimeout))
+	}
+	if c.AssessmentWorkers < 1 {
+		errs = append(errs, fmt.Sprintf("assessmentWorkers %d must be at least 1", c.AssessmentWorkers))
+	}
+	if c.PVCAssessmentTimeout.Duration <= 0 {
+		errs = append(errs, fmt.Sprintf("pvcAssessmentTimeout %s must be positive", c.PVCAssessmentTimeout))
+	}
+	if _, err := webhook.ParseUnknownPolicy(c.UnknownPVCPolicy); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if c.FailureMode != FailureModeOpen && c.FailureMode != FailureModeClosed {
+		errs = append(errs, fmt.Sprintf("failureMode %q must be open or closed", c.FailureMode))
+	}
+
+	if c.Capture.File != "" && (c.Capture.MaxSizeMB < 1 || c.Capture.MaxFiles < 0) {
+		errs = append(errs, fmt.Sprintf("capture.maxSizeMB %d must be at least 1 and capture.maxFiles %d must not be negative",
+			c.Capture.MaxSizeMB, c.Capture.MaxFiles))
+	}
+
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		errs = append(errs, fmt.Sprintf("logFormat %q must be text or json", c.LogFormat))
+	}
+
+	if msgs := validation.IsQualifiedName(c.BypassLabel); len(msgs) > 0 {
+		errs = append(errs, fmt.Sprintf("bypassLabel %q is not a valid label key: %s", c.BypassLabel, strings.Join(msgs, "; ")))
+	}
+	for _, ns := range c.ExcludedNamespaces {
+		if msgs := validation.IsDNS1123Label(ns); len(msgs) > 0 {
+			errs = append(errs, fmt.Sprintf("excludedNamespaces entry %q is not a valid namespace name", ns))
+		}
+	}
+	for _, key := range c.ContactKeys {
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			errs = append(errs, fmt.Sprintf("contactKeys entry %q is not a valid annotation or label key: %s", key, strings.Join(msgs, "; ")))
+		}
+	}
+
+	if _, problems := c.ChangeWindows.build(); len(problems) > 0 {
+		errs = append(errs, problems...)
+	}
+
+	if breaker := c.CircuitBreaker; breaker.Enabled {
+		if breaker.Window.Duration <= 0 {
+			errs = append(errs, fmt.Sprintf("circuitBreaker.window %s must be positive", breaker.Window))
+		}
+		if breaker.UserLimit < 0 || breaker.ClusterLimit < 0 || (breaker.UserLimit == 0 && breaker.ClusterLimit == 0) {
+			errs = append(errs, fmt.Sprintf("circuitBreaker.userLimit %d and clusterLimit %d must not be negative, and at least one must be set",
+				breaker.UserLimit, breaker.ClusterLimit))
+		}
+		if msgs := validation.IsDNS1123Subdomain(breaker.ConfigMapName); len(msgs) > 0 {
+			errs = append(errs, fmt.Sprintf("circuitBreaker.configMapName %q is not a valid ConfigMap name", breaker.ConfigMapName))
+		}
+	}
+
+	if c.AutoSnapshot.Enabled {
+		if !c.Features.Snapshots {
+			errs = append(errs, "autoSnapshot requires the snapshots feature")
+		}
+		if c.AutoSnapshot.VolumeSnapshotClassName == "" {
+			errs = append(errs, "autoSnapshot.volumeSnapshotClassName is required when autoSnapshot is enabled")
+		}
+	}
+
+	for _, provider := range c.BackupProviders.Enabled {
+		if provider != webhook.VeleroProviderName {
+			errs = append(errs, fmt.Sprintf("unknown backup provider %q", provider))
+		}
+	}
+	if c.BackupProviders.MaxAge.Duration < 0 {
+		errs = append(errs, "backupProviders.maxAge must not be negative")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+
+	return nil
+}
+
+// freezeTimeLayouts are the accepted layouts of freeze times without a UTC offset
+var freezeTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// build converts the change windows for the handler. It returns nil when neither windows
+// nor freezes are configured, and the problems found instead when the settings are invalid.
+func (c ChangeWindowsConfig) build() (*webhook.ChangeWindows, []string) {
+	if len(c.MaintenanceWindows) == 0 && len(c.Freezes) == 0 {
+		return nil, nil
+	}
+
+	var problems []string
+
+	location, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("changeWindows.timeZone %q is not a known time zone", c.TimeZone))
+		location = time.UTC
+	}
+	scope, err := webhook.ParseChangeScope(c.Scope)
+	if err != nil {
+		problems = append(problems, "changeWindows: "+err.Error())
+	}
+
+	windows := &webhook.ChangeWindows{Scope: scope, Location: location}
+
+	for i, w := range c.MaintenanceWindows {
+		field := fmt.Sprintf("changeWindows.maintenanceWindows[%d]", i)
+		schedule, err := webhook.ParseCronSchedule(w.Schedule)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", field, err))
+		}
+		if w.Duration.Duration <= 0 {
+			problems = append(problems, fmt.Sprintf("%s.duration %s must be positive", field, w.Duration))
+		}
+		windowLocation := location
+		if w.TimeZone != "" {
+			if windowLocation, err = time.LoadLocation(w.TimeZone); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.timeZone %q is not a known time zone", field, w.TimeZone))
+			}
+		}
+		windows.Windows = append(windows.Windows, webhook.MaintenanceWindow{
+			Name:     w.Name,
+			Schedule: schedule,
+			Duration: w.Duration.Duration,
+			Location: windowLocation,
+		})
+	}
+
+	for i, f := range c.Freezes {
+		field := fmt.Sprintf("changeWindows.freezes[%d]", i)
+		if f.Name == "" {
+			problems = append(problems, field+".name is required")
+		}
+		start, startErr := parseFreezeTime(f.Start, location)
+		end, endErr := parseFreezeTime(f.End, location)
+		switch {
+		case startErr != nil:
+			problems = append(problems, fmt.Sprintf("%s.start: %v", field, startErr))
+		case endErr != nil:
+			problems = append(problems, fmt.Sprintf("%s.end: %v", field, endErr))
+		case !end.After(start):
+			problems = append(problems, fmt.Sprintf("%s.end %s must be after start %s", field, f.End, f.Start))
+		}
+		var freezeScope webhook.ChangeScope
+		if f.Scope != "" {
+			if freezeScope, err = webhook.ParseChangeScope(f.Scope); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", field, err))
+			}
+		}
+		windows.Freezes = append(windows.Freezes, webhook.ChangeFreeze{Name: f.Name, Start: start, End: end, Scope: freezeScope})
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return windows, nil
+}
+
+// parseFreezeTime parses an RFC 3339 time, or a time or date in location
+func parseFreezeTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range freezeTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time or a date such as 2026-11-27", value)
+}
+
+// Duration is a time.Duration that reads from YAML strings such as "5s" and works as a flag
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+	return d.Set(value)
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// Set parses a duration flag value
+func (d *Duration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// stringList is a comma-separated list flag
+type stringList []string
+
+// String returns the list joined with commas
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set replaces the list with the comma-separated values
+func (s *stringList) Set(value string) error {
+	*s = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*s = append(*s, item)
+		}
+	}
+	return nil
+}
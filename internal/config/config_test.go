@@ -0,0 +1,255 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.AssessmentTimeout.Duration != 5*time.Second {
+		t.Errorf("AssessmentTimeout = %s, want 5s", cfg.AssessmentTimeout)
+	}
+	if cfg.FailureMode != FailureModeOpen || cfg.LogFormat != LogFormatText {
+		t.Errorf("FailureMode/LogFormat = %s/%s, want open/text", cfg.FailureMode, cfg.LogFormat)
+	}
+	if !cfg.Features.Snapshots || !cfg.Features.GroupSnapshots || !cfg.Features.StorageClassProtection {
+		t.Errorf("Features = %+v, want all enabled", cfg.Features)
+	}
+}
+
+func TestLoadFileAndFlagPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+assessmentTimeout: 8s
+failureMode: closed
+logFormat: json
+bypassLabel: example.com/allow-delete
+excludedNamespaces: [scratch, ci]
+features:
+  groupSnapshots: false
+backupProviders:
+  enabled: [velero]
+  maxAge: 24h
+deletionLock:
+  adminUsers: [jane]
+  adminGroups: [storage-admins]
+`)
+
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError),
+		[]string{"--config", path, "--assessment-timeout=9s", "--excluded-namespaces=sandbox", "--deletion-lock-admin-groups=dba,sre"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.AssessmentTimeout.Duration != 9*time.Second {
+		t.Errorf("AssessmentTimeout = %s, want the flag value 9s", cfg.AssessmentTimeout)
+	}
+	if cfg.FailureMode != FailureModeClosed || cfg.LogFormat != LogFormatJSON {
+		t.Errorf("FailureMode/LogFormat = %s/%s, want closed/json from the file", cfg.FailureMode, cfg.LogFormat)
+	}
+	if cfg.BypassLabel != "example.com/allow-delete" {
+		t.Errorf("BypassLabel = %q, want the file value", cfg.BypassLabel)
+	}
+	if strings.Join(cfg.ExcludedNamespaces, ",") != "sandbox" {
+		t.Errorf("ExcludedNamespaces = %v, want the flag value [sandbox]", cfg.ExcludedNamespaces)
+	}
+	if cfg.Features.GroupSnapshots || !cfg.Features.Snapshots {
+		t.Errorf("Features = %+v, want group snapshots disabled and snapshots kept enabled", cfg.Features)
+	}
+	if cfg.BackupProviders.MaxAge.Duration != 24*time.Hour || cfg.BackupProviders.VeleroNamespace != "velero" {
+		t.Errorf("BackupProviders = %+v, want maxAge 24h and default namespace", cfg.BackupProviders)
+	}
+	if strings.Join(cfg.DeletionLock.AdminUsers, ",") != "jane" || strings.Join(cfg.DeletionLock.AdminGroups, ",") != "dba,sre" {
+		t.Errorf("DeletionLock = %+v, want file users and flag groups", cfg.DeletionLock)
+	}
+}
+
+func TestLoadFileRejectsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "assesmentTimeout: 8s\n")
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--config", path})
+	if err == nil || !strings.Contains(err.Error(), "assesmentTimeout") {
+		t.Fatalf("Load() error = %v, want unknown field error", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{name: "defaults are valid", modify: func(*Config) {}},
+		{
+			name:    "timeout above API server maximum",
+			modify:  func(c *Config) { c.AssessmentTimeout.Duration = time.Minute },
+			wantErr: "assessmentTimeout 1m0s must be between 0s and 30s",
+		},
+		{
+			name:    "unknown failure mode",
+			modify:  func(c *Config) { c.FailureMode = "maybe" },
+			wantErr: `failureMode "maybe" must be open or closed`,
+		},
+		{
+			name:    "invalid log format",
+			modify:  func(c *Config) { c.LogFormat = "xml" },
+			wantErr: `logFormat "xml" must be text or json`,
+		},
+		{
+			name:    "invalid bypass label",
+			modify:  func(c *Config) { c.BypassLabel = "not a label" },
+			wantErr: `bypassLabel "not a label" is not a valid label key`,
+		},
+		{
+			name:    "invalid excluded namespace",
+			modify:  func(c *Config) { c.ExcludedNamespaces = []string{"Bad_NS"} },
+			wantErr: `excludedNamespaces entry "Bad_NS"`,
+		},
+		{
+			name:    "invalid contact key",
+			modify:  func(c *Config) { c.ContactKeys = []string{"owner team"} },
+			wantErr: `contactKeys entry "owner team" is not a valid annotation or label key`,
+		},
+		{
+			name:    "auto snapshot without class",
+			modify:  func(c *Config) { c.AutoSnapshot.Enabled = true },
+			wantErr: "autoSnapshot.volumeSnapshotClassName is required",
+		},
+		{
+			name:    "unknown backup provider",
+			modify:  func(c *Config) { c.BackupProviders.Enabled = []string{"kasten"} },
+			wantErr: `unknown backup provider "kasten"`,
+		},
+		{
+			name:    "invalid unknown PVC policy",
+			modify:  func(c *Config) { c.UnknownPVCPolicy = "ignore" },
+			wantErr: `invalid unknown PVC policy "ignore"`,
+		},
+		{
+			name: "invalid change windows",
+			modify: func(c *Config) {
+				c.ChangeWindows.TimeZone = "Mars/Olympus"
+				c.ChangeWindows.MaintenanceWindows = []MaintenanceWindowConfig{{Schedule: "0 25 * * *", Duration: Duration{time.Hour}}}
+				c.ChangeWindows.Freezes = []ChangeFreezeConfig{{Name: "peak", Start: "2026-11-30", End: "2026-11-27"}}
+			},
+			wantErr: `changeWindows.timeZone "Mars/Olympus" is not a known time zone
+  - changeWindows.maintenanceWindows[0]: cron schedule "0 25 * * *": invalid value "25" in hour field (must be 0-23)
+  - changeWindows.freezes[0].end 2026-11-27 must be after start 2026-11-30`,
+		},
+		{
+			name: "circuit breaker without limits",
+			modify: func(c *Config) {
+				c.CircuitBreaker.Enabled = true
+				c.CircuitBreaker.UserLimit = 0
+				c.CircuitBreaker.ClusterLimit = 0
+			},
+			wantErr: "circuitBreaker.userLimit 0 and clusterLimit 0 must not be negative, and at least one must be set",
+		},
+		{
+			name: "capture file without size limit",
+			modify: func(c *Config) {
+				c.Capture.File = "/var/run/pv-safe/captures.jsonl"
+				c.Capture.MaxSizeMB = 0
+			},
+			wantErr: "capture.maxSizeMB 0 must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigureHandlerMessageTemplates(t *testing.T) {
+	handler := webhook.NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(), nil)
+
+	cfg := Default()
+	cfg.MessageTemplatesFile = filepath.Join(t.TempDir(), "missing.tmpl")
+	if err := cfg.ConfigureHandler(handler); err == nil || !strings.Contains(err.Error(), "failed to read message templates") {
+		t.Errorf("ConfigureHandler() error = %v, want missing template file error", err)
+	}
+
+	cfg.MessageTemplatesFile = writeConfigFile(t, `{{ define "pvc-block" }}{{ .Name }}{{ end }}`)
+	if err := cfg.ConfigureHandler(handler); err != nil {
+		t.Errorf("ConfigureHandler() error = %v", err)
+	}
+}
+
+func TestConfigureHandlerChangeWindows(t *testing.T) {
+	path := writeConfigFile(t, `
+changeWindows:
+  timeZone: America/New_York
+  scope: all
+  maintenanceWindows:
+    - name: weekly
+      schedule: "0 2 * * sun"
+      duration: 4h
+  freezes:
+    - name: black-friday
+      start: 2026-11-26
+      end: "2026-11-30T08:00"
+      scope: risky
+`)
+
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), []string{"--config", path})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	handler := webhook.NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(), nil)
+	if err := cfg.ConfigureHandler(handler); err != nil {
+		t.Fatalf("ConfigureHandler() error = %v", err)
+	}
+
+	windows := handler.ChangeWindows
+	if windows == nil || windows.Scope != webhook.ChangeScopeAll || len(windows.Windows) != 1 || len(windows.Freezes) != 1 {
+		t.Fatalf("ChangeWindows = %+v, want one all-scope window and one freeze", windows)
+	}
+	freeze := windows.Freezes[0]
+	newYork := windows.Location
+	if !freeze.Start.Equal(time.Date(2026, 11, 26, 0, 0, 0, 0, newYork)) || !freeze.End.Equal(time.Date(2026, 11, 30, 8, 0, 0, 0, newYork)) ||
+		freeze.Scope != webhook.ChangeScopeRisky {
+		t.Errorf("freeze = %+v, want Nov 26 00:00 to Nov 30 08:00 New York time, risky scope", freeze)
+	}
+
+	// Without windows or freezes nothing is restricted
+	if err := Default().ConfigureHandler(handler); err != nil || handler.ChangeWindows != nil {
+		t.Errorf("ConfigureHandler() = %v, ChangeWindows %+v, want no change windows by default", err, handler.ChangeWindows)
+	}
+}
@@ -0,0 +1,185 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+// Result summarizes a replay run
+type Result struct {
+	Total   int
+	Changed int
+	Failed  int
+}
+
+// Replay sends each captured request through the handler, writes the new decision and,
+// when it differs from the recorded response, a line diff of the two
+func Replay(handler http.Handler, records []webhook.CaptureRecord, out io.Writer) Result {
+	var result Result
+
+	for _, record := range records {
+		result.Total++
+		request := record.Request.Request
+		fmt.Fprintf(out, "=== %s %s %s (uid %s, user %s, recorded %s)\n",
+			request.Operation, request.Kind.Kind, objectName(request), request.UID,
+			request.UserInfo.Username, record.Time.Format("2006-01-02T15:04:05Z07:00"))
+
+		response, err := send(handler, record.Request)
+		if err != nil {
+			result.Failed++
+			fmt.Fprintf(out, "error: %v\n\n", err)
+			continue
+		}
+
+		replayed := renderResponse(response)
+		fmt.Fprint(out, indent(replayed))
+
+		if record.Response == nil || record.Response.Response == nil {
+			fmt.Fprintln(out, "no recorded response to compare")
+			fmt.Fprintln(out)
+			continue
+		}
+
+		recorded := renderResponse(record.Response.Response)
+		if recorded == replayed {
+			fmt.Fprintln(out, "unchanged")
+		} else {
+			result.Changed++
+			fmt.Fprintln(out, "changed (- recorded, + replayed):")
+			fmt.Fprint(out, diffLines(recorded, replayed))
+		}
+		fmt.Fprintln(out)
+	}
+
+	fmt.Fprintf(out, "%d replayed, %d unchanged, %d changed, %d failed\n",
+		result.Total, result.Total-result.Changed-result.Failed, result.Changed, result.Failed)
+
+	return result
+}
+
+// send posts an admission review to the handler and decodes its response
+func send(handler http.Handler, review *admissionv1.AdmissionReview) (*admissionv1.AdmissionResponse, error) {
+	body, err := json.Marshal(review)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal admission review: %w", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		return nil, fmt.Errorf("handler returned HTTP %d: %s", rec.Code, strings.TrimSpace(rec.Body.String()))
+	}
+
+	var response admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		return nil, fmt.Errorf("invalid admission response: %w", err)
+	}
+	if response.Response == nil {
+		return nil, fmt.Errorf("admission response has no response")
+	}
+
+	return response.Response, nil
+}
+
+// renderResponse renders the parts of a response that make up the decision, one per line
+func renderResponse(response *admissionv1.AdmissionResponse) string {
+	var b strings.Builder
+
+	if response.Allowed {
+		b.WriteString("allowed\n")
+	} else {
+		b.WriteString("denied\n")
+	}
+	if response.Result != nil {
+		if response.Result.Code != 0 {
+			fmt.Fprintf(&b, "code: %d\n", response.Result.Code)
+		}
+		if response.Result.Reason != "" {
+			fmt.Fprintf(&b, "reason: %s\n", response.Result.Reason)
+		}
+		for _, line := range strings.Split(response.Result.Message, "\n") {
+			if line != "" {
+				fmt.Fprintf(&b, "message: %s\n", line)
+			}
+		}
+		if response.Result.Details != nil {
+			for _, cause := range response.Result.Details.Causes {
+				fmt.Fprintf(&b, "cause: %s %s %s\n", cause.Field, cause.Type, cause.Message)
+			}
+		}
+	}
+	for _, warning := range response.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", warning)
+	}
+
+	return b.String()
+}
+
+// diffLines returns a line diff of two texts based on their longest common subsequence
+func diffLines(before, after string) string {
+	a := strings.Split(strings.TrimSuffix(before, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(after, "\n"), "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(&out, "    %s\n", a[i])
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			fmt.Fprintf(&out, "  + %s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(&out, "  - %s\n", a[i])
+			i++
+		}
+	}
+
+	return out.String()
+}
+
+// indent indents every line of a text for display under a request header
+func indent(text string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintf(&b, "  %s\n", line)
+	}
+	return b.String()
+}
+
+// objectName returns namespace/name for namespaced objects and name otherwise
+func objectName(request *admissionv1.AdmissionRequest) string {
+	if request.Namespace == "" {
+		return request.Name
+	}
+	return request.Namespace + "/" + request.Name
+}
@@ -0,0 +1,128 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+// loadTestState loads testdata/state.yaml
+func loadTestState(t *testing.T) *State {
+	t.Helper()
+
+	file, err := os.Open("testdata/state.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	state, err := LoadState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// newPVCDeletionRecord creates a capture record of a PVC deletion with the recorded decision
+func newPVCDeletionRecord(t *testing.T, name string, allowed bool, message string) webhook.CaptureRecord {
+	t.Helper()
+
+	raw, err := json.Marshal(&corev1.PersistentVolumeClaim{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "app"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := &admissionv1.AdmissionResponse{
+		UID:     types.UID("uid-" + name),
+		Allowed: allowed,
+		Result:  &metav1.Status{Message: message},
+	}
+
+	return webhook.CaptureRecord{
+		Time: time.Date(2026, 1, 3, 0, 0, 0, 0, time.UTC),
+		Request: &admissionv1.AdmissionReview{
+			TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+			Request: &admissionv1.AdmissionRequest{
+				UID:       types.UID("uid-" + name),
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+				Operation: admissionv1.Delete,
+				Namespace: "app",
+				Name:      name,
+				UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+				OldObject: runtime.RawExtension{Raw: raw},
+			},
+		},
+		Response: &admissionv1.AdmissionReview{Response: response},
+	}
+}
+
+func TestLoadState(t *testing.T) {
+	state := loadTestState(t)
+
+	want := "1 Namespace, 2 PersistentVolumeClaim, 2 PersistentVolume, 1 VolumeSnapshotClass, 1 VolumeSnapshot, 1 VolumeSnapshotContent"
+	if got := state.String(); got != want {
+		t.Errorf("state = %q, want %q", got, want)
+	}
+
+	if _, err := LoadState(strings.NewReader("metadata:\n  name: nameless\n")); err == nil {
+		t.Error("expected an error for an object without kind")
+	}
+}
+
+func TestReplayAgainstState(t *testing.T) {
+	client, dynamicClient := loadTestState(t).Clients()
+	handler := webhook.NewHandler(log.New(io.Discard, "", 0), client,
+		webhook.NewSnapshotCheckerForClient(dynamicClient, client))
+
+	records := []webhook.CaptureRecord{
+		// Protected by its snapshot, as recorded
+		newPVCDeletionRecord(t, "data", true, "Deletion allowed - safe operation"),
+		// Recorded as allowed, but the claim has no snapshot in the state
+		newPVCDeletionRecord(t, "scratch", true, "Deletion allowed - safe operation"),
+	}
+
+	var out strings.Builder
+	result := Replay(handler, records, &out)
+
+	if result != (Result{Total: 2, Changed: 1}) {
+		t.Errorf("result = %+v, want 2 replayed with 1 changed", result)
+	}
+
+	output := out.String()
+	for _, want := range []string{
+		"=== DELETE PersistentVolumeClaim app/data (uid uid-data, user alice, recorded 2026-01-03T00:00:00Z)\n",
+		"  allowed\n",
+		"unchanged\n",
+		"=== DELETE PersistentVolumeClaim app/scratch (uid uid-scratch",
+		"changed (- recorded, + replayed):\n  - allowed\n  - message: Deletion allowed - safe operation\n  + denied\n  + code: 403\n",
+		"2 replayed, 1 unchanged, 1 changed, 0 failed\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output does not contain %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	got := diffLines("denied\ncode: 403\nmessage: a\nmessage: b\n", "denied\ncode: 403\nmessage: b\nmessage: c\n")
+	want := "    denied\n    code: 403\n  - message: a\n    message: b\n  + message: c\n"
+	if got != want {
+		t.Errorf("diff =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,141 @@
+// Package replay feeds captured admission reviews back through the webhook handler to
+// reproduce its decisions, either against a live cluster or against a cluster state
+// snapshot loaded into fake clients.
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+// State is a cluster state snapshot: built-in objects such as PVCs, PVs and Namespaces,
+// and custom resources such as VolumeSnapshots
+type State struct {
+	Objects         []runtime.Object
+	CustomResources []*unstructured.Unstructured
+}
+
+// LoadState reads a YAML or JSON state snapshot. It accepts one or more documents, each
+// an object or a List of objects, as written by
+//
+//	kubectl get namespaces,pvc,pv,volumesnapshots,volumesnapshotclasses,volumesnapshotcontents -A -o yaml
+func LoadState(in io.Reader) (*State, error) {
+	state := &State{}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(in, 4096)
+
+	for {
+		var doc unstructured.Unstructured
+		if err := decoder.Decode(&doc.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("failed to parse state: %w", err)
+		}
+		if len(doc.Object) == 0 {
+			continue
+		}
+
+		if !doc.IsList() {
+			if err := state.add(&doc); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err := doc.EachListItem(func(item runtime.Object) error {
+			return state.add(item.(*unstructured.Unstructured))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return state, nil
+}
+
+// add sorts an object into built-in objects, converted to their typed form, and custom resources
+func (s *State) add(obj *unstructured.Unstructured) error {
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" {
+		return fmt.Errorf("state object %q has no kind", obj.GetName())
+	}
+
+	if !scheme.Scheme.Recognizes(gvk) {
+		s.CustomResources = append(s.CustomResources, obj)
+		return nil
+	}
+
+	typed, err := scheme.Scheme.New(gvk)
+	if err != nil {
+		return err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+		return fmt.Errorf("invalid %s %q in state: %w", gvk.Kind, obj.GetName(), err)
+	}
+	s.Objects = append(s.Objects, typed)
+
+	return nil
+}
+
+// Clients returns fake clients serving the state. Every custom resource the webhook reads
+// can be listed, even when the state has none of them.
+func (s *State) Clients() (kubernetes.Interface, dynamic.Interface) {
+	listKinds := webhook.CustomResourceListKinds()
+	objects := make([]runtime.Object, 0, len(s.CustomResources))
+	for _, obj := range s.CustomResources {
+		gvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+		if _, known := listKinds[gvr]; !known {
+			listKinds[gvr] = obj.GetKind() + "List"
+		}
+		objects = append(objects, obj)
+	}
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds, objects...)
+	return fake.NewClientset(s.Objects...), dynamicClient
+}
+
+// String summarizes the state by kind, e.g. "3 PersistentVolumeClaim, 3 PersistentVolume"
+func (s *State) String() string {
+	counts := map[string]int{}
+	var kinds []string
+	count := func(gvk schema.GroupVersionKind) {
+		if counts[gvk.Kind] == 0 {
+			kinds = append(kinds, gvk.Kind)
+		}
+		counts[gvk.Kind]++
+	}
+
+	for _, obj := range s.Objects {
+		gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err == nil && len(gvks) > 0 {
+			count(gvks[0])
+		}
+	}
+	for _, obj := range s.CustomResources {
+		count(obj.GroupVersionKind())
+	}
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+	}
+	if len(parts) == 0 {
+		return "empty state"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// AutoSnapshotLabel marks VolumeSnapshots created by pv-safe before a deletion
+	AutoSnapshotLabel = "pv-safe.io/auto-snapshot"
+
+	// AutoSnapshotSourceLabel records the PVC an automatic snapshot was taken from
+	AutoSnapshotSourceLabel = "pv-safe.io/source-pvc"
+
+	// maxSnapshotNameLength is the maximum length of a VolumeSnapshot name (DNS subdomain)
+	maxSnapshotNameLength = 253
+)
+
+// AutoSnapshotter creates VolumeSnapshots for risky PVCs when a deletion is blocked,
+// so that the next deletion attempt is allowed once the snapshot becomes ready.
+type AutoSnapshotter struct {
+	snapshotChecker *SnapshotChecker
+	className       string
+}
+
+// PendingSnapshot describes a VolumeSnapshot requested on behalf of a blocked deletion
+type PendingSnapshot struct {
+	Name      string
+	Namespace string
+	SourcePVC string
+	Created   bool
+	Error     error
+}
+
+// NewAutoSnapshotter creates a new auto snapshotter using the given VolumeSnapshotClass
+func NewAutoSnapshotter(snapshotChecker *SnapshotChecker, className string) (*AutoSnapshotter, error) {
+	if snapshotChecker == nil {
+		return nil, fmt.Errorf("snapshot support is not available")
+	}
+	if className == "" {
+		return nil, fmt.Errorf("a VolumeSnapshotClass name is required")
+	}
+
+	return &AutoSnapshotter{
+		snapshotChecker: snapshotChecker,
+		className:       className,
+	}, nil
+}
+
+// Validate checks that the configured VolumeSnapshotClass exists and retains its content.
+// Snapshots from a class with any other deletion policy are not accepted as protection
+// by HasReadySnapshot, so creating them would never unblock a deletion.
+func (as *AutoSnapshotter) Validate(ctx context.Context) error {
+	policy, err := as.snapshotChecker.getSnapshotClassDeletionPolicy(ctx, as.className)
+	if err != nil {
+		return fmt.Errorf("failed to get VolumeSnapshotClass %s: %w", as.className, err)
+	}
+
+	if policy != "Retain" {
+		return fmt.Errorf("VolumeSnapshotClass %s has deletion policy %s, expected Retain", as.className, policy)
+	}
+
+	return nil
+}
+
+// EnsureSnapshots requests a VolumeSnapshot for every risky PVC that does not already have one.
+// PVCs whose snapshots are not accepted as protection (their PV, claim or namespace is
+// labelled enforce) are skipped, since their snapshots would never unblock the deletion.
+func (as *AutoSnapshotter) EnsureSnapshots(ctx context.Context, riskyPVCs []RiskyPVC) []PendingSnapshot {
+	pending := make([]PendingSnapshot, 0, len(riskyPVCs))
+
+	for _, risky := range riskyPVCs {
+		if risky.Namespace == "" || risky.Name == "" || risky.SnapshotState == SnapshotStateNotAccepted {
+			continue
+		}
+
+		snapshot := PendingSnapshot{
+			Name:      autoSnapshotName(risky.Name, risky.PVName),
+			Namespace: risky.Namespace,
+			SourcePVC: risky.Name,
+		}
+		snapshot.Created, snapshot.Error = as.ensureSnapshot(ctx, snapshot)
+		pending = append(pending, snapshot)
+	}
+
+	return pending
+}
+
+// ensureSnapshot creates the named VolumeSnapshot unless it already exists.
+// It reports whether a new snapshot was created by this call.
+func (as *AutoSnapshotter) ensureSnapshot(ctx context.Context, snapshot PendingSnapshot) (bool, error) {
+	client := as.snapshotChecker.dynamicClient.Resource(volumeSnapshotGVR).Namespace(snapshot.Namespace)
+
+	_, err := client.Get(ctx, snapshot.Name, metav1.GetOptions{})
+	if err == nil {
+		return false, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return false, fmt.Errorf("failed to get volumesnapshot %s/%s: %w", snapshot.Namespace, snapshot.Name, err)
+	}
+
+	obj := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "snapshot.storage.k8s.io/v1",
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":      snapshot.Name,
+				"namespace": snapshot.Namespace,
+				"labels": map[string]interface{}{
+					"app.kubernetes.io/managed-by": "pv-safe",
+					AutoSnapshotLabel:              "true",
+					AutoSnapshotSourceLabel:        snapshot.SourcePVC,
+				},
+			},
+			"spec": map[string]interface{}{
+				"volumeSnapshotClassName": as.className,
+				"source": map[string]interface{}{
+					"persistentVolumeClaimName": snapshot.SourcePVC,
+				},
+			},
+		},
+	}
+
+	if _, err := client.Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// Another replica created it concurrently
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to create volumesnapshot %s/%s: %w", snapshot.Namespace, snapshot.Name, err)
+	}
+
+	return true, nil
+}
+
+// autoSnapshotName derives a stable snapshot name from the PVC and its bound PV,
+// so repeated deletion attempts reuse the same snapshot instead of creating new ones.
+func autoSnapshotName(pvcName, pvName string) string {
+	sum := sha256.Sum256([]byte(pvName))
+	suffix := "-" + hex.EncodeToString(sum[:])[:8]
+
+	name := "pv-safe-" + pvcName
+	if len(name)+len(suffix) > maxSnapshotNameLength {
+		name = strings.TrimRight(name[:maxSnapshotNameLength-len(suffix)], "-.")
+	}
+
+	return name + suffix
+}
+
+// buildAutoSnapshotMessage explains which snapshots are in progress for a blocked deletion
+func buildAutoSnapshotMessage(pending []PendingSnapshot) string {
+	if len(pending) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("\nAutomatic snapshot in progress:\n")
+	for _, snapshot := range pending {
+		if snapshot.Error != nil {
+			sb.WriteString(fmt.Sprintf("  - %s/%s: failed to create VolumeSnapshot '%s': %v\n",
+				snapshot.Namespace, snapshot.SourcePVC, snapshot.Name, snapshot.Error))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  - %s/%s: VolumeSnapshot '%s'\n", snapshot.Namespace, snapshot.SourcePVC, snapshot.Name))
+	}
+	retryHeader := "\nRetry the deletion once the snapshot is ready:\n"
+	for _, snapshot := range pending {
+		if snapshot.Error != nil {
+			continue
+		}
+		sb.WriteString(retryHeader)
+		retryHeader = ""
+		sb.WriteString(fmt.Sprintf("     kubectl get volumesnapshot %s -n %s\n", snapshot.Name, snapshot.Namespace))
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newAutoSnapshotClass(name, deletionPolicy string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "snapshot.storage.k8s.io/v1",
+		"kind":           "VolumeSnapshotClass",
+		"metadata":       map[string]interface{}{"name": name},
+		"driver":         "hostpath.csi.k8s.io",
+		"deletionPolicy": deletionPolicy,
+	}}
+}
+
+func newAutoSnapshot(name, pvcName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "app"},
+		"spec": map[string]interface{}{
+			"volumeSnapshotClassName": "retain",
+			"source":                  map[string]interface{}{"persistentVolumeClaimName": pvcName},
+		},
+	}}
+}
+
+func newTestAutoSnapshotter(t *testing.T, objects ...runtime.Object) (*AutoSnapshotter, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{volumeSnapshotGVR: "VolumeSnapshotList"}, objects...)
+	snapshotter, err := NewAutoSnapshotter(&SnapshotChecker{dynamicClient: client}, "retain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshotter, client
+}
+
+func TestNewAutoSnapshotterRequiresCheckerAndClass(t *testing.T) {
+	if _, err := NewAutoSnapshotter(nil, "retain"); err == nil {
+		t.Error("expected an error without snapshot support")
+	}
+	if _, err := NewAutoSnapshotter(&SnapshotChecker{}, ""); err == nil {
+		t.Error("expected an error without a VolumeSnapshotClass")
+	}
+}
+
+func TestAutoSnapshotterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		wantErr string
+	}{
+		{
+			name:    "Retain class",
+			objects: []runtime.Object{newAutoSnapshotClass("retain", "Retain")},
+		},
+		{
+			name:    "Delete class",
+			objects: []runtime.Object{newAutoSnapshotClass("retain", "Delete")},
+			wantErr: "VolumeSnapshotClass retain has deletion policy Delete, expected Retain",
+		},
+		{
+			name:    "missing class",
+			wantErr: "failed to get VolumeSnapshotClass retain",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotter, _ := newTestAutoSnapshotter(t, tt.objects...)
+			err := snapshotter.Validate(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAutoSnapshotterEnsureSnapshots(t *testing.T) {
+	existing := newAutoSnapshot(autoSnapshotName("logs", "pv-logs"), "logs")
+	snapshotter, client := newTestAutoSnapshotter(t, existing)
+	ctx := context.Background()
+
+	pending := snapshotter.EnsureSnapshots(ctx, []RiskyPVC{
+		{Name: "data", Namespace: "app", PVName: "pv-data"},
+		{Name: "logs", Namespace: "app", PVName: "pv-logs"},
+		{Name: "critical", Namespace: "app", PVName: "pv-critical", SnapshotState: SnapshotStateNotAccepted},
+		{Name: "orphan", PVName: "pv-orphan"},
+	})
+
+	if len(pending) != 2 {
+		t.Fatalf("pending = %+v, want snapshots for data and logs only", pending)
+	}
+	if !pending[0].Created || pending[0].Error != nil || pending[0].SourcePVC != "data" {
+		t.Errorf("pending[0] = %+v, want a new snapshot of data", pending[0])
+	}
+	if pending[1].Created || pending[1].Error != nil || pending[1].Name != existing.GetName() {
+		t.Errorf("pending[1] = %+v, want the existing snapshot of logs", pending[1])
+	}
+
+	created, err := client.Resource(volumeSnapshotGVR).Namespace("app").Get(ctx, pending[0].Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := created.GetLabels()
+	if labels[AutoSnapshotLabel] != "true" || labels[AutoSnapshotSourceLabel] != "data" || labels["app.kubernetes.io/managed-by"] != "pv-safe" {
+		t.Errorf("labels = %v", labels)
+	}
+	source, _, _ := unstructured.NestedString(created.Object, "spec", "source", "persistentVolumeClaimName")
+	class, _, _ := unstructured.NestedString(created.Object, "spec", "volumeSnapshotClassName")
+	if source != "data" || class != "retain" {
+		t.Errorf("spec = %v, want a snapshot of data with class retain", created.Object["spec"])
+	}
+
+	// Repeated deletion attempts reuse the snapshot
+	again := snapshotter.EnsureSnapshots(ctx, []RiskyPVC{{Name: "data", Namespace: "app", PVName: "pv-data"}})
+	if len(again) != 1 || again[0].Created || again[0].Name != pending[0].Name {
+		t.Errorf("again = %+v, want the snapshot created before", again)
+	}
+}
+
+func TestAutoSnapshotterEnsureSnapshotRace(t *testing.T) {
+	// Another replica creates the snapshot between our get and create
+	snapshotter, client := newTestAutoSnapshotter(t, newAutoSnapshot(autoSnapshotName("data", "pv-data"), "data"))
+	client.PrependReactor("get", "volumesnapshots", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewNotFound(volumeSnapshotGVR.GroupResource(), action.(k8stesting.GetAction).GetName())
+	})
+
+	pending := snapshotter.EnsureSnapshots(context.Background(), []RiskyPVC{{Name: "data", Namespace: "app", PVName: "pv-data"}})
+	if len(pending) != 1 || pending[0].Created || pending[0].Error != nil {
+		t.Errorf("pending = %+v, want the concurrently created snapshot without an error", pending)
+	}
+}
+
+func TestAutoSnapshotterEnsureSnapshotFailure(t *testing.T) {
+	snapshotter, client := newTestAutoSnapshotter(t)
+	client.PrependReactor("create", "volumesnapshots", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("quota exceeded")
+	})
+
+	pending := snapshotter.EnsureSnapshots(context.Background(), []RiskyPVC{{Name: "data", Namespace: "app", PVName: "pv-data"}})
+	if len(pending) != 1 || pending[0].Created || pending[0].Error == nil ||
+		!strings.Contains(pending[0].Error.Error(), "failed to create volumesnapshot app/pv-safe-data-") {
+		t.Errorf("pending = %+v, want a create error", pending)
+	}
+}
+
+func TestAutoSnapshotName(t *testing.T) {
+	name := autoSnapshotName("data", "pv-data")
+	if !strings.HasPrefix(name, "pv-safe-data-") || len(name) != len("pv-safe-data-")+8 {
+		t.Errorf("name = %q, want pv-safe-data- and an 8 character suffix", name)
+	}
+	if autoSnapshotName("data", "pv-data") != name {
+		t.Error("expected the name to be stable")
+	}
+	// A recreated PVC with a new volume gets a new snapshot
+	if autoSnapshotName("data", "pv-data-2") == name {
+		t.Error("expected different volumes of the same PVC to get different names")
+	}
+
+	long := autoSnapshotName(strings.Repeat("a", 240)+"-"+strings.Repeat("b", 20), "pv-data")
+	if len(long) > maxSnapshotNameLength {
+		t.Errorf("len(name) = %d, want at most %d", len(long), maxSnapshotNameLength)
+	}
+	if strings.Contains(long, "--") || !strings.HasSuffix(long, name[len("pv-safe-data"):]) {
+		t.Errorf("name = %q, want the truncated PVC name and the volume suffix", long)
+	}
+	// Long names differing only after the cut still differ by volume
+	if autoSnapshotName(strings.Repeat("a", 300), "pv-1") == autoSnapshotName(strings.Repeat("a", 300), "pv-2") {
+		t.Error("expected truncated names of different volumes to differ")
+	}
+}
+
+func TestBuildAutoSnapshotMessage(t *testing.T) {
+	if message := buildAutoSnapshotMessage(nil); message != "" {
+		t.Errorf("message = %q, want none without snapshots", message)
+	}
+
+	message := buildAutoSnapshotMessage([]PendingSnapshot{
+		{Name: "pv-safe-data-1234abcd", Namespace: "app", SourcePVC: "data", Created: true},
+		{Name: "pv-safe-logs-1234abcd", Namespace: "app", SourcePVC: "logs", Error: errors.New("quota exceeded")},
+	})
+	want := "\nAutomatic snapshot in progress:\n" +
+		"  - app/data: VolumeSnapshot 'pv-safe-data-1234abcd'\n" +
+		"  - app/logs: failed to create VolumeSnapshot 'pv-safe-logs-1234abcd': quota exceeded\n" +
+		"\nRetry the deletion once the snapshot is ready:\n" +
+		"     kubectl get volumesnapshot pv-safe-data-1234abcd -n app\n"
+	if message != want {
+		t.Errorf("message = %q, want %q", message, want)
+	}
+
+	failed := buildAutoSnapshotMessage([]PendingSnapshot{{Name: "pv-safe-logs-1234abcd", Namespace: "app", SourcePVC: "logs", Error: errors.New("quota exceeded")}})
+	if strings.Contains(failed, "Retry the deletion") {
+		t.Errorf("message = %q, want no retry instructions when every snapshot failed", failed)
+	}
+}
+
+func TestHandlerRequestSnapshots(t *testing.T) {
+	risky := &RiskAssessment{IsRisky: true, RiskyPVCs: []RiskyPVC{{Name: "data", Namespace: "app", PVName: "pv-data"}}}
+
+	tests := []struct {
+		name        string
+		kind        string
+		dryRun      bool
+		wantCreated bool
+	}{
+		{name: "blocked PVC deletion", kind: "PersistentVolumeClaim", wantCreated: true},
+		{name: "blocked Namespace deletion", kind: "Namespace", wantCreated: true},
+		{name: "dry run", kind: "PersistentVolumeClaim", dryRun: true},
+		{name: "PV deletion", kind: "PersistentVolume"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshotter, client := newTestAutoSnapshotter(t)
+			handler := &Handler{Logger: log.New(io.Discard, "", 0), AutoSnapshotter: snapshotter}
+
+			message := handler.requestSnapshots(context.Background(), &admissionv1.AdmissionRequest{
+				Kind:   metav1.GroupVersionKind{Version: "v1", Kind: tt.kind},
+				DryRun: &tt.dryRun,
+			}, risky)
+
+			snapshots, err := client.Resource(volumeSnapshotGVR).Namespace("app").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			mentioned := strings.Contains(message, "Automatic snapshot in progress")
+			if created := len(snapshots.Items) == 1; created != tt.wantCreated || mentioned != tt.wantCreated {
+				t.Errorf("created = %v, mentioned = %v, want %v (%q)", created, mentioned, tt.wantCreated, message)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/dynamic"
+)
+
+// BackupProvider finds backup evidence for a PVC outside of CSI VolumeSnapshots
+type BackupProvider interface {
+	// Name returns the provider name used in messages and logs
+	Name() string
+
+	// FindBackup returns a backup that protects the PVC's data, or nil if there is none
+	FindBackup(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*BackupInfo, error)
+}
+
+// BackupInfo contains information about a backup that protects a PVC
+type BackupInfo struct {
+	Provider       string
+	Name           string
+	Namespace      string
+	CompletionTime time.Time
+	Expiration     time.Time
+}
+
+// NewBackupProviders creates the named backup providers
+func NewBackupProviders(names []string, dynamicClient dynamic.Interface, veleroNamespace string, maxAge time.Duration) ([]BackupProvider, error) {
+	var providers []BackupProvider
+
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case VeleroProviderName:
+			providers = append(providers, NewVeleroProvider(dynamicClient, veleroNamespace, maxAge))
+		default:
+			return nil, fmt.Errorf("unknown backup provider %q", name)
+		}
+	}
+
+	return providers, nil
+}
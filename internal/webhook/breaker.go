@@ -0,0 +1,436 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// ReasonCircuitBreakerOpen is the status cause type of a deletion denied by a tripped
+	// circuit breaker
+	ReasonCircuitBreakerOpen = "CircuitBreakerOpen"
+
+	// BreakerLimitUser and BreakerLimitCluster name the limit that tripped the breaker
+	BreakerLimitUser    = "user"
+	BreakerLimitCluster = "cluster"
+
+	// DefaultBreakerSyncInterval is how often the shared trip state is re-read, so that
+	// trips by other replicas and resets take effect
+	DefaultBreakerSyncInterval = 5 * time.Second
+)
+
+var (
+	circuitBreakerTripped = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "pv_safe_circuit_breaker_tripped",
+		Help: "1 while the mass-deletion circuit breaker is tripped and deletions are denied, 0 otherwise.",
+	})
+	circuitBreakerTrips = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "pv_safe_circuit_breaker_trips_total",
+		Help: "Number of times this replica tripped the mass-deletion circuit breaker, by the limit exceeded.",
+	}, []string{"limit"})
+	circuitBreakerDenials = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "pv_safe_circuit_breaker_denied_deletions_total",
+		Help: "Number of deletions denied because the mass-deletion circuit breaker was tripped.",
+	})
+)
+
+// breakerKinds are the deletions counted and blocked by the circuit breaker
+var breakerKinds = map[string]bool{
+	"Namespace":             true,
+	"PersistentVolumeClaim": true,
+	"PersistentVolume":      true,
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker
+type CircuitBreakerOptions struct {
+	// Window is the sliding window deletions are counted in
+	Window time.Duration
+	// UserLimit and ClusterLimit are the deletions allowed within Window for one user and
+	// in total; zero disables a limit. Each replica counts the deletions it admits.
+	UserLimit    int
+	ClusterLimit int
+	// Namespace and ConfigMapName locate the ConfigMap recording a trip for all replicas;
+	// deleting it resets the breaker
+	Namespace     string
+	ConfigMapName string
+	// Identity identifies this replica in the trip record and events
+	Identity string
+}
+
+// BreakerTrip records why the circuit breaker tripped
+type BreakerTrip struct {
+	// Limit is BreakerLimitUser or BreakerLimitCluster
+	Limit string
+	// User made the deletion that exceeded the limit
+	User      string
+	Count     int
+	Window    time.Duration
+	TrippedAt time.Time
+	Replica   string
+}
+
+// String describes the trip, e.g. "21 deletions by alice within 1m0s"
+func (t BreakerTrip) String() string {
+	if t.Limit == BreakerLimitUser {
+		return fmt.Sprintf("%d deletions by %s within %s", t.Count, t.User, t.Window)
+	}
+	return fmt.Sprintf("%d deletions within %s across all users (last by %s)", t.Count, t.Window, t.User)
+}
+
+// CircuitBreaker stops mass deletions. It counts the PVC, PV and Namespace deletions the
+// webhook allows in a sliding window, per user and in total; once a limit is exceeded it
+// trips and every further deletion is denied, safe or not, until an operator deletes the
+// ConfigMap recording the trip. The trip is shared with the other replicas through that
+// ConfigMap; the counts are per replica.
+//
+// Decisions only use the in-memory state. API calls are made outside the mutex, by a
+// single request per SyncInterval or by the request that trips the breaker, so a slow API
+// server never queues the other deletions behind them.
+type CircuitBreaker struct {
+	client  kubernetes.Interface
+	logger  *log.Logger
+	options CircuitBreakerOptions
+
+	// SyncInterval is how often the ConfigMap is re-read
+	SyncInterval time.Duration
+
+	mu               sync.Mutex
+	userDeletions    map[string][]time.Time
+	clusterDeletions []time.Time
+	trip             *BreakerTrip
+	// persisted is false while a local trip could not be written to the ConfigMap yet
+	persisted bool
+	syncedAt  time.Time
+
+	// now is the clock used for the sliding window; replaced in tests
+	now func() time.Time
+}
+
+// NewCircuitBreaker creates a circuit breaker; the trip state is read on first use
+func NewCircuitBreaker(client kubernetes.Interface, logger *log.Logger, options CircuitBreakerOptions) *CircuitBreaker {
+	return &CircuitBreaker{
+		client:        client,
+		logger:        logger,
+		options:       options,
+		SyncInterval:  DefaultBreakerSyncInterval,
+		userDeletions: map[string][]time.Time{},
+		now:           time.Now,
+	}
+}
+
+// Admit decides whether a deletion that would otherwise be allowed may proceed. It
+// returns the trip when the breaker is tripped, including by this deletion; otherwise the
+// deletion is counted, unless it is a dry run.
+func (b *CircuitBreaker) Admit(ctx context.Context, user string, dryRun bool) *BreakerTrip {
+	b.refresh(ctx)
+
+	b.mu.Lock()
+	trip, tripped := b.decide(user, dryRun)
+	b.mu.Unlock()
+
+	if tripped {
+		circuitBreakerTripped.Set(1)
+		circuitBreakerTrips.WithLabelValues(trip.Limit).Inc()
+		b.logger.Printf("CIRCUIT BREAKER TRIPPED: %s; denying all PVC, PV and Namespace deletions until reset with: %s",
+			trip, b.ResetCommand())
+		b.record(ctx, trip)
+	}
+	if trip == nil {
+		return nil
+	}
+	result := *trip
+	return &result
+}
+
+// decide counts a deletion against the limits and returns the current trip, reporting
+// whether this deletion tripped the breaker. The caller holds b.mu.
+func (b *CircuitBreaker) decide(user string, dryRun bool) (*BreakerTrip, bool) {
+	if b.trip != nil {
+		return b.trip, false
+	}
+	if dryRun {
+		return nil, false
+	}
+
+	now := b.now()
+	b.prune(now)
+	userCount := len(b.userDeletions[user]) + 1
+	clusterCount := len(b.clusterDeletions) + 1
+
+	var trip *BreakerTrip
+	switch {
+	case b.options.UserLimit > 0 && userCount > b.options.UserLimit:
+		trip = &BreakerTrip{Limit: BreakerLimitUser, Count: userCount}
+	case b.options.ClusterLimit > 0 && clusterCount > b.options.ClusterLimit:
+		trip = &BreakerTrip{Limit: BreakerLimitCluster, Count: clusterCount}
+	default:
+		b.userDeletions[user] = append(b.userDeletions[user], now)
+		b.clusterDeletions = append(b.clusterDeletions, now)
+		return nil, false
+	}
+
+	trip.User = user
+	trip.Window = b.options.Window
+	trip.TrippedAt = now
+	trip.Replica = b.options.Identity
+	b.trip = trip
+	b.persisted = false
+	return trip, true
+}
+
+// Tripped returns the current trip, or nil when deletions are admitted
+func (b *CircuitBreaker) Tripped(ctx context.Context) *BreakerTrip {
+	b.refresh(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.trip == nil {
+		return nil
+	}
+	trip := *b.trip
+	return &trip
+}
+
+// ResetCommand is the command an operator runs to reset the breaker
+func (b *CircuitBreaker) ResetCommand() string {
+	return fmt.Sprintf("kubectl delete configmap %s -n %s", b.options.ConfigMapName, b.options.Namespace)
+}
+
+// prune drops deletions that left the sliding window
+func (b *CircuitBreaker) prune(now time.Time) {
+	cutoff := now.Add(-b.options.Window)
+	keep := func(times []time.Time) []time.Time {
+		i := 0
+		for i < len(times) && !times[i].After(cutoff) {
+			i++
+		}
+		return times[i:]
+	}
+
+	for user, times := range b.userDeletions {
+		if times = keep(times); len(times) == 0 {
+			delete(b.userDeletions, user)
+		} else {
+			b.userDeletions[user] = times
+		}
+	}
+	b.clusterDeletions = keep(b.clusterDeletions)
+}
+
+// record writes a local trip to the ConfigMap and emits an event for it. It must be
+// called without holding b.mu.
+func (b *CircuitBreaker) record(ctx context.Context, trip *BreakerTrip) {
+	configMap, err := b.persist(ctx, trip)
+	if err != nil {
+		b.logger.Printf("ERROR: Failed to record circuit breaker trip in ConfigMap %s/%s (retrying on next sync): %v",
+			b.options.Namespace, b.options.ConfigMapName, err)
+		return
+	}
+	if configMap != nil {
+		b.emitEvent(ctx, configMap, trip)
+	}
+}
+
+// persist writes a local trip to the ConfigMap. If another replica recorded a trip
+// first, that trip is adopted instead and no ConfigMap is returned, since this replica
+// did not trip the breaker.
+func (b *CircuitBreaker) persist(ctx context.Context, trip *BreakerTrip) (*corev1.ConfigMap, error) {
+	configMaps := b.client.CoreV1().ConfigMaps(b.options.Namespace)
+	desired := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      b.options.ConfigMapName,
+			Namespace: b.options.Namespace,
+			Labels:    map[string]string{"app.kubernetes.io/name": "pv-safe"},
+		},
+		Data: tripData(trip),
+	}
+
+	created, err := configMaps.Create(ctx, desired, metav1.CreateOptions{})
+	if err == nil {
+		b.markPersisted(trip, nil)
+		return created, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return nil, err
+	}
+
+	existing, err := configMaps.Get(ctx, b.options.ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if recorded := tripFromData(existing.Data); recorded != nil {
+		b.markPersisted(trip, recorded)
+		return nil, nil
+	}
+
+	existing.Data = desired.Data
+	updated, err := configMaps.Update(ctx, existing, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	b.markPersisted(trip, nil)
+	return updated, nil
+}
+
+// markPersisted records that a local trip is in the ConfigMap, adopting the trip recorded
+// there by another replica if any. It does nothing when the breaker was reset meanwhile.
+func (b *CircuitBreaker) markPersisted(local, recorded *BreakerTrip) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.trip != local {
+		return
+	}
+	if recorded != nil {
+		b.trip = recorded
+	}
+	b.persisted = true
+}
+
+// refresh re-reads the ConfigMap at most every SyncInterval to pick up trips by other
+// replicas and resets, or retries recording a local trip. Only the request that finds the
+// sync due reads the ConfigMap; the others use the last known state meanwhile. When the
+// ConfigMap cannot be read the last known state is kept.
+func (b *CircuitBreaker) refresh(ctx context.Context) {
+	b.mu.Lock()
+	now := b.now()
+	if !b.syncedAt.IsZero() && now.Sub(b.syncedAt) < b.SyncInterval {
+		b.mu.Unlock()
+		return
+	}
+	b.syncedAt = now
+	local, persisted := b.trip, b.persisted
+	b.mu.Unlock()
+
+	if local != nil && !persisted {
+		b.record(ctx, local)
+		return
+	}
+
+	configMap, err := b.client.CoreV1().ConfigMaps(b.options.Namespace).Get(ctx, b.options.ConfigMapName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		b.logger.Printf("Warning: Failed to read circuit breaker ConfigMap %s/%s: %v", b.options.Namespace, b.options.ConfigMapName, err)
+		return
+	}
+
+	var trip *BreakerTrip
+	if err == nil {
+		trip = tripFromData(configMap.Data)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.trip != local {
+		// This replica tripped while the ConfigMap was read; the read is stale
+		return
+	}
+
+	switch {
+	case trip == nil && b.trip != nil:
+		b.logger.Printf("Circuit breaker reset: admitting deletions again")
+		b.trip = nil
+		b.userDeletions = map[string][]time.Time{}
+		b.clusterDeletions = nil
+		circuitBreakerTripped.Set(0)
+	case trip != nil && b.trip == nil:
+		b.logger.Printf("CIRCUIT BREAKER TRIPPED by replica %s: %s", trip.Replica, trip)
+		b.trip = trip
+		b.persisted = true
+		circuitBreakerTripped.Set(1)
+	case trip == nil:
+		circuitBreakerTripped.Set(0)
+	}
+}
+
+// emitEvent records a Warning event on the ConfigMap so the trip shows up in
+// `kubectl get events`. Failures are only logged.
+func (b *CircuitBreaker) emitEvent(ctx context.Context, configMap *corev1.ConfigMap, trip *BreakerTrip) {
+	now := metav1.NewTime(b.now())
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s.%x", configMap.Name, now.UnixNano()),
+			Namespace: configMap.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Namespace:  configMap.Namespace,
+			Name:       configMap.Name,
+			UID:        configMap.UID,
+		},
+		Reason: "CircuitBreakerTripped",
+		Message: fmt.Sprintf("Mass-deletion circuit breaker tripped: %s. All PVC, PV and Namespace deletions are denied until reset with: %s",
+			trip, b.ResetCommand()),
+		Source:              corev1.EventSource{Component: "pv-safe-webhook", Host: b.options.Identity},
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+		Type:                corev1.EventTypeWarning,
+		ReportingController: "pv-safe.io/webhook",
+		ReportingInstance:   b.options.Identity,
+	}
+
+	if _, err := b.client.CoreV1().Events(configMap.Namespace).Create(ctx, event, metav1.CreateOptions{}); err != nil {
+		b.logger.Printf("Warning: Failed to create circuit breaker event: %v", err)
+	}
+}
+
+// tripData serializes a trip into ConfigMap data
+func tripData(trip *BreakerTrip) map[string]string {
+	return map[string]string{
+		"tripped":   "true",
+		"limit":     trip.Limit,
+		"user":      trip.User,
+		"count":     strconv.Itoa(trip.Count),
+		"window":    trip.Window.String(),
+		"trippedAt": trip.TrippedAt.UTC().Format(time.RFC3339),
+		"replica":   trip.Replica,
+		"reason":    trip.String(),
+	}
+}
+
+// tripFromData reads a trip from ConfigMap data; data without tripped=true is no trip.
+// Unparsable details are left empty rather than ignoring the trip.
+func tripFromData(data map[string]string) *BreakerTrip {
+	if !strings.EqualFold(data["tripped"], "true") {
+		return nil
+	}
+
+	trip := &BreakerTrip{Limit: data["limit"], User: data["user"], Replica: data["replica"]}
+	trip.Count, _ = strconv.Atoi(data["count"])
+	trip.Window, _ = time.ParseDuration(data["window"])
+	trip.TrippedAt, _ = time.Parse(time.RFC3339, data["trippedAt"])
+	return trip
+}
+
+// buildBreakerMessage creates the denial message for a deletion refused by a tripped breaker
+func (b *CircuitBreaker) buildBreakerMessage(kind, namespace, name string, trip *BreakerTrip) string {
+	var sb strings.Builder
+
+	object := DeletionLock{Namespace: namespace, Name: name}.field()
+	sb.WriteString(fmt.Sprintf("MASS DELETION CIRCUIT BREAKER TRIPPED: %s '%s' cannot be deleted\n\n", kind, object))
+	sb.WriteString(fmt.Sprintf("Reason: %s", trip))
+	if !trip.TrippedAt.IsZero() {
+		sb.WriteString(fmt.Sprintf(" (tripped at %s", trip.TrippedAt.UTC().Format(time.RFC3339)))
+		if trip.Replica != "" {
+			sb.WriteString(" by " + trip.Replica)
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString("\n\nAll PVC, PV and Namespace deletions are denied, even safe ones, whatever their labels.\n")
+	sb.WriteString(fmt.Sprintf("After checking that no script is deleting volumes by mistake, an operator can reset the breaker:\n  %s\n", b.ResetCommand()))
+
+	return sb.String()
+}
@@ -0,0 +1,237 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var breakerTestNow = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+// newTestBreaker returns a breaker allowing 3 deletions per user and 5 in total per minute,
+// with a clock advanced by the returned function
+func newTestBreaker(client *fake.Clientset) (*CircuitBreaker, func(time.Duration)) {
+	breaker := NewCircuitBreaker(client, log.New(io.Discard, "", 0), CircuitBreakerOptions{
+		Window:        time.Minute,
+		UserLimit:     3,
+		ClusterLimit:  5,
+		Namespace:     "pv-safe-system",
+		ConfigMapName: "pv-safe-circuit-breaker",
+		Identity:      "pv-safe-0",
+	})
+	now := breakerTestNow
+	breaker.now = func() time.Time { return now }
+	return breaker, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCircuitBreakerUserLimit(t *testing.T) {
+	client := fake.NewClientset()
+	breaker, advance := newTestBreaker(client)
+	ctx := context.Background()
+
+	for i := range 3 {
+		if trip := breaker.Admit(ctx, "alice", false); trip != nil {
+			t.Fatalf("deletion %d tripped the breaker: %s", i+1, trip)
+		}
+		advance(time.Second)
+	}
+
+	trip := breaker.Admit(ctx, "alice", false)
+	if trip == nil || trip.Limit != BreakerLimitUser || trip.Count != 4 || trip.User != "alice" {
+		t.Fatalf("trip = %+v, want alice's 4th deletion to trip the user limit", trip)
+	}
+	if trip.String() != "4 deletions by alice within 1m0s" {
+		t.Errorf("trip = %q", trip)
+	}
+
+	// Every user is blocked now, and the trip is recorded for the other replicas
+	if breaker.Admit(ctx, "bob", false) == nil {
+		t.Error("expected bob's deletion to be denied by the tripped breaker")
+	}
+	configMap, err := client.CoreV1().ConfigMaps("pv-safe-system").Get(ctx, "pv-safe-circuit-breaker", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if configMap.Data["tripped"] != "true" || configMap.Data["user"] != "alice" || configMap.Data["replica"] != "pv-safe-0" {
+		t.Errorf("ConfigMap data = %v", configMap.Data)
+	}
+	events, err := client.CoreV1().Events("pv-safe-system").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events.Items) != 1 || events.Items[0].Type != corev1.EventTypeWarning || events.Items[0].Reason != "CircuitBreakerTripped" ||
+		!strings.Contains(events.Items[0].Message, "kubectl delete configmap pv-safe-circuit-breaker -n pv-safe-system") {
+		t.Errorf("events = %+v, want one CircuitBreakerTripped warning naming the reset command", events.Items)
+	}
+
+	// Time alone does not reset the breaker; deleting the ConfigMap does, with fresh counts
+	advance(time.Hour)
+	if breaker.Admit(ctx, "carol", false) == nil {
+		t.Error("expected the breaker to stay tripped until reset")
+	}
+	if err := client.CoreV1().ConfigMaps("pv-safe-system").Delete(ctx, "pv-safe-circuit-breaker", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	advance(DefaultBreakerSyncInterval)
+	for i := range 3 {
+		if trip := breaker.Admit(ctx, "alice", false); trip != nil {
+			t.Fatalf("deletion %d after the reset was denied: %s", i+1, trip)
+		}
+	}
+}
+
+func TestCircuitBreakerClusterLimitAndWindow(t *testing.T) {
+	breaker, advance := newTestBreaker(fake.NewClientset())
+	ctx := context.Background()
+
+	for _, user := range []string{"alice", "bob", "carol", "dave"} {
+		if trip := breaker.Admit(ctx, user, false); trip != nil {
+			t.Fatalf("%s tripped the breaker: %s", user, trip)
+		}
+	}
+	// Dry runs are checked but not counted
+	for range 5 {
+		if trip := breaker.Admit(ctx, "erin", true); trip != nil {
+			t.Fatalf("dry run tripped the breaker: %s", trip)
+		}
+	}
+
+	// Deletions older than the window no longer count
+	advance(time.Minute)
+	for _, user := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		if trip := breaker.Admit(ctx, user, false); trip != nil {
+			t.Fatalf("%s tripped the breaker after the window slid: %s", user, trip)
+		}
+	}
+
+	trip := breaker.Admit(ctx, "frank", false)
+	if trip == nil || trip.Limit != BreakerLimitCluster || trip.Count != 6 {
+		t.Fatalf("trip = %+v, want the 6th deletion to trip the cluster limit", trip)
+	}
+}
+
+func TestCircuitBreakerTrippedByOtherReplica(t *testing.T) {
+	client := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-safe-circuit-breaker", Namespace: "pv-safe-system"},
+		Data: tripData(&BreakerTrip{
+			Limit: BreakerLimitCluster, User: "ci-bot", Count: 51, Window: time.Minute,
+			TrippedAt: breakerTestNow.Add(-time.Minute), Replica: "pv-safe-1",
+		}),
+	})
+	breaker, _ := newTestBreaker(client)
+
+	trip := breaker.Admit(context.Background(), "alice", false)
+	if trip == nil || trip.Replica != "pv-safe-1" || trip.Count != 51 {
+		t.Fatalf("trip = %+v, want the trip recorded by pv-safe-1", trip)
+	}
+	if events, _ := client.CoreV1().Events("pv-safe-system").List(context.Background(), metav1.ListOptions{}); len(events.Items) != 0 {
+		t.Errorf("events = %+v, want none for a trip observed from another replica", events.Items)
+	}
+}
+
+func TestCircuitBreakerSlowAPIServerDoesNotBlockAdmission(t *testing.T) {
+	client := fake.NewClientset()
+	release := make(chan struct{})
+	client.PrependReactor("get", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		<-release
+		return false, nil, nil
+	})
+	breaker, _ := newTestBreaker(client)
+	ctx := context.Background()
+
+	// The first admission reads the ConfigMap and hangs on the API server
+	syncing := make(chan *BreakerTrip)
+	go func() { syncing <- breaker.Admit(ctx, "alice", false) }()
+	for {
+		breaker.mu.Lock()
+		started := !breaker.syncedAt.IsZero()
+		breaker.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Meanwhile the other admissions are decided from the in-memory state
+	done := make(chan *BreakerTrip)
+	go func() { done <- breaker.Admit(ctx, "bob", false) }()
+	select {
+	case trip := <-done:
+		if trip != nil {
+			t.Errorf("bob's deletion was denied: %s", trip)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("admission waited for the ConfigMap read of another request")
+	}
+
+	close(release)
+	if trip := <-syncing; trip != nil {
+		t.Errorf("alice's deletion was denied: %s", trip)
+	}
+}
+
+func TestHandlerCircuitBreaker(t *testing.T) {
+	safe := newNamespacePVC("logs", corev1.ClaimBound, "pv-logs")
+	risky := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	client := fake.NewClientset(safe, risky,
+		newPhasedPV("pv-logs", corev1.PersistentVolumeReclaimRetain, corev1.VolumeBound, nil),
+		newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil))
+
+	handler := NewHandler(log.New(io.Discard, "", 0), client, nil)
+	handler.CircuitBreaker, _ = newTestBreaker(client)
+
+	review := func(pvc *corev1.PersistentVolumeClaim) *admissionv1.AdmissionResponse {
+		return serveReview(t, handler, &admissionv1.AdmissionRequest{
+			UID:       "uid-1",
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+			Operation: admissionv1.Delete,
+			Namespace: "app",
+			Name:      pvc.Name,
+			UserInfo:  authenticationv1.UserInfo{Username: "alice"},
+			OldObject: rawPVC(t, pvc),
+		})
+	}
+
+	// Blocked deletions are not counted
+	for range 5 {
+		if review(risky).Allowed {
+			t.Fatal("expected the risky deletion to be blocked")
+		}
+	}
+	for i := range 3 {
+		if response := review(safe); !response.Allowed {
+			t.Fatalf("safe deletion %d was denied: %s", i+1, response.Result.Message)
+		}
+	}
+
+	response := review(safe)
+	if response.Allowed {
+		t.Fatal("expected the 4th safe deletion to trip the breaker")
+	}
+	for _, want := range []string{
+		"MASS DELETION CIRCUIT BREAKER TRIPPED: PersistentVolumeClaim 'app/logs' cannot be deleted",
+		"Reason: 4 deletions by alice within 1m0s (tripped at 2026-10-18T12:00:00Z by pv-safe-0)",
+		"kubectl delete configmap pv-safe-circuit-breaker -n pv-safe-system",
+	} {
+		if !strings.Contains(response.Result.Message, want) {
+			t.Errorf("message = %q, want it to contain %q", response.Result.Message, want)
+		}
+	}
+	if strings.Contains(response.Result.Message, BypassLabel) {
+		t.Errorf("message = %q, want the bypass label left unnamed", response.Result.Message)
+	}
+	if causes := response.Result.Details.Causes; len(causes) != 1 || causes[0].Type != ReasonCircuitBreakerOpen {
+		t.Errorf("causes = %+v, want one CircuitBreakerOpen cause", causes)
+	}
+}
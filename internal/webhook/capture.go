@@ -0,0 +1,279 @@
+package webhook
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// lastAppliedAnnotation holds a full copy of the object written by kubectl apply
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+	// captureQueueSize is the number of reviews waiting to be written before new ones are dropped
+	captureQueueSize = 1024
+)
+
+var capturesDropped = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "pv_safe_capture_dropped_total",
+	Help: "Number of admission reviews not captured because the capture writer fell behind.",
+})
+
+// CaptureRecord is one admission review as captured by a Recorder: the redacted request
+// and the response that was sent back to the API server
+type CaptureRecord struct {
+	Time     time.Time                    `json:"time"`
+	Request  *admissionv1.AdmissionReview `json:"request"`
+	Response *admissionv1.AdmissionReview `json:"response"`
+}
+
+// Recorder appends captured admission reviews as JSON lines to a file. When the file
+// would grow beyond MaxSize it is rotated to path.1, path.1 to path.2 and so on, keeping
+// at most MaxFiles rotated files. It is safe for concurrent use.
+//
+// Reviews are queued and written by a background goroutine, so redaction and disk writes
+// never delay admission responses. When the queue is full, reviews are dropped.
+type Recorder struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+	logger   *log.Logger
+
+	mu     sync.Mutex
+	queue  chan CaptureRecord
+	closed bool
+	done   chan struct{}
+
+	// file and size are only used by the writer goroutine
+	file *os.File
+	size int64
+	now  func() time.Time
+}
+
+// NewRecorder opens (or creates) the capture file for appending and starts the writer;
+// write errors are logged to logger
+func NewRecorder(path string, maxSize int64, maxFiles int, logger *log.Logger) (*Recorder, error) {
+	r := &Recorder{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		logger:   logger,
+		queue:    make(chan CaptureRecord, captureQueueSize),
+		done:     make(chan struct{}),
+		now:      time.Now,
+	}
+
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	go r.run()
+
+	return r, nil
+}
+
+// Record queues a review and its response to be redacted and appended to the capture
+// file. It never blocks: when the writer has fallen behind, the review is dropped.
+func (r *Recorder) Record(request, response *admissionv1.AdmissionReview) error {
+	record := CaptureRecord{Time: r.now().UTC(), Request: request, Response: response}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return errors.New("capture file is closed")
+	}
+	select {
+	case r.queue <- record:
+		return nil
+	default:
+		capturesDropped.Inc()
+		return errors.New("capture queue is full, dropping review")
+	}
+}
+
+// Close writes the queued reviews and closes the capture file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.queue)
+	}
+	r.mu.Unlock()
+
+	<-r.done
+	return r.file.Close()
+}
+
+// run writes queued reviews until the queue is closed
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	for record := range r.queue {
+		if err := r.write(record); err != nil {
+			r.logger.Printf("Warning: Failed to capture admission review: %v", err)
+		}
+	}
+}
+
+// write redacts a review and appends it to the capture file, rotating it first if needed
+func (r *Recorder) write(record CaptureRecord) error {
+	record.Request = RedactReview(record.Request)
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal capture record: %w", err)
+	}
+	line = append(line, '\n')
+
+	if r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := r.file.Write(line)
+	r.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write capture file %s: %w", r.path, err)
+	}
+
+	return nil
+}
+
+// open opens the capture file for appending and records its current size
+func (r *Recorder) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600) //nolint:gosec // G304: path comes from a trusted flag
+	if err != nil {
+		return fmt.Errorf("failed to open capture file %s: %w", r.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to stat capture file %s: %w", r.path, err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// rotate shifts the rotated files up by one, dropping the oldest, and starts a new file
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close capture file %s: %w", r.path, err)
+	}
+
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(rotatedPath(r.path, i), rotatedPath(r.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate capture file: %w", err)
+		}
+	}
+
+	if r.maxFiles > 0 {
+		if err := os.Rename(r.path, rotatedPath(r.path, 1)); err != nil {
+			return fmt.Errorf("failed to rotate capture file: %w", err)
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return fmt.Errorf("failed to truncate capture file: %w", err)
+	}
+
+	return r.open()
+}
+
+// rotatedPath returns the name of the n-th rotated capture file
+func rotatedPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// ReadCaptures reads the records of a capture file
+func ReadCaptures(in io.Reader) ([]CaptureRecord, error) {
+	var records []CaptureRecord
+
+	scanner := bufio.NewScanner(in)
+	// Namespaced objects carry labels and annotations, so lines can be long
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("line %d: invalid capture record: %w", line, err)
+		}
+		if record.Request == nil || record.Request.Request == nil {
+			return nil, fmt.Errorf("line %d: capture record has no admission request", line)
+		}
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read captures: %w", err)
+	}
+
+	return records, nil
+}
+
+// RedactReview returns a copy of an admission review without object data. Only the
+// identity and metadata the webhook decides on are kept, and extra user information,
+// which can carry credential identifiers, is dropped.
+func RedactReview(review *admissionv1.AdmissionReview) *admissionv1.AdmissionReview {
+	if review == nil || review.Request == nil {
+		return review
+	}
+
+	redacted := *review
+	request := *review.Request
+	request.Object = redactObject(request.Object)
+	request.OldObject = redactObject(request.OldObject)
+	request.UserInfo.Extra = nil
+	redacted.Request = &request
+
+	return &redacted
+}
+
+// redactObject keeps the type and the name, namespace, UID, labels and annotations of
+// an object, and drops everything else including spec, status and data
+func redactObject(object runtime.RawExtension) runtime.RawExtension {
+	if object.Raw == nil {
+		return runtime.RawExtension{}
+	}
+
+	var obj unstructured.Unstructured
+	if err := json.Unmarshal(object.Raw, &obj); err != nil {
+		return runtime.RawExtension{}
+	}
+
+	var kept unstructured.Unstructured
+	kept.SetAPIVersion(obj.GetAPIVersion())
+	kept.SetKind(obj.GetKind())
+	kept.SetName(obj.GetName())
+	kept.SetNamespace(obj.GetNamespace())
+	kept.SetUID(obj.GetUID())
+	kept.SetCreationTimestamp(obj.GetCreationTimestamp())
+	kept.SetLabels(obj.GetLabels())
+
+	if annotations := obj.GetAnnotations(); len(annotations) > 0 {
+		delete(annotations, lastAppliedAnnotation)
+		kept.SetAnnotations(annotations)
+	}
+
+	raw, err := json.Marshal(&kept)
+	if err != nil {
+		return runtime.RawExtension{}
+	}
+
+	return runtime.RawExtension{Raw: raw}
+}
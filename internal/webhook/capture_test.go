@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newCaptureReview creates a PVC deletion review whose old object carries a spec
+func newCaptureReview(t *testing.T, uid string) *admissionv1.AdmissionReview {
+	t.Helper()
+
+	raw, err := json.Marshal(&corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "data",
+			Namespace: "app",
+			Labels:    map[string]string{BypassLabel: "true"},
+			Annotations: map[string]string{
+				lastAppliedAnnotation: `{"spec":{"volumeName":"pv-secret"}}`,
+				"team":                "storage",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{VolumeName: "pv-secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID(uid),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+			Operation: admissionv1.Delete,
+			Namespace: "app",
+			Name:      "data",
+			UserInfo: authenticationv1.UserInfo{
+				Username: "alice",
+				Extra:    map[string]authenticationv1.ExtraValue{"credential-id": {"token-123"}},
+			},
+			OldObject: runtime.RawExtension{Raw: raw},
+		},
+	}
+}
+
+func TestRedactReview(t *testing.T) {
+	review := newCaptureReview(t, "uid-1")
+	original := string(review.Request.OldObject.Raw)
+
+	redacted := RedactReview(review)
+
+	if string(review.Request.OldObject.Raw) != original || review.Request.UserInfo.Extra == nil {
+		t.Fatal("RedactReview modified the original review")
+	}
+	if redacted.Request.UserInfo.Extra != nil {
+		t.Errorf("user extra = %v, want dropped", redacted.Request.UserInfo.Extra)
+	}
+	if redacted.Request.Object.Raw != nil {
+		t.Errorf("object = %s, want empty", redacted.Request.Object.Raw)
+	}
+
+	raw := string(redacted.Request.OldObject.Raw)
+	for _, dropped := range []string{"pv-secret", "spec", lastAppliedAnnotation} {
+		if strings.Contains(raw, dropped) {
+			t.Errorf("redacted object %s still contains %q", raw, dropped)
+		}
+	}
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := json.Unmarshal(redacted.Request.OldObject.Raw, &pvc); err != nil {
+		t.Fatal(err)
+	}
+	if pvc.Kind != "PersistentVolumeClaim" || pvc.Name != "data" || pvc.Namespace != "app" {
+		t.Errorf("redacted identity = %s %s/%s", pvc.Kind, pvc.Namespace, pvc.Name)
+	}
+	if pvc.Labels[BypassLabel] != "true" || pvc.Annotations["team"] != "storage" {
+		t.Errorf("redacted labels = %v, annotations = %v, want them kept", pvc.Labels, pvc.Annotations)
+	}
+}
+
+func TestRecorderRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "captures.jsonl")
+	response := &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: true}}
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	line, err := json.Marshal(CaptureRecord{Time: now, Request: RedactReview(newCaptureReview(t, "uid-0")), Response: response})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Room for two records per file
+	recorder, err := NewRecorder(path, int64(2*(len(line)+1)+10), 2, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.now = func() time.Time { return now }
+	for _, uid := range []string{"uid-1", "uid-2", "uid-3", "uid-4", "uid-5", "uid-6", "uid-7"} {
+		if err := recorder.Record(newCaptureReview(t, uid), response); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		path:        {"uid-7"},
+		path + ".1": {"uid-5", "uid-6"},
+		path + ".2": {"uid-3", "uid-4"},
+	}
+	for file, wantUIDs := range want {
+		if got := readCaptureUIDs(t, file); strings.Join(got, ",") != strings.Join(wantUIDs, ",") {
+			t.Errorf("%s holds %v, want %v", filepath.Base(file), got, wantUIDs)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want at most 2 rotated files", filepath.Base(path))
+	}
+}
+
+func TestRecorderRejectsRecordsAfterClose(t *testing.T) {
+	recorder, err := NewRecorder(filepath.Join(t.TempDir(), "captures.jsonl"), 1<<20, 1, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	response := &admissionv1.AdmissionReview{Response: &admissionv1.AdmissionResponse{Allowed: true}}
+	if err := recorder.Record(newCaptureReview(t, "uid-1"), response); err == nil {
+		t.Error("expected an error recording to a closed recorder")
+	}
+}
+
+// readCaptureUIDs returns the request UIDs recorded in a capture file
+func readCaptureUIDs(t *testing.T, path string) []string {
+	t.Helper()
+
+	file, err := os.Open(path) //nolint:gosec // G304: test file
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	records, err := ReadCaptures(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var uids []string
+	for _, record := range records {
+		uids = append(uids, string(record.Request.Request.UID))
+	}
+	return uids
+}
+
+func TestReadCapturesRejectsInvalidLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{name: "empty record", input: "{}\nnot json\n", wantErr: "line 1: capture record has no admission request"},
+		{name: "garbage", input: "not json\n", wantErr: "line 1: invalid capture record"},
+		{name: "no request", input: "\n" + `{"request":{}}` + "\n", wantErr: "line 2: capture record has no admission request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadCaptures(strings.NewReader(tt.input))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHandlerCapturesReviews(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "captures.jsonl")
+	recorder, err := NewRecorder(path, 1<<20, 1, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(), nil)
+	handler.Recorder = recorder
+
+	body, err := json.Marshal(newCaptureReview(t, "uid-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path) //nolint:gosec // G304: test file
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := ReadCaptures(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("captured %d records, want 1", len(records))
+	}
+
+	var sent admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &sent); err != nil {
+		t.Fatal(err)
+	}
+	record := records[0]
+	if record.Request.Request.UID != "uid-1" || record.Request.Request.UserInfo.Extra != nil {
+		t.Errorf("captured request = %+v, want redacted uid-1", record.Request.Request)
+	}
+	if record.Response == nil || record.Response.Response.Allowed != sent.Response.Allowed {
+		t.Errorf("captured response = %+v, want the response sent (%+v)", record.Response, sent.Response)
+	}
+}
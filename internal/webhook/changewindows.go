@@ -0,0 +1,230 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReasonChangeFreeze is the status cause type of a deletion denied during a change freeze
+	ReasonChangeFreeze = "ChangeFreeze"
+	// ReasonOutsideMaintenanceWindow is the status cause type of a deletion denied because
+	// no maintenance window is open
+	ReasonOutsideMaintenanceWindow = "OutsideMaintenanceWindow"
+
+	// changeWindowTimeFormat shows window and freeze times in denial messages
+	changeWindowTimeFormat = "Mon 2006-01-02 15:04 MST"
+	// maxChangeWindowSteps bounds the search for the next open time through chained
+	// freezes and windows
+	maxChangeWindowSteps = 100
+)
+
+// ChangeScope selects which deletions change windows restrict
+type ChangeScope string
+
+const (
+	// ChangeScopeRisky restricts risky deletions: the bypass label is not honoured while
+	// changes are closed, so they stay blocked until a window opens
+	ChangeScopeRisky ChangeScope = "risky"
+	// ChangeScopeAll denies every deletion the webhook reviews while changes are closed
+	ChangeScopeAll ChangeScope = "all"
+)
+
+// ParseChangeScope validates a change scope
+func ParseChangeScope(value string) (ChangeScope, error) {
+	switch scope := ChangeScope(value); scope {
+	case ChangeScopeRisky, ChangeScopeAll:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("change scope %q must be risky or all", value)
+	}
+}
+
+// MaintenanceWindow is a recurring period during which deletions are allowed. It opens at
+// every time matching Schedule, evaluated in Location, and stays open for Duration.
+type MaintenanceWindow struct {
+	Name     string
+	Schedule *CronSchedule
+	Duration time.Duration
+	Location *time.Location
+}
+
+// ChangeFreeze is a named period, from Start until End (exclusive), during which deletions
+// are denied even inside maintenance windows
+type ChangeFreeze struct {
+	Name       string
+	Start, End time.Time
+	// Scope overrides the scope of the change windows for this freeze; empty keeps it
+	Scope ChangeScope
+}
+
+// ChangeWindows restricts when storage deletions may happen. With maintenance windows,
+// deletions in Scope are only allowed while one is open; freezes close changes regardless.
+// Location is the time zone used in messages.
+type ChangeWindows struct {
+	Scope    ChangeScope
+	Location *time.Location
+	Windows  []MaintenanceWindow
+	Freezes  []ChangeFreeze
+}
+
+// ChangeWindowClosure explains why changes are closed at a given time
+type ChangeWindowClosure struct {
+	Scope ChangeScope
+	// Reason is ReasonChangeFreeze or ReasonOutsideMaintenanceWindow
+	Reason string
+	// Description names the freeze or says that no maintenance window is open
+	Description string
+	// Freeze is the name of the change freeze in effect, if any
+	Freeze string
+	// NextOpen is when deletions are allowed again; zero when no window opens within the
+	// search limit
+	NextOpen time.Time
+}
+
+// Closure returns why changes are closed at now, or nil when they are open
+func (cw *ChangeWindows) Closure(now time.Time) *ChangeWindowClosure {
+	var closure *ChangeWindowClosure
+
+	if freeze := cw.freezeAt(now); freeze != nil {
+		scope := freeze.Scope
+		if scope == "" {
+			scope = cw.Scope
+		}
+		closure = &ChangeWindowClosure{
+			Scope:       scope,
+			Reason:      ReasonChangeFreeze,
+			Description: fmt.Sprintf("change freeze %q until %s", freeze.Name, cw.format(freeze.End)),
+			Freeze:      freeze.Name,
+		}
+	}
+
+	if len(cw.Windows) > 0 && !cw.inWindow(now) {
+		switch {
+		case closure == nil:
+			closure = &ChangeWindowClosure{
+				Scope:       cw.Scope,
+				Reason:      ReasonOutsideMaintenanceWindow,
+				Description: "no maintenance window is open",
+			}
+		case cw.Scope == ChangeScopeAll:
+			// A risky-only freeze outside the windows still closes every deletion
+			closure.Scope = ChangeScopeAll
+		}
+	}
+
+	if closure != nil {
+		closure.NextOpen = cw.nextOpen(now)
+	}
+	return closure
+}
+
+// Applies reports whether the closure denies a deletion, given whether it is risky
+func (c *ChangeWindowClosure) Applies(risky bool) bool {
+	return c.Scope == ChangeScopeAll || risky
+}
+
+// freezeAt returns the freeze in effect at t, if any
+func (cw *ChangeWindows) freezeAt(t time.Time) *ChangeFreeze {
+	for i := range cw.Freezes {
+		freeze := &cw.Freezes[i]
+		if !t.Before(freeze.Start) && t.Before(freeze.End) {
+			return freeze
+		}
+	}
+	return nil
+}
+
+// inWindow reports whether a maintenance window is open at t, i.e. one started in
+// (t-Duration, t]
+func (cw *ChangeWindows) inWindow(t time.Time) bool {
+	for _, window := range cw.Windows {
+		start, found := window.Schedule.Next(t.Add(-window.Duration).Add(time.Nanosecond).In(window.Location))
+		if found && !start.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextWindowStart returns the earliest maintenance window start at or after t
+func (cw *ChangeWindows) nextWindowStart(t time.Time) (time.Time, bool) {
+	var earliest time.Time
+	for _, window := range cw.Windows {
+		start, found := window.Schedule.Next(t.In(window.Location))
+		if found && (earliest.IsZero() || start.Before(earliest)) {
+			earliest = start
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// nextOpen returns the first time at or after now that is inside a maintenance window (if
+// any are configured) and outside every freeze, or zero if there is none within the limits
+func (cw *ChangeWindows) nextOpen(now time.Time) time.Time {
+	t := now
+	for range maxChangeWindowSteps {
+		if freeze := cw.freezeAt(t); freeze != nil {
+			t = freeze.End
+			continue
+		}
+		if len(cw.Windows) > 0 && !cw.inWindow(t) {
+			start, found := cw.nextWindowStart(t)
+			if !found {
+				return time.Time{}
+			}
+			t = start
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// format renders a time in the change windows' time zone
+func (cw *ChangeWindows) format(t time.Time) string {
+	if cw.Location != nil {
+		t = t.In(cw.Location)
+	}
+	return t.Format(changeWindowTimeFormat)
+}
+
+// nextOpenText describes when deletions are allowed again, relative to now
+func (cw *ChangeWindows) nextOpenText(closure *ChangeWindowClosure, now time.Time) string {
+	if closure.NextOpen.IsZero() {
+		return "No maintenance window opens within the configured schedules."
+	}
+	wait := closure.NextOpen.Sub(now).Round(time.Minute)
+	return fmt.Sprintf("Deletions are allowed again from %s (in %s).", cw.format(closure.NextOpen), strings.TrimSuffix(wait.String(), "0s"))
+}
+
+// buildClosedMessage creates the denial message for a deletion refused because changes
+// are closed
+func (cw *ChangeWindows) buildClosedMessage(kind, namespace, name string, closure *ChangeWindowClosure, now time.Time) string {
+	var sb strings.Builder
+
+	object := DeletionLock{Namespace: namespace, Name: name}.field()
+	sb.WriteString(fmt.Sprintf("CHANGE WINDOW CLOSED: %s '%s' cannot be deleted now\n\n", kind, object))
+	sb.WriteString(fmt.Sprintf("Reason: %s\n", closure.Description))
+	sb.WriteString(cw.nextOpenText(closure, now) + "\n")
+
+	return sb.String()
+}
+
+// closedNote is appended to a risky deletion's denial while changes are closed
+func (cw *ChangeWindows) closedNote(closure *ChangeWindowClosure, now time.Time) string {
+	return fmt.Sprintf("\nChanges are closed (%s): risky deletions are denied until then, whatever their labels or the failure mode.\n%s\n",
+		closure.Description, cw.nextOpenText(closure, now))
+}
+
+// closureCause returns the status cause describing a closure
+func closureCause(closure *ChangeWindowClosure) metav1.StatusCause {
+	message := closure.Description
+	if !closure.NextOpen.IsZero() {
+		message += "; next open " + closure.NextOpen.UTC().Format(time.RFC3339)
+	}
+	return metav1.StatusCause{Type: metav1.CauseType(closure.Reason), Message: message}
+}
@@ -0,0 +1,265 @@
+package webhook
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newWeekendChangeWindows opens changes on Saturdays from 22:00 to Sunday 04:00, Berlin time
+func newWeekendChangeWindows(t *testing.T, scope ChangeScope, freezes ...ChangeFreeze) *ChangeWindows {
+	t.Helper()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := ParseCronSchedule("0 22 * * sat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ChangeWindows{
+		Scope:    scope,
+		Location: berlin,
+		Windows:  []MaintenanceWindow{{Name: "weekend", Schedule: schedule, Duration: 6 * time.Hour, Location: berlin}},
+		Freezes:  freezes,
+	}
+}
+
+func berlinTime(t *testing.T, value string) time.Time {
+	t.Helper()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := time.ParseInLocation("2006-01-02T15:04", value, berlin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestChangeWindowsClosure(t *testing.T) {
+	blackFriday := ChangeFreeze{Name: "black-friday", Start: berlinTime(t, "2026-11-27T00:00"), End: berlinTime(t, "2026-11-30T00:00")}
+	endsInWindow := ChangeFreeze{Name: "release", Start: berlinTime(t, "2026-11-28T12:00"), End: berlinTime(t, "2026-11-28T23:00"), Scope: ChangeScopeAll}
+
+	tests := []struct {
+		name         string
+		windows      *ChangeWindows
+		now          string
+		wantReason   string
+		wantScope    ChangeScope
+		wantNextOpen string
+	}{
+		{
+			name:    "inside the window",
+			windows: newWeekendChangeWindows(t, ChangeScopeRisky),
+			now:     "2026-11-01T03:59",
+		},
+		{
+			name:         "window end is exclusive",
+			windows:      newWeekendChangeWindows(t, ChangeScopeRisky),
+			now:          "2026-11-01T04:00",
+			wantReason:   ReasonOutsideMaintenanceWindow,
+			wantScope:    ChangeScopeRisky,
+			wantNextOpen: "2026-11-07T22:00",
+		},
+		{
+			name:         "outside the window",
+			windows:      newWeekendChangeWindows(t, ChangeScopeAll),
+			now:          "2026-10-21T12:00",
+			wantReason:   ReasonOutsideMaintenanceWindow,
+			wantScope:    ChangeScopeAll,
+			wantNextOpen: "2026-10-24T22:00",
+		},
+		{
+			name:         "freeze closes an open window until the next one",
+			windows:      newWeekendChangeWindows(t, ChangeScopeRisky, blackFriday),
+			now:          "2026-11-29T01:00",
+			wantReason:   ReasonChangeFreeze,
+			wantScope:    ChangeScopeRisky,
+			wantNextOpen: "2026-12-05T22:00",
+		},
+		{
+			name:         "window opens when a freeze ends inside it",
+			windows:      newWeekendChangeWindows(t, ChangeScopeRisky, endsInWindow),
+			now:          "2026-11-28T22:30",
+			wantReason:   ReasonChangeFreeze,
+			wantScope:    ChangeScopeAll,
+			wantNextOpen: "2026-11-28T23:00",
+		},
+		{
+			name:         "freeze without windows",
+			windows:      &ChangeWindows{Scope: ChangeScopeAll, Freezes: []ChangeFreeze{blackFriday}},
+			now:          "2026-11-28T10:00",
+			wantReason:   ReasonChangeFreeze,
+			wantScope:    ChangeScopeAll,
+			wantNextOpen: "2026-11-30T00:00",
+		},
+		{
+			name:    "after a freeze without windows",
+			windows: &ChangeWindows{Scope: ChangeScopeAll, Freezes: []ChangeFreeze{blackFriday}},
+			now:     "2026-11-30T00:00",
+		},
+		{
+			name:         "risky freeze outside all-scope windows closes everything",
+			windows:      newWeekendChangeWindows(t, ChangeScopeAll, blackFriday),
+			now:          "2026-11-27T12:00",
+			wantReason:   ReasonChangeFreeze,
+			wantScope:    ChangeScopeAll,
+			wantNextOpen: "2026-12-05T22:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closure := tt.windows.Closure(berlinTime(t, tt.now))
+			if tt.wantReason == "" {
+				if closure != nil {
+					t.Fatalf("closure = %+v, want changes open", closure)
+				}
+				return
+			}
+
+			if closure == nil {
+				t.Fatal("expected changes to be closed")
+			}
+			if closure.Reason != tt.wantReason || closure.Scope != tt.wantScope {
+				t.Errorf("closure = %s/%s, want %s/%s", closure.Reason, closure.Scope, tt.wantReason, tt.wantScope)
+			}
+			if want := berlinTime(t, tt.wantNextOpen); !closure.NextOpen.Equal(want) {
+				t.Errorf("NextOpen = %s, want %s", closure.NextOpen, want)
+			}
+		})
+	}
+}
+
+func TestHandlerChangeWindows(t *testing.T) {
+	safe := newNamespacePVC("logs", corev1.ClaimBound, "pv-logs")
+	risky := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	risky.Labels = map[string]string{BypassLabel: "true"}
+	warned := newNamespacePVC("cache", corev1.ClaimBound, "pv-cache")
+	warned.Labels = map[string]string{ProtectionLabel: string(ProtectionWarn)}
+	objects := func() *fake.Clientset {
+		return fake.NewClientset(safe, risky, warned,
+			newPhasedPV("pv-logs", corev1.PersistentVolumeReclaimRetain, corev1.VolumeBound, nil),
+			newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil),
+			newPhasedPV("pv-cache", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil))
+	}
+	blackFriday := ChangeFreeze{Name: "black-friday", Start: berlinTime(t, "2026-11-27T00:00"), End: berlinTime(t, "2026-11-30T00:00")}
+
+	tests := []struct {
+		name        string
+		scope       ChangeScope
+		freezes     []ChangeFreeze
+		now         string
+		pvc         *corev1.PersistentVolumeClaim
+		wantAllowed bool
+		wantMessage []string
+		wantCauses  []string
+	}{
+		{
+			name:  "all scope denies safe deletions outside the window",
+			scope: ChangeScopeAll,
+			now:   "2026-10-21T12:00",
+			pvc:   safe,
+			wantMessage: []string{
+				"CHANGE WINDOW CLOSED: PersistentVolumeClaim 'app/logs' cannot be deleted now",
+				"Reason: no maintenance window is open",
+				"Deletions are allowed again from Sat 2026-10-24 22:00 CEST (in 82h0m).",
+			},
+			wantCauses: []string{ReasonOutsideMaintenanceWindow},
+		},
+		{
+			name:        "risky scope allows safe deletions outside the window",
+			scope:       ChangeScopeRisky,
+			now:         "2026-10-21T12:00",
+			pvc:         safe,
+			wantAllowed: true,
+		},
+		{
+			name:  "risky scope ignores the bypass label outside the window",
+			scope: ChangeScopeRisky,
+			now:   "2026-10-21T12:00",
+			pvc:   risky,
+			wantMessage: []string{
+				"DELETION BLOCKED",
+				"Changes are closed (no maintenance window is open): risky deletions are denied until then, whatever their labels",
+			},
+			wantCauses: []string{ReasonReclaimPolicy, ReasonOutsideMaintenanceWindow},
+		},
+		{
+			name:        "bypass label works inside the window",
+			scope:       ChangeScopeRisky,
+			now:         "2026-10-24T23:00",
+			pvc:         risky,
+			wantAllowed: true,
+			wantMessage: []string{"Deletion allowed via bypass label"},
+		},
+		{
+			name:    "risky scope denies deletions allowed by warn protection during a freeze",
+			scope:   ChangeScopeRisky,
+			freezes: []ChangeFreeze{blackFriday},
+			now:     "2026-11-28T23:00",
+			pvc:     warned,
+			wantMessage: []string{
+				"DELETION BLOCKED: PersistentVolumeClaim 'app/cache' cannot be deleted while changes are closed",
+				"allowed by pv-safe.io/protection=warn on PersistentVolumeClaim app/cache",
+				`Changes are closed (change freeze "black-friday" until Mon 2026-11-30 00:00 CET)`,
+			},
+			wantCauses: []string{ReasonChangeFreeze},
+		},
+		{
+			name:        "warn protection allows risky deletions inside the window",
+			scope:       ChangeScopeRisky,
+			now:         "2026-10-24T23:00",
+			pvc:         warned,
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(log.New(io.Discard, "", 0), objects(), nil)
+			handler.ChangeWindows = newWeekendChangeWindows(t, tt.scope, tt.freezes...)
+			now := berlinTime(t, tt.now)
+			handler.now = func() time.Time { return now }
+
+			response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+				UID:       "uid-1",
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+				Operation: admissionv1.Delete,
+				Namespace: "app",
+				Name:      tt.pvc.Name,
+				OldObject: rawPVC(t, tt.pvc),
+			})
+
+			if response.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v (%+v)", response.Allowed, tt.wantAllowed, response.Result)
+			}
+			for _, want := range tt.wantMessage {
+				if !strings.Contains(response.Result.Message, want) {
+					t.Errorf("message = %q, want it to contain %q", response.Result.Message, want)
+				}
+			}
+			if tt.wantAllowed {
+				return
+			}
+			var causes []string
+			for _, cause := range response.Result.Details.Causes {
+				causes = append(causes, string(cause.Type))
+			}
+			if strings.Join(causes, ",") != strings.Join(tt.wantCauses, ",") {
+				t.Errorf("causes = %v, want %v", causes, tt.wantCauses)
+			}
+		})
+	}
+}
@@ -1,13 +1,19 @@
 package webhook
 
 import (
+	"errors"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
-// NewKubernetesClient creates a Kubernetes client using in-cluster configuration
-func NewKubernetesClient() (*kubernetes.Clientset, *rest.Config, error) {
-	config, err := rest.InClusterConfig()
+// NewKubernetesClient creates a Kubernetes client. Without a kubeconfig path or context
+// it uses the in-cluster configuration, falling back to the default kubeconfig loading
+// rules ($KUBECONFIG, then ~/.kube/config) when not running in a pod.
+func NewKubernetesClient(kubeconfig, kubeContext string) (*kubernetes.Clientset, *rest.Config, error) {
+	config, err := loadRESTConfig(kubeconfig, kubeContext)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -19,3 +25,39 @@ func NewKubernetesClient() (*kubernetes.Clientset, *rest.Config, error) {
 
 	return clientset, config, nil
 }
+
+// loadRESTConfig resolves the client configuration; see NewKubernetesClient
+func loadRESTConfig(kubeconfig, kubeContext string) (*rest.Config, error) {
+	if kubeconfig == "" && kubeContext == "" {
+		config, err := rest.InClusterConfig()
+		if err == nil {
+			return config, nil
+		}
+		if !errors.Is(err, rest.ErrNotInCluster) {
+			return nil, err
+		}
+	}
+
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+// CustomResourceListKinds returns the custom resources the webhook reads through the dynamic
+// client, mapped to their list kinds. Fake dynamic clients need these to serve list calls.
+func CustomResourceListKinds() map[schema.GroupVersionResource]string {
+	kinds := map[schema.GroupVersionResource]string{
+		volumeSnapshotGVR:        "VolumeSnapshotList",
+		volumeSnapshotClassGVR:   "VolumeSnapshotClassList",
+		volumeSnapshotContentGVR: "VolumeSnapshotContentList",
+		veleroBackupGVR:          "BackupList",
+		veleroPodVolumeBackupGVR: "PodVolumeBackupList",
+	}
+	for _, version := range groupSnapshotVersions {
+		kinds[groupSnapshotGVR(version)] = "VolumeGroupSnapshotList"
+		kinds[groupSnapshotContentGVR(version)] = "VolumeGroupSnapshotContentList"
+	}
+	return kinds
+}
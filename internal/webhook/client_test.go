@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: kind-dev
+clusters:
+- name: dev
+  cluster:
+    server: https://127.0.0.1:6443
+- name: staging
+  cluster:
+    server: https://staging.example.com:6443
+contexts:
+- name: kind-dev
+  context:
+    cluster: dev
+    user: dev
+- name: staging
+  context:
+    cluster: staging
+    user: dev
+users:
+- name: dev
+  user:
+    token: test-token
+`
+
+func TestLoadRESTConfigFromKubeconfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		context  string
+		wantHost string
+		wantErr  bool
+	}{
+		{name: "current context", wantHost: "https://127.0.0.1:6443"},
+		{name: "explicit context", context: "staging", wantHost: "https://staging.example.com:6443"},
+		{name: "unknown context", context: "prod", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := loadRESTConfig(path, tt.context)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadRESTConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && config.Host != tt.wantHost {
+				t.Errorf("Host = %q, want %q", config.Host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestLoadRESTConfigFallsBackToKubeconfigOutsideCluster(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatalf("failed to write kubeconfig: %v", err)
+	}
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBECONFIG", path)
+
+	config, err := loadRESTConfig("", "")
+	if err != nil {
+		t.Fatalf("loadRESTConfig() error = %v", err)
+	}
+	if config.Host != "https://127.0.0.1:6443" {
+		t.Errorf("Host = %q, want the $KUBECONFIG cluster", config.Host)
+	}
+}
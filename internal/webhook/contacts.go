@@ -0,0 +1,217 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Contact is an ownership annotation or label found for a risky PVC
+type Contact struct {
+	// Key is the configured annotation or label key, e.g. "team"
+	Key   string
+	Value string
+	// Source is the object the value was read from, e.g. "Deployment app/web"
+	Source string
+}
+
+// contactSource is an object whose annotations and labels may name the PVC's owners
+type contactSource struct {
+	description string
+	meta        metav1.Object
+}
+
+// addContacts fills in the contacts of risky PVCs in one namespace. Each contact key is
+// looked up on the PVC first, then on the workloads mounting it, then on the namespace;
+// annotations win over labels on the same object. Lookups are best effort: objects that
+// cannot be read contribute no contacts. pvcs maps claim names to the claims already read;
+// when it is nil the claims are read from the API.
+func (rc *RiskCalculator) addContacts(ctx context.Context, namespace string, riskyPVCs []RiskyPVC, pvcs map[string]*corev1.PersistentVolumeClaim) {
+	if len(rc.contactKeys) == 0 || len(riskyPVCs) == 0 || namespace == "" {
+		return
+	}
+
+	if pvcs == nil {
+		pvcs = map[string]*corev1.PersistentVolumeClaim{}
+		for _, risky := range riskyPVCs {
+			if pvc, err := rc.client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, risky.Name, metav1.GetOptions{}); err == nil {
+				pvcs[risky.Name] = pvc
+			}
+		}
+	}
+
+	var namespaceSource *contactSource
+	if ns, err := rc.client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err == nil {
+		namespaceSource = &contactSource{description: "Namespace " + namespace, meta: ns}
+	}
+
+	workloads := rc.workloadsByClaim(ctx, namespace)
+
+	for i := range riskyPVCs {
+		risky := &riskyPVCs[i]
+
+		var sources []contactSource
+		if pvc := pvcs[risky.Name]; pvc != nil {
+			sources = append(sources, contactSource{description: fmt.Sprintf("PersistentVolumeClaim %s/%s", namespace, pvc.Name), meta: pvc})
+		}
+		sources = append(sources, workloads[risky.Name]...)
+		if namespaceSource != nil {
+			sources = append(sources, *namespaceSource)
+		}
+
+		risky.Contacts = findContacts(rc.contactKeys, sources)
+	}
+}
+
+// findContacts returns, for each key, the first value found on the sources in order
+func findContacts(keys []string, sources []contactSource) []Contact {
+	var contacts []Contact
+
+	for _, key := range keys {
+		for _, source := range sources {
+			value, found := source.meta.GetAnnotations()[key]
+			if !found {
+				value, found = source.meta.GetLabels()[key]
+			}
+			if found && value != "" {
+				contacts = append(contacts, Contact{Key: key, Value: value, Source: source.description})
+				break
+			}
+		}
+	}
+
+	return contacts
+}
+
+// workloadsByClaim maps each claim name to the workloads whose pods mount it, in pod list
+// order and without duplicates. A pod is attributed to its top-level controller where that
+// can be read, and to itself otherwise.
+func (rc *RiskCalculator) workloadsByClaim(ctx context.Context, namespace string) map[string][]contactSource {
+	result := map[string][]contactSource{}
+
+	pods, err := rc.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return result
+	}
+
+	cache := map[string]*contactSource{}
+	seen := map[string]bool{}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
+			}
+			claim := volume.PersistentVolumeClaim.ClaimName
+
+			workload := rc.workloadOf(ctx, pod, cache)
+			if seen[claim+"\x00"+workload.description] {
+				continue
+			}
+			seen[claim+"\x00"+workload.description] = true
+			result[claim] = append(result[claim], *workload)
+		}
+	}
+
+	return result
+}
+
+// workloadOf resolves a pod to its controlling Deployment, StatefulSet, DaemonSet or
+// ReplicaSet. Resolved controllers are cached by kind and name.
+func (rc *RiskCalculator) workloadOf(ctx context.Context, pod *corev1.Pod, cache map[string]*contactSource) *contactSource {
+	self := &contactSource{description: fmt.Sprintf("Pod %s/%s", pod.Namespace, pod.Name), meta: pod}
+
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return self
+	}
+
+	cacheKey := owner.Kind + "/" + owner.Name
+	if cached, found := cache[cacheKey]; found {
+		return cached
+	}
+
+	workload := rc.getController(ctx, pod.Namespace, owner)
+	if workload == nil {
+		return self
+	}
+
+	cache[cacheKey] = workload
+	return workload
+}
+
+// getController reads a pod's controller, following a ReplicaSet up to its Deployment
+func (rc *RiskCalculator) getController(ctx context.Context, namespace string, owner *metav1.OwnerReference) *contactSource {
+	apps := rc.client.AppsV1()
+	describe := func(kind string, meta metav1.Object) *contactSource {
+		return &contactSource{description: fmt.Sprintf("%s %s/%s", kind, namespace, meta.GetName()), meta: meta}
+	}
+
+	switch owner.Kind {
+	case "ReplicaSet":
+		rs, err := apps.ReplicaSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+		if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil && rsOwner.Kind == "Deployment" {
+			if deployment, err := apps.Deployments(namespace).Get(ctx, rsOwner.Name, metav1.GetOptions{}); err == nil {
+				return describe("Deployment", deployment)
+			}
+		}
+		return describe("ReplicaSet", rs)
+	case "StatefulSet":
+		sts, err := apps.StatefulSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+		return describe("StatefulSet", sts)
+	case "DaemonSet":
+		ds, err := apps.DaemonSets(namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil
+		}
+		return describe("DaemonSet", ds)
+	default:
+		return nil
+	}
+}
+
+// contactsLine renders contacts as a "Contacts: key=value (source), ..." message line
+func contactsLine(contacts []Contact) string {
+	if len(contacts) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		parts = append(parts, fmt.Sprintf("%s=%s (%s)", contact.Key, contact.Value, contact.Source))
+	}
+	return "Contacts: " + strings.Join(parts, ", ") + "\n"
+}
+
+// formatContacts renders contacts as "key=value, ..." for the PVC lines of namespace denials
+func formatContacts(contacts []Contact) string {
+	parts := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		parts = append(parts, contact.Key+"="+contact.Value)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// causeContacts renders contacts as " key=value" pairs for status cause messages, quoting
+// values that contain spaces or quotes
+func causeContacts(contacts []Contact) string {
+	var sb strings.Builder
+	for _, contact := range contacts {
+		value := contact.Value
+		if strings.ContainsAny(value, " \t\"") {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(fmt.Sprintf(" %s=%s", contact.Key, value))
+	}
+	return sb.String()
+}
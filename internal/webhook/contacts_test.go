@@ -0,0 +1,170 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newContactNamespace(annotations map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app", Annotations: annotations}}
+}
+
+func newClaimPod(name string, owner *metav1.OwnerReference, claims ...string) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "app"}}
+	if owner != nil {
+		pod.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	for _, claim := range claims {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: claim,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+			},
+		})
+	}
+	return pod
+}
+
+func controllerRef(kind, name string) *metav1.OwnerReference {
+	isController := true
+	return &metav1.OwnerReference{APIVersion: "apps/v1", Kind: kind, Name: name, Controller: &isController}
+}
+
+// newDeploymentObjects returns a Deployment labelled with labels, its ReplicaSet and a pod
+// mounting claim
+func newDeploymentObjects(name, claim string, labels map[string]string) []runtime.Object {
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "app", Labels: labels}}
+	replicaSet := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name:            name + "-5d8f",
+		Namespace:       "app",
+		OwnerReferences: []metav1.OwnerReference{*controllerRef("Deployment", name)},
+	}}
+	return []runtime.Object{deployment, replicaSet, newClaimPod(name+"-5d8f-x2k4", controllerRef("ReplicaSet", replicaSet.Name), claim)}
+}
+
+func TestAssessPVCDeletionContacts(t *testing.T) {
+	pvc := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	pvc.Annotations = map[string]string{"team": "payments"}
+
+	objects := []runtime.Object{
+		pvc,
+		newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil),
+		newContactNamespace(map[string]string{"owner": "jane@example.com", "team": "platform"}),
+	}
+	objects = append(objects, newDeploymentObjects("web", "data", map[string]string{"oncall": "payments-primary", "team": "web"})...)
+
+	rc := NewRiskCalculator(fake.NewClientset(objects...), nil)
+	rc.SetContactKeys([]string{"owner", "team", "oncall", "slack"})
+
+	assessment, err := rc.AssessPVCDeletion(context.Background(), "app", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assessment.IsRisky {
+		t.Fatal("expected the PVC to be risky")
+	}
+
+	want := []Contact{
+		{Key: "owner", Value: "jane@example.com", Source: "Namespace app"},
+		{Key: "team", Value: "payments", Source: "PersistentVolumeClaim app/data"},
+		{Key: "oncall", Value: "payments-primary", Source: "Deployment app/web"},
+	}
+	if got := assessment.RiskyPVCs[0].Contacts; !reflect.DeepEqual(got, want) {
+		t.Errorf("contacts = %+v, want %+v", got, want)
+	}
+
+	wantLine := "Contacts: owner=jane@example.com (Namespace app), team=payments (PersistentVolumeClaim app/data), " +
+		"oncall=payments-primary (Deployment app/web)\n"
+	if !strings.HasSuffix(assessment.Message, wantLine) {
+		t.Errorf("message = %q, want it to end with %q", assessment.Message, wantLine)
+	}
+
+	causes := assessment.Causes()
+	wantCause := "pv=pv-data snapshot=None owner=jane@example.com team=payments oncall=payments-primary: "
+	if len(causes) != 1 || !strings.HasPrefix(causes[0].Message, wantCause) {
+		t.Errorf("causes = %+v, want a message starting with %q", causes, wantCause)
+	}
+}
+
+func TestAssessNamespaceDeletionContacts(t *testing.T) {
+	objects := newNamespaceObjects(2, corev1.PersistentVolumeReclaimDelete)
+	objects = append(objects,
+		newContactNamespace(map[string]string{"team": "platform"}),
+		newClaimPod("db-0", controllerRef("StatefulSet", "db"), "data-0001"),
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{
+			Name:        "db",
+			Namespace:   "app",
+			Annotations: map[string]string{"team": "Database Reliability"},
+		}},
+	)
+
+	rc := NewRiskCalculator(fake.NewClientset(objects...), nil)
+	rc.SetContactKeys([]string{"team"})
+
+	assessment, err := rc.AssessNamespaceDeletion(context.Background(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"  - data-0000: PV has Delete reclaim policy, no snapshot found [contacts: team=platform]\n",
+		"  - data-0001: PV has Delete reclaim policy, no snapshot found [contacts: team=Database Reliability]\n",
+	} {
+		if !strings.Contains(assessment.Message, want) {
+			t.Errorf("message = %q, want it to contain %q", assessment.Message, want)
+		}
+	}
+
+	causes := assessment.Causes()
+	if len(causes) != 2 || !strings.Contains(causes[1].Message, `team="Database Reliability":`) {
+		t.Errorf("causes = %+v, want the quoted StatefulSet team", causes)
+	}
+}
+
+func TestAssessPVDeletionContacts(t *testing.T) {
+	pvc := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	pvc.Labels = map[string]string{"team": "payments"}
+	pv := newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound,
+		&corev1.ObjectReference{Namespace: "app", Name: "data"})
+
+	rc := NewRiskCalculator(fake.NewClientset(pvc, pv, newClaimPod("debug", nil, "data")), nil)
+	rc.SetContactKeys([]string{"team"})
+
+	assessment, err := rc.AssessPVDeletion(context.Background(), "pv-data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Contact{{Key: "team", Value: "payments", Source: "PersistentVolumeClaim app/data"}}
+	if got := assessment.RiskyPVCs[0].Contacts; !reflect.DeepEqual(got, want) {
+		t.Errorf("contacts = %+v, want %+v", got, want)
+	}
+}
+
+func TestContactsDisabledByDefault(t *testing.T) {
+	pvc := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	pvc.Annotations = map[string]string{"team": "payments"}
+	client := fake.NewClientset(pvc, newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil))
+
+	assessment, err := NewRiskCalculator(client, nil).AssessPVCDeletion(context.Background(), "app", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contacts := assessment.RiskyPVCs[0].Contacts; contacts != nil {
+		t.Errorf("contacts = %+v, want none without contact keys", contacts)
+	}
+	for _, action := range client.Actions() {
+		if action.GetResource().Resource == "pods" {
+			t.Errorf("unexpected %s of pods without contact keys", action.GetVerb())
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package webhook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit bounds the search for the next matching time, so that schedules that
+// never match (e.g. "0 0 30 2 *") terminate
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// CronSchedule is a standard five-field cron expression: minute, hour, day of month,
+// month and day of week. Fields accept "*", numbers, ranges ("1-5"), steps ("*/15",
+// "0-30/10"), comma-separated lists and three-letter month and day names. As in cron, a
+// day matches when either day field matches if both are restricted.
+type CronSchedule struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDOM    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Day of week 7 is Sunday, like 0
+	cronDOW = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// ParseCronSchedule parses a five-field cron expression
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron schedule %q must have 5 fields (minute hour day-of-month month day-of-week), got %d", spec, len(fields))
+	}
+
+	schedule := &CronSchedule{spec: spec}
+	var err error
+	if schedule.minute, err = cronMinute.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("cron schedule %q: %w", spec, err)
+	}
+	if schedule.hour, err = cronHour.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("cron schedule %q: %w", spec, err)
+	}
+	if schedule.dom, err = cronDOM.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("cron schedule %q: %w", spec, err)
+	}
+	if schedule.month, err = cronMonth.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("cron schedule %q: %w", spec, err)
+	}
+	if schedule.dow, err = cronDOW.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("cron schedule %q: %w", spec, err)
+	}
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domRestricted = !strings.HasPrefix(fields[2], "*")
+	schedule.dowRestricted = !strings.HasPrefix(fields[4], "*")
+
+	return schedule, nil
+}
+
+// String returns the cron expression
+func (s *CronSchedule) String() string {
+	return s.spec
+}
+
+// parse returns the bit set of values selected by one cron field
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if before, after, found := strings.Cut(part, "/"); found {
+			var err error
+			if step, err = strconv.Atoi(after); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", after, f.name)
+			}
+			rangePart = before
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			lowText, highText, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = f.value(lowText); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highText); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// "5/15" means from 5 to the end in steps of 15
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangePart, f.name)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// value parses a number or name within the field's bounds
+func (f cronField) value(text string) (int, error) {
+	if v, found := f.names[strings.ToLower(text)]; found {
+		return v, nil
+	}
+	v, err := strconv.Atoi(text)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q in %s field (must be %d-%d)", text, f.name, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first matching minute at or after t, in t's location. It reports
+// false when the schedule does not match within five years.
+func (s *CronSchedule) Next(t time.Time) (time.Time, bool) {
+	loc := t.Location()
+	// Round up to a whole minute
+	if truncated := t.Truncate(time.Minute); !truncated.Equal(t) {
+		t = truncated.Add(time.Minute)
+	}
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		var next time.Time
+		switch {
+		case s.month&(1<<uint(month)) == 0:
+			next = time.Date(year, month+1, 1, 0, 0, 0, 0, loc)
+		case !s.dayMatches(t):
+			next = time.Date(year, month, day+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			next = time.Date(year, month, day, t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+		// Daylight saving transitions can make the wall clock step backwards; always move on
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+
+	return time.Time{}, false
+}
+
+// dayMatches applies the cron rule for the two day fields
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"0 22 * * sat", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 24, 22, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 10, 18, 12, 1, 30, 0, time.UTC), time.Date(2026, 10, 18, 12, 15, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC), time.Date(2026, 10, 18, 2, 30, 0, 0, time.UTC)},
+		{"0 9 1-7 * mon-fri", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		// 02:30 does not exist on the spring daylight saving day in Berlin
+		{"30 2 * * *", time.Date(2026, 3, 29, 0, 0, 0, 0, berlin), time.Date(2026, 3, 30, 2, 30, 0, 0, berlin)},
+		{"0 22 * * 6", time.Date(2026, 10, 18, 12, 0, 0, 0, berlin), time.Date(2026, 10, 24, 22, 0, 0, 0, berlin)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, found := schedule.Next(tt.from)
+			if !found || !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, %v, want %s", tt.from, got, found, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNeverMatches(t *testing.T) {
+	schedule, err := ParseCronSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, found := schedule.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); found {
+		t.Errorf("Next() = %s, want no match for February 30", got)
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	tests := map[string]string{
+		"0 22 * *":     "must have 5 fields",
+		"60 * * * *":   `invalid value "60" in minute field`,
+		"0 5-2 * * *":  `invalid range "5-2" in hour field`,
+		"*/0 * * * *":  `invalid step "0" in minute field`,
+		"0 0 * * fun":  `invalid value "fun" in day of week field`,
+		"0 0 0 * *":    `invalid value "0" in day of month field`,
+		"0 0 * 13 *":   `invalid value "13" in month field`,
+		"0 0 * * 1,,2": `invalid value "" in day of week field`,
+	}
+
+	for spec, want := range tests {
+		if _, err := ParseCronSchedule(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseCronSchedule(%q) error = %v, want it to contain %q", spec, err, want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	groupSnapshotGroup = "groupsnapshot.storage.k8s.io"
+
+	// groupSnapshotDiscoveryInterval is how long a fallback to the default group snapshot
+	// version is kept before discovery is tried again, e.g. after the CRDs are installed
+	groupSnapshotDiscoveryInterval = 5 * time.Minute
+)
+
+// groupSnapshotVersions are the VolumeGroupSnapshot API versions pv-safe can read, newest first
+var groupSnapshotVersions = []string{"v1", "v1beta2", "v1beta1"}
+
+// volumeGroupSnapshotGVR is read when the served version cannot be discovered
+var volumeGroupSnapshotGVR = groupSnapshotGVR("v1beta1")
+
+// groupSnapshotGVR returns the VolumeGroupSnapshot resource of an API version
+func groupSnapshotGVR(version string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: groupSnapshotGroup, Version: version, Resource: "volumegroupsnapshots"}
+}
+
+// groupSnapshotContentGVR returns the VolumeGroupSnapshotContent resource of an API version
+func groupSnapshotContentGVR(version string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: groupSnapshotGroup, Version: version, Resource: "volumegroupsnapshotcontents"}
+}
+
+// groupSnapshotVersion returns the newest VolumeGroupSnapshot API version served by the
+// cluster. A discovered version is kept for the life of the checker; without discovery, or
+// when no known version is served, v1beta1 is used and discovery is retried later.
+func (sc *SnapshotChecker) groupSnapshotVersion() string {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.groupVersion != "" && (sc.groupVersionFinal || time.Since(sc.groupVersionAt) < groupSnapshotDiscoveryInterval) {
+		return sc.groupVersion
+	}
+
+	sc.groupVersion, sc.groupVersionFinal = volumeGroupSnapshotGVR.Version, false
+	sc.groupVersionAt = time.Now()
+	if sc.clientset == nil {
+		return sc.groupVersion
+	}
+
+	for _, version := range groupSnapshotVersions {
+		resources, err := sc.clientset.Discovery().ServerResourcesForGroupVersion(groupSnapshotGroup + "/" + version)
+		if err != nil {
+			continue
+		}
+		for _, resource := range resources.APIResources {
+			if resource.Name == volumeGroupSnapshotGVR.Resource {
+				sc.groupVersion, sc.groupVersionFinal = version, true
+				return sc.groupVersion
+			}
+		}
+	}
+
+	return sc.groupVersion
+}
+
+// GroupSnapshotInfo contains information about a VolumeGroupSnapshot
+type GroupSnapshotInfo struct {
+	Name           string
+	Namespace      string
+	ContentName    string
+	IsReady        bool
+	DeletionPolicy string
+	CreationTime   metav1.Time
+	// VolumeHandles lists the CSI volume handles captured by the group, when reported
+	VolumeHandles []string
+}
+
+// HasReadyGroupSnapshot checks if a PVC is a member of a Ready VolumeGroupSnapshot whose
+// content has a Retain deletion policy. A PVC is a member when the group's label selector
+// matches it and its PV's handle is listed by the content, or, when the content reports no
+// volume handles, the group was taken after the PVC was created.
+func (sc *SnapshotChecker) HasReadyGroupSnapshot(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) (bool, *GroupSnapshotInfo, error) {
+	groups, err := sc.listGroupSnapshots(ctx, pvc.Namespace)
+	if err != nil {
+		return false, nil, err
+	}
+
+	info := findGroupSnapshot(groups, pvc, pv)
+	return info != nil, info, nil
+}
+
+// listGroupSnapshots lists and indexes the VolumeGroupSnapshots of a namespace in the
+// served API version
+func (sc *SnapshotChecker) listGroupSnapshots(ctx context.Context, namespace string) ([]*indexedGroupSnapshot, error) {
+	version := sc.groupSnapshotVersion()
+	groups, err := sc.dynamicClient.Resource(groupSnapshotGVR(version)).Namespace(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		// VolumeGroupSnapshot CRD might not be installed
+		return nil, fmt.Errorf("failed to list volumegroupsnapshots (group snapshots may not be available): %w", err)
+	}
+
+	return sc.indexGroupSnapshots(ctx, version, groups.Items), nil
+}
+
+// indexedGroupSnapshot is a Ready VolumeGroupSnapshot with retained content
+type indexedGroupSnapshot struct {
+	group *unstructured.Unstructured
+	info  *GroupSnapshotInfo
+}
+
+// indexGroupSnapshots resolves the content of every Ready VolumeGroupSnapshot and keeps
+// the ones whose content has a Retain deletion policy
+func (sc *SnapshotChecker) indexGroupSnapshots(ctx context.Context, version string, items []unstructured.Unstructured) []*indexedGroupSnapshot {
+	var result []*indexedGroupSnapshot
+
+	for i := range items {
+		item := &items[i]
+
+		ready, _, _ := unstructured.NestedBool(item.Object, "status", "readyToUse")
+		if !ready {
+			continue
+		}
+
+		contentName, found, _ := unstructured.NestedString(item.Object, "status", "boundVolumeGroupSnapshotContentName")
+		if !found || contentName == "" {
+			continue
+		}
+
+		info, err := sc.getGroupSnapshotContent(ctx, version, contentName)
+		if err != nil || info.DeletionPolicy != "Retain" {
+			continue
+		}
+
+		info.Name = item.GetName()
+		info.Namespace = item.GetNamespace()
+		info.IsReady = true
+		info.CreationTime = item.GetCreationTimestamp()
+
+		result = append(result, &indexedGroupSnapshot{group: item, info: info})
+	}
+
+	return result
+}
+
+// findGroupSnapshot returns the first indexed group snapshot that has the PVC as a member.
+// Without captured volume handles the selector alone cannot tell a recreated PVC from the
+// one that was snapshotted, so a group older than the PVC is not accepted.
+func findGroupSnapshot(groups []*indexedGroupSnapshot, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) *GroupSnapshotInfo {
+	for _, candidate := range groups {
+		if !groupSelectsPVC(candidate.group, pvc) {
+			continue
+		}
+
+		if len(candidate.info.VolumeHandles) > 0 {
+			if !containsVolumeHandle(candidate.info.VolumeHandles, pv) {
+				continue
+			}
+		} else if !pvc.CreationTimestamp.IsZero() && candidate.info.CreationTime.Before(&pvc.CreationTimestamp) {
+			continue
+		}
+
+		return candidate.info
+	}
+
+	return nil
+}
+
+// getGroupSnapshotContent reads the deletion policy and captured volume handles of a
+// VolumeGroupSnapshotContent
+func (sc *SnapshotChecker) getGroupSnapshotContent(ctx context.Context, version, contentName string) (*GroupSnapshotInfo, error) {
+	content, err := sc.dynamicClient.Resource(groupSnapshotContentGVR(version)).Get(ctx, contentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &GroupSnapshotInfo{
+		ContentName:    contentName,
+		DeletionPolicy: UnknownDeletionPolicy,
+	}
+
+	if policy, found, _ := unstructured.NestedString(content.Object, "spec", "deletionPolicy"); found {
+		info.DeletionPolicy = policy
+	}
+
+	// v1beta1 reports volumeSnapshotHandlePairList, later versions volumeSnapshotInfoList
+	for _, field := range []string{"volumeSnapshotHandlePairList", "volumeSnapshotInfoList"} {
+		entries, _, _ := unstructured.NestedSlice(content.Object, "status", field)
+		for _, entry := range entries {
+			entryMap, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if handle, ok := entryMap["volumeHandle"].(string); ok && handle != "" {
+				info.VolumeHandles = append(info.VolumeHandles, handle)
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// groupSelectsPVC checks whether a VolumeGroupSnapshot's label selector matches the PVC
+func groupSelectsPVC(group *unstructured.Unstructured, pvc *corev1.PersistentVolumeClaim) bool {
+	rawSelector, found, _ := unstructured.NestedMap(group.Object, "spec", "source", "selector")
+	if !found {
+		return false
+	}
+
+	var labelSelector metav1.LabelSelector
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawSelector, &labelSelector); err != nil {
+		return false
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&labelSelector)
+	if err != nil || selector.Empty() {
+		return false
+	}
+
+	return selector.Matches(labels.Set(pvc.Labels))
+}
+
+// containsVolumeHandle checks whether the PV's CSI volume handle is in the list
+func containsVolumeHandle(handles []string, pv *corev1.PersistentVolume) bool {
+	if pv.Spec.CSI == nil {
+		return false
+	}
+
+	for _, handle := range handles {
+		if handle == pv.Spec.CSI.VolumeHandle {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,219 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newGroupSnapshot(name string, ready bool, matchLabels map[string]interface{}, contentName string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "groupsnapshot.storage.k8s.io/v1beta1",
+		"kind":       "VolumeGroupSnapshot",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "app"},
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{
+				"selector": map[string]interface{}{"matchLabels": matchLabels},
+			},
+		},
+		"status": map[string]interface{}{
+			"readyToUse":                          ready,
+			"boundVolumeGroupSnapshotContentName": contentName,
+		},
+	}}
+}
+
+func newGroupSnapshotContent(name, deletionPolicy string, volumeHandles ...string) *unstructured.Unstructured {
+	pairs := make([]interface{}, 0, len(volumeHandles))
+	for _, handle := range volumeHandles {
+		pairs = append(pairs, map[string]interface{}{"volumeHandle": handle, "snapshotHandle": "snap-" + handle})
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "groupsnapshot.storage.k8s.io/v1beta1",
+		"kind":       "VolumeGroupSnapshotContent",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       map[string]interface{}{"deletionPolicy": deletionPolicy},
+		"status":     map[string]interface{}{"volumeSnapshotHandlePairList": pairs},
+	}}
+}
+
+func TestHasReadyGroupSnapshot(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-0", Namespace: "app", Labels: map[string]string{"app": "db"}},
+	}
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-data-0"},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "hostpath.csi.k8s.io", VolumeHandle: "vol-1"},
+			},
+		},
+	}
+	dbSelector := map[string]interface{}{"app": "db"}
+	pvcCreated := metav1.NewTime(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+	takenAt := func(group *unstructured.Unstructured, at time.Time) *unstructured.Unstructured {
+		group.SetCreationTimestamp(metav1.NewTime(at))
+		return group
+	}
+
+	tests := []struct {
+		name       string
+		objects    []runtime.Object
+		pvcCreated metav1.Time
+		wantGroup  string
+	}{
+		{
+			name: "ready group with Retain content covers the PVC",
+			objects: []runtime.Object{
+				newGroupSnapshot("db-group", true, dbSelector, "content-1"),
+				newGroupSnapshotContent("content-1", "Retain", "vol-1", "vol-2"),
+			},
+			wantGroup: "db-group",
+		},
+		{
+			name: "group not ready",
+			objects: []runtime.Object{
+				newGroupSnapshot("db-group", false, dbSelector, "content-1"),
+				newGroupSnapshotContent("content-1", "Retain", "vol-1"),
+			},
+		},
+		{
+			name: "content has Delete policy",
+			objects: []runtime.Object{
+				newGroupSnapshot("db-group", true, dbSelector, "content-1"),
+				newGroupSnapshotContent("content-1", "Delete", "vol-1"),
+			},
+		},
+		{
+			name: "selector does not match the PVC",
+			objects: []runtime.Object{
+				newGroupSnapshot("web-group", true, map[string]interface{}{"app": "web"}, "content-1"),
+				newGroupSnapshotContent("content-1", "Retain", "vol-1"),
+			},
+		},
+		{
+			name: "volume handle not captured by the group",
+			objects: []runtime.Object{
+				newGroupSnapshot("db-group", true, dbSelector, "content-1"),
+				newGroupSnapshotContent("content-1", "Retain", "vol-2"),
+			},
+		},
+		{
+			name: "content without handle list relies on selector",
+			objects: []runtime.Object{
+				newGroupSnapshot("db-group", true, dbSelector, "content-1"),
+				newGroupSnapshotContent("content-1", "Retain"),
+			},
+			wantGroup: "db-group",
+		},
+		{
+			name: "content without handle list taken after the PVC was created",
+			objects: []runtime.Object{
+				takenAt(newGroupSnapshot("db-group", true, dbSelector, "content-1"), pvcCreated.Add(time.Hour)),
+				newGroupSnapshotContent("content-1", "Retain"),
+			},
+			pvcCreated: pvcCreated,
+			wantGroup:  "db-group",
+		},
+		{
+			name: "content without handle list taken before the PVC was created",
+			objects: []runtime.Object{
+				takenAt(newGroupSnapshot("db-group", true, dbSelector, "content-1"), pvcCreated.Add(-time.Hour)),
+				newGroupSnapshotContent("content-1", "Retain"),
+			},
+			pvcCreated: pvcCreated,
+		},
+		{
+			name: "handle list proves membership of a group older than the PVC",
+			objects: []runtime.Object{
+				takenAt(newGroupSnapshot("db-group", true, dbSelector, "content-1"), pvcCreated.Add(-time.Hour)),
+				newGroupSnapshotContent("content-1", "Retain", "vol-1"),
+			},
+			pvcCreated: pvcCreated,
+			wantGroup:  "db-group",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), CustomResourceListKinds(), tt.objects...)
+			sc := NewSnapshotCheckerForClient(client, nil)
+			pvc := pvc.DeepCopy()
+			pvc.CreationTimestamp = tt.pvcCreated
+
+			covered, info, err := sc.HasReadyGroupSnapshot(context.Background(), pvc, pv)
+			if err != nil {
+				t.Fatalf("HasReadyGroupSnapshot() error = %v", err)
+			}
+
+			if tt.wantGroup == "" {
+				if covered {
+					t.Fatalf("HasReadyGroupSnapshot() = true (%s), want false", info.Name)
+				}
+				return
+			}
+
+			if !covered || info == nil {
+				t.Fatalf("HasReadyGroupSnapshot() = false, want %q", tt.wantGroup)
+			}
+			if info.Name != tt.wantGroup || info.DeletionPolicy != "Retain" {
+				t.Errorf("HasReadyGroupSnapshot() = %+v, want %q with Retain policy", info, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestHasReadyGroupSnapshotDiscoversServedVersion(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-0", Namespace: "app", Labels: map[string]string{"app": "db"}},
+	}
+	pv := &corev1.PersistentVolume{
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "hostpath.csi.k8s.io", VolumeHandle: "vol-1"},
+			},
+		},
+	}
+
+	// The cluster serves only v1, which reports captured volumes in volumeSnapshotInfoList
+	group := newGroupSnapshot("db-group", true, map[string]interface{}{"app": "db"}, "content-1")
+	group.SetAPIVersion("groupsnapshot.storage.k8s.io/v1")
+	content := newGroupSnapshotContent("content-1", "Retain")
+	content.SetAPIVersion("groupsnapshot.storage.k8s.io/v1")
+	content.Object["status"] = map[string]interface{}{
+		"volumeSnapshotInfoList": []interface{}{map[string]interface{}{"volumeHandle": "vol-1", "snapshotHandle": "snap-vol-1"}},
+	}
+
+	clientset := fake.NewClientset()
+	clientset.Discovery().(*fakediscovery.FakeDiscovery).Resources = []*metav1.APIResourceList{{
+		GroupVersion: "groupsnapshot.storage.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "volumegroupsnapshots", Namespaced: true, Kind: "VolumeGroupSnapshot"},
+			{Name: "volumegroupsnapshotcontents", Kind: "VolumeGroupSnapshotContent"},
+		},
+	}}
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), CustomResourceListKinds(), group, content)
+
+	covered, info, err := NewSnapshotCheckerForClient(client, clientset).HasReadyGroupSnapshot(context.Background(), pvc, pv)
+	if err != nil {
+		t.Fatalf("HasReadyGroupSnapshot() error = %v", err)
+	}
+	if !covered || info.Name != "db-group" {
+		t.Errorf("HasReadyGroupSnapshot() = %v, %+v, want db-group read through v1", covered, info)
+	}
+
+	// Without discovery the v1 objects are not visible through v1beta1
+	covered, _, err = NewSnapshotCheckerForClient(client, nil).HasReadyGroupSnapshot(context.Background(), pvc, pv)
+	if err != nil || covered {
+		t.Errorf("HasReadyGroupSnapshot() = %v, %v, want no group at v1beta1", covered, err)
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	admissionv1 "k8s.io/api/admission/v1"
@@ -20,27 +22,75 @@ import (
 )
 
 const (
-	// BypassLabel is the label that allows forcing deletion
+	// BypassLabel is the default label that allows forcing deletion
 	//nolint:gosec // G101: This is a label name, not a credential
 	BypassLabel = "pv-safe.io/force-delete"
+
+	// DefaultAssessmentTimeout is the default timeout for assessing one admission request
+	DefaultAssessmentTimeout = 5 * time.Second
 )
 
 // Handler is the main webhook handler that processes Kubernetes admission requests.
 // It contains a logger for structured logging and a risk calculator for assessing deletions.
+// AutoSnapshotter is optional; when set, blocked PVC and Namespace deletions trigger
+// VolumeSnapshot creation for the risky claims. The remaining fields are runtime settings
+// whose defaults are set by NewHandler.
 type Handler struct {
-	Logger         *log.Logger
-	RiskCalculator *RiskCalculator
+	Logger          *log.Logger
+	RiskCalculator  *RiskCalculator
+	AutoSnapshotter *AutoSnapshotter
+
+	// AssessmentTimeout bounds the risk assessment of one admission request
+	AssessmentTimeout time.Duration
+	// FailClosed denies deletions whose risk assessment fails instead of allowing them
+	FailClosed bool
+	// BypassLabel is the label key that forces a deletion when set to "true"
+	BypassLabel string
+	// ExcludedNamespaces lists namespaces whose deletions are never assessed
+	ExcludedNamespaces map[string]bool
+	// ProtectStorageClasses enables assessment of StorageClass and CSIDriver deletions
+	ProtectStorageClasses bool
+	// ReadinessChecks are run by ReadyCheck; see AddReadinessCheck
+	ReadinessChecks []ReadinessCheck
+	// Recorder, when set, captures every admission review and its response for replay
+	Recorder *Recorder
+	// LockAdminUsers and LockAdminGroups are the identities allowed to remove or change
+	// deletion lock annotations; with neither set, locks cannot be removed through the API
+	LockAdminUsers  []string
+	LockAdminGroups []string
+	// ChangeWindows, when set, closes deletions outside maintenance windows and during freezes
+	ChangeWindows *ChangeWindows
+	// CircuitBreaker, when set, denies all deletions after too many in a short time
+	CircuitBreaker *CircuitBreaker
+
+	// now is the clock used for change windows; replaced in tests
+	now func() time.Time
+
+	// shuttingDown makes ReadyCheck fail while the server drains; see StartShutdown
+	shuttingDown atomic.Bool
 }
 
 // NewHandler creates a new webhook handler instance with the provided logger, client, and snapshot checker.
 // This is the constructor function for the Handler struct.
 func NewHandler(logger *log.Logger, client kubernetes.Interface, snapshotChecker *SnapshotChecker) *Handler {
 	return &Handler{
-		Logger:         logger,
-		RiskCalculator: NewRiskCalculator(client, snapshotChecker),
+		Logger:                logger,
+		RiskCalculator:        NewRiskCalculator(client, snapshotChecker),
+		AssessmentTimeout:     DefaultAssessmentTimeout,
+		BypassLabel:           BypassLabel,
+		ExcludedNamespaces:    map[string]bool{},
+		ProtectStorageClasses: true,
+		ReadinessChecks:       defaultReadinessChecks(client, snapshotChecker),
+		now:                   time.Now,
 	}
 }
 
+// SetBypassLabel changes the bypass label key checked on deleted objects and shown in suggestions
+func (h *Handler) SetBypassLabel(label string) {
+	h.BypassLabel = label
+	h.RiskCalculator.SetBypassLabel(label)
+}
+
 // ServeHTTP is the main HTTP handler that implements the http.Handler interface.
 // This function is called by Kubernetes API server when an admission request is made.
 // It processes the incoming AdmissionReview request and returns an AdmissionReview response.
@@ -114,6 +164,14 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if _, err := w.Write(responseBytes); err != nil {
 		h.Logger.Printf("Error writing response: %v", err)
 	}
+
+	// Capture the review; Record only queues it for a background writer, so redaction
+	// and a slow disk never delay the response
+	if h.Recorder != nil {
+		if err := h.Recorder.Record(&admissionReview, &admissionResponse); err != nil {
+			h.Logger.Printf("Warning: Failed to capture admission review: %v", err)
+		}
+	}
 }
 
 // handleAdmissionRequest processes an individual admission request and generates a response.
@@ -141,10 +199,15 @@ func (h *Handler) handleAdmissionRequest(request *admissionv1.AdmissionRequest)
 	// Special handling for DELETE operations - assess risk and potentially block
 	if request.Operation == admissionv1.Delete {
 		h.logDeletion(request)
-		return h.assessAndDecide(request)
+		return h.checkCircuitBreaker(request, h.assessAndDecide(request))
 	}
 
-	// Non-DELETE operations are always allowed
+	// Updates may only remove or change a deletion lock when made by a lock admin
+	if request.Operation == admissionv1.Update {
+		return h.checkLockChange(request)
+	}
+
+	// Other operations are always allowed
 	return &admissionv1.AdmissionResponse{
 		UID:     request.UID,
 		Allowed: true,
@@ -156,15 +219,89 @@ func (h *Handler) handleAdmissionRequest(request *admissionv1.AdmissionRequest)
 
 // assessAndDecide performs risk assessment for DELETE operations and decides whether to allow or block
 func (h *Handler) assessAndDecide(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), h.AssessmentTimeout)
 	defer cancel()
 
 	kind := request.Kind.Kind
 	namespace := request.Namespace
 	name := request.Name
 
-	// Check for bypass label
-	if h.hasBypassLabel(request) {
+	// Deletion locks are absolute: they are checked before exclusions and the bypass label.
+	// A known lock denies even when the lookup of other locks failed, and a failed lookup
+	// always fails closed, whatever the failure policy.
+	locks, err := h.RiskCalculator.FindDeletionLocks(ctx, kind, namespace, name, h.oldObject(request))
+	if err != nil {
+		h.Logger.Printf("ERROR: Deletion lock lookup failed: %v", err)
+		if len(locks) == 0 {
+			return h.lockLookupFailed(request, err)
+		}
+	}
+	if len(locks) > 0 {
+		h.Logger.Printf("BLOCKING: %s %s/%s is locked against deletion", kind, namespace, name)
+		for _, lock := range locks {
+			h.Logger.Printf("  Lock: %s held by %s: %s", lock, lockHolder(lock), lockReason(lock))
+		}
+		return &admissionv1.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  "Failure",
+				Message: h.RiskCalculator.buildLockedMessage(kind, namespace, name, locks),
+				Reason:  metav1.StatusReasonForbidden,
+				Code:    403,
+				Details: &metav1.StatusDetails{
+					Name:   name,
+					Group:  request.Kind.Group,
+					Kind:   kind,
+					Causes: lockCauses(locks),
+				},
+			},
+		}
+	}
+
+	// Skip excluded namespaces, both for objects inside them and for the namespace itself
+	if h.isExcluded(request) {
+		h.Logger.Printf("Namespace excluded from protection - allowing %s %s/%s", kind, namespace, name)
+		return &admissionv1.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: true,
+			Result: &metav1.Status{
+				Message: "Deletion allowed - namespace excluded from protection",
+			},
+		}
+	}
+
+	// Change windows are checked before the bypass label, which they can override
+	now := h.now()
+	var closure *ChangeWindowClosure
+	if h.ChangeWindows != nil {
+		closure = h.ChangeWindows.Closure(now)
+	}
+	if closure != nil && closure.Applies(false) {
+		h.Logger.Printf("BLOCKING: Changes are closed (%s) - denying %s %s/%s", closure.Description, kind, namespace, name)
+		return &admissionv1.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  "Failure",
+				Message: h.closedMessage(request, closure, now),
+				Reason:  metav1.StatusReasonForbidden,
+				Code:    403,
+				Details: &metav1.StatusDetails{
+					Name:   name,
+					Group:  request.Kind.Group,
+					Kind:   kind,
+					Causes: []metav1.StatusCause{closureCause(closure)},
+				},
+			},
+		}
+	}
+
+	// Check for bypass label; while changes are closed it is not honoured
+	bypassed := h.hasBypassLabel(request)
+	if bypassed && closure != nil {
+		h.Logger.Printf("Bypass label on %s %s/%s ignored: changes are closed (%s)", kind, namespace, name, closure.Description)
+	} else if bypassed {
 		h.Logger.Printf("BYPASS: Force delete label found on %s %s/%s", kind, namespace, name)
 		h.Logger.Printf("  User: %s", request.UserInfo.Username)
 		h.Logger.Printf("  Allowing deletion despite potential data loss")
@@ -172,7 +309,20 @@ func (h *Handler) assessAndDecide(request *admissionv1.AdmissionRequest) *admiss
 			UID:     request.UID,
 			Allowed: true,
 			Result: &metav1.Status{
-				Message: fmt.Sprintf("Deletion allowed via bypass label %s", BypassLabel),
+				Message: fmt.Sprintf("Deletion allowed via bypass label %s", h.BypassLabel),
+			},
+		}
+	}
+
+	// Honour the protection label of the object, its claim or its namespace
+	protection := h.RiskCalculator.ResolveProtection(ctx, kind, namespace, name, h.oldObject(request))
+	if protection.Mode == ProtectionDisabled {
+		h.Logger.Printf("Protection disabled by %s - allowing %s %s/%s", protection, kind, namespace, name)
+		return &admissionv1.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: true,
+			Result: &metav1.Status{
+				Message: fmt.Sprintf("Deletion allowed - protection disabled by %s", protection),
 			},
 		}
 	}
@@ -180,7 +330,6 @@ func (h *Handler) assessAndDecide(request *admissionv1.AdmissionRequest) *admiss
 	h.Logger.Printf("Assessing risk for %s deletion: %s/%s", kind, namespace, name)
 
 	var assessment *RiskAssessment
-	var err error
 
 	switch kind {
 	case "Namespace":
@@ -189,6 +338,16 @@ func (h *Handler) assessAndDecide(request *admissionv1.AdmissionRequest) *admiss
 		assessment, err = h.RiskCalculator.AssessPVCDeletion(ctx, namespace, name)
 	case "PersistentVolume":
 		assessment, err = h.RiskCalculator.AssessPVDeletion(ctx, name)
+	case "StorageClass":
+		if !h.ProtectStorageClasses {
+			return &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true}
+		}
+		assessment, err = h.RiskCalculator.AssessStorageClassDeletion(ctx, name)
+	case "CSIDriver":
+		if !h.ProtectStorageClasses {
+			return &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true}
+		}
+		assessment, err = h.RiskCalculator.AssessCSIDriverDeletion(ctx, name)
 	default:
 		// Unknown resource type - allow by default
 		h.Logger.Printf("Unknown resource type %s - allowing", kind)
@@ -200,22 +359,26 @@ func (h *Handler) assessAndDecide(request *admissionv1.AdmissionRequest) *admiss
 
 	if err != nil {
 		h.Logger.Printf("ERROR: Risk assessment failed: %v", err)
-		// On error, allow the request (fail open for now)
-		return &admissionv1.AdmissionResponse{
-			UID:     request.UID,
-			Allowed: true,
-			Result: &metav1.Status{
-				Message: fmt.Sprintf("Risk assessment error (allowed): %v", err),
-			},
+		if closure != nil && !h.FailClosed {
+			// An unassessed deletion may be risky: while changes are closed it does not fail open
+			h.Logger.Printf("BLOCKING: Changes are closed (%s) - not failing open", closure.Description)
+			return h.closedDenial(request, closure, now, fmt.Sprintf("DELETION BLOCKED: risk assessment failed: %v\n", err))
 		}
+		return h.assessmentFailed(request, err)
 	}
 
 	if assessment.IsRisky {
 		h.Logger.Printf("BLOCKING: Risky deletion detected!")
 		h.Logger.Printf("  Reason: %s", assessment.Message)
 		h.Logger.Printf("  Risky PVCs: %d", len(assessment.RiskyPVCs))
+		h.Logger.Printf("  Unassessable PVCs: %d", len(assessment.UnknownPVCs))
 
-		message := assessment.Message + assessment.Suggestion
+		message := assessment.Message + h.requestSnapshots(ctx, request, assessment) + assessment.Suggestion
+		causes := assessment.Causes()
+		if closure != nil {
+			message += h.closedNote(request, closure, now)
+			causes = append(causes, closureCause(closure))
+		}
 
 		return &admissionv1.AdmissionResponse{
 			UID:     request.UID,
@@ -225,45 +388,346 @@ func (h *Handler) assessAndDecide(request *admissionv1.AdmissionRequest) *admiss
 				Message: message,
 				Reason:  metav1.StatusReasonForbidden,
 				Code:    403,
+				Details: &metav1.StatusDetails{
+					Name:   name,
+					Group:  request.Kind.Group,
+					Kind:   kind,
+					Causes: causes,
+				},
 			},
 		}
 	}
 
+	// Deletions allowed only with warnings, e.g. by warn protection, are risky too
+	if closure != nil && closure.Applies(len(assessment.Warnings) > 0) {
+		h.Logger.Printf("BLOCKING: Changes are closed (%s) - denying warned deletion", closure.Description)
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("DELETION BLOCKED: %s '%s' cannot be deleted while changes are closed\n\nWarnings:\n",
+			kind, DeletionLock{Namespace: namespace, Name: name}.field()))
+		for _, warning := range assessment.Warnings {
+			sb.WriteString(fmt.Sprintf("  - %s\n", warning))
+		}
+		return h.closedDenial(request, closure, now, sb.String())
+	}
+
 	h.Logger.Printf("ALLOWING: Deletion is safe")
 	if assessment.Message != "" {
 		h.Logger.Printf("  Reason: %s", assessment.Message)
 	}
+	for _, warning := range assessment.Warnings {
+		h.Logger.Printf("  Warning: %s", warning)
+	}
 
+	return &admissionv1.AdmissionResponse{
+		UID:      request.UID,
+		Allowed:  true,
+		Warnings: assessment.Warnings,
+		Result: &metav1.Status{
+			Message: "Deletion allowed - safe operation",
+		},
+	}
+}
+
+// assessmentFailed denies or allows a deletion whose assessment failed, depending on FailClosed
+func (h *Handler) assessmentFailed(request *admissionv1.AdmissionRequest, err error) *admissionv1.AdmissionResponse {
+	if h.FailClosed {
+		data := &MessageData{
+			Kind:        request.Kind.Kind,
+			Namespace:   request.Namespace,
+			Name:        request.Name,
+			BypassLabel: h.BypassLabel,
+			Error:       err.Error(),
+		}
+		message := h.RiskCalculator.templates.render(TemplateAssessmentFailed, data, func() string {
+			return fmt.Sprintf("DELETION BLOCKED: risk assessment failed: %v\n\nRetry later, or force delete with the %s=true label", err, h.BypassLabel)
+		})
+		return &admissionv1.AdmissionResponse{
+			UID:     request.UID,
+			Allowed: false,
+			Result: &metav1.Status{
+				Status:  "Failure",
+				Message: message,
+				Reason:  metav1.StatusReasonServiceUnavailable,
+				Code:    503,
+			},
+		}
+	}
+
+	// Fail open: allow the request
 	return &admissionv1.AdmissionResponse{
 		UID:     request.UID,
 		Allowed: true,
 		Result: &metav1.Status{
-			Message: "Deletion allowed - safe operation",
+			Message: fmt.Sprintf("Risk assessment error (allowed): %v", err),
+		},
+	}
+}
+
+// closedMessage renders the denial of a deletion refused because changes are closed
+func (h *Handler) closedMessage(request *admissionv1.AdmissionRequest, closure *ChangeWindowClosure, now time.Time) string {
+	return h.RiskCalculator.templates.render(TemplateChangeClosed, h.closureData(request, closure), func() string {
+		return h.ChangeWindows.buildClosedMessage(request.Kind.Kind, request.Namespace, request.Name, closure, now)
+	})
+}
+
+// closedNote renders the note appended to a risky deletion's denial while changes are closed
+func (h *Handler) closedNote(request *admissionv1.AdmissionRequest, closure *ChangeWindowClosure, now time.Time) string {
+	return h.RiskCalculator.templates.render(TemplateChangeClosedNote, h.closureData(request, closure), func() string {
+		return h.ChangeWindows.closedNote(closure, now)
+	})
+}
+
+// closureData returns the template data for a deletion denied while changes are closed
+func (h *Handler) closureData(request *admissionv1.AdmissionRequest, closure *ChangeWindowClosure) *MessageData {
+	data := h.RiskCalculator.messageData(request.Kind.Kind, request.Namespace, request.Name, nil, nil)
+	data.Closure = closure
+	return data
+}
+
+// closedDenial denies a risky deletion while changes are closed, appending the closure
+// note to the summary of why the deletion is risky
+func (h *Handler) closedDenial(request *admissionv1.AdmissionRequest, closure *ChangeWindowClosure, now time.Time, summary string) *admissionv1.AdmissionResponse {
+	return &admissionv1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  "Failure",
+			Message: summary + h.closedNote(request, closure, now),
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    403,
+			Details: &metav1.StatusDetails{
+				Name:   request.Name,
+				Group:  request.Kind.Group,
+				Kind:   request.Kind.Kind,
+				Causes: []metav1.StatusCause{closureCause(closure)},
+			},
+		},
+	}
+}
+
+// lockLookupFailed denies a deletion whose deletion locks could not be looked up. Unlike
+// other assessment failures it ignores FailClosed: a lock must never be lost to an API error.
+func (h *Handler) lockLookupFailed(request *admissionv1.AdmissionRequest, err error) *admissionv1.AdmissionResponse {
+	data := &MessageData{
+		Kind:        request.Kind.Kind,
+		Namespace:   request.Namespace,
+		Name:        request.Name,
+		BypassLabel: h.BypassLabel,
+		Error:       err.Error(),
+	}
+	message := h.RiskCalculator.templates.render(TemplateLockLookupFailed, data, func() string {
+		return fmt.Sprintf("DELETION BLOCKED: deletion lock lookup failed: %v\n\nRetry later; deletion locks cannot be overridden", err)
+	})
+	return &admissionv1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  "Failure",
+			Message: message,
+			Reason:  metav1.StatusReasonServiceUnavailable,
+			Code:    503,
+		},
+	}
+}
+
+// checkCircuitBreaker counts a deletion the assessment allowed against the circuit
+// breaker, and denies it when the breaker is or becomes tripped. Denied deletions are
+// not counted, so retries of blocked deletions cannot trip the breaker.
+func (h *Handler) checkCircuitBreaker(request *admissionv1.AdmissionRequest, response *admissionv1.AdmissionResponse) *admissionv1.AdmissionResponse {
+	if h.CircuitBreaker == nil || !response.Allowed || !breakerKinds[request.Kind.Kind] || h.isExcluded(request) {
+		return response
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.AssessmentTimeout)
+	defer cancel()
+
+	dryRun := request.DryRun != nil && *request.DryRun
+	trip := h.CircuitBreaker.Admit(ctx, request.UserInfo.Username, dryRun)
+	if trip == nil {
+		return response
+	}
+
+	circuitBreakerDenials.Inc()
+	h.Logger.Printf("BLOCKING: Circuit breaker tripped (%s) - denying %s %s/%s by %s",
+		trip, request.Kind.Kind, request.Namespace, request.Name, request.UserInfo.Username)
+	return &admissionv1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  "Failure",
+			Message: h.breakerMessage(request, trip),
+			Reason:  metav1.StatusReasonForbidden,
+			Code:    403,
+			Details: &metav1.StatusDetails{
+				Name:  request.Name,
+				Group: request.Kind.Group,
+				Kind:  request.Kind.Kind,
+				Causes: []metav1.StatusCause{{
+					Type:    ReasonCircuitBreakerOpen,
+					Message: trip.String(),
+				}},
+			},
 		},
 	}
 }
 
+// breakerMessage renders the denial of a deletion refused by a tripped circuit breaker
+func (h *Handler) breakerMessage(request *admissionv1.AdmissionRequest, trip *BreakerTrip) string {
+	data := h.RiskCalculator.messageData(request.Kind.Kind, request.Namespace, request.Name, nil, nil)
+	data.Trip = trip
+	data.ResetCommand = h.CircuitBreaker.ResetCommand()
+
+	return h.RiskCalculator.templates.render(TemplateBreakerBlock, data, func() string {
+		return h.CircuitBreaker.buildBreakerMessage(request.Kind.Kind, request.Namespace, request.Name, trip)
+	})
+}
+
+// checkLockChange denies updates that remove or change the deletion lock or its holder,
+// unless they are made by a lock admin. Adding a lock is always allowed.
+func (h *Handler) checkLockChange(request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	allowed := &admissionv1.AdmissionResponse{UID: request.UID, Allowed: true}
+
+	oldObject := h.oldObject(request)
+	if oldObject == nil {
+		return allowed
+	}
+	lock := deletionLockOf(request.Kind.Kind, oldObject)
+	if lock == nil {
+		return allowed
+	}
+
+	var newAnnotations map[string]string
+	if request.Object.Raw != nil {
+		var newObject unstructured.Unstructured
+		if err := json.Unmarshal(request.Object.Raw, &newObject); err != nil {
+			h.Logger.Printf("Warning: Failed to parse Object for deletion lock check: %v", err)
+		}
+		newAnnotations = newObject.GetAnnotations()
+	}
+
+	reason, stillLocked := newAnnotations[DeletionLockAnnotation]
+	if stillLocked && reason == lock.Reason && newAnnotations[DeletionLockHolderAnnotation] == lock.Holder {
+		return allowed
+	}
+
+	if h.isLockAdmin(request.UserInfo.Username, request.UserInfo.Groups) {
+		h.Logger.Printf("UNLOCK: %s changed the deletion lock of %s", request.UserInfo.Username, lock)
+		return allowed
+	}
+
+	h.Logger.Printf("BLOCKING: %s may not change the deletion lock of %s", request.UserInfo.Username, lock)
+	return &admissionv1.AdmissionResponse{
+		UID:     request.UID,
+		Allowed: false,
+		Result: &metav1.Status{
+			Status: "Failure",
+			Message: fmt.Sprintf("DELETION LOCK: %s is locked by %s (%s); only deletion lock admins may remove or change the %s and %s annotations",
+				lock, lockHolder(*lock), lockReason(*lock), DeletionLockAnnotation, DeletionLockHolderAnnotation),
+			Reason: metav1.StatusReasonForbidden,
+			Code:   403,
+		},
+	}
+}
+
+// isLockAdmin reports whether a user, or one of their groups, may change deletion locks
+func (h *Handler) isLockAdmin(username string, groups []string) bool {
+	for _, admin := range h.LockAdminUsers {
+		if username == admin {
+			return true
+		}
+	}
+	for _, group := range groups {
+		for _, admin := range h.LockAdminGroups {
+			if group == admin {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requestSnapshots creates VolumeSnapshots for the risky PVCs of a blocked deletion when
+// automatic snapshots are enabled, and returns the message describing them.
+// Dry-run requests never create snapshots.
+func (h *Handler) requestSnapshots(ctx context.Context, request *admissionv1.AdmissionRequest, assessment *RiskAssessment) string {
+	if h.AutoSnapshotter == nil {
+		return ""
+	}
+
+	kind := request.Kind.Kind
+	if kind != "PersistentVolumeClaim" && kind != "Namespace" {
+		return ""
+	}
+
+	if request.DryRun != nil && *request.DryRun {
+		h.Logger.Printf("  Dry run: skipping automatic snapshot creation")
+		return ""
+	}
+
+	pending := h.AutoSnapshotter.EnsureSnapshots(ctx, assessment.RiskyPVCs)
+	for _, snapshot := range pending {
+		switch {
+		case snapshot.Error != nil:
+			h.Logger.Printf("  Auto-snapshot failed for %s/%s: %v", snapshot.Namespace, snapshot.SourcePVC, snapshot.Error)
+		case snapshot.Created:
+			h.Logger.Printf("  Auto-snapshot created: %s/%s", snapshot.Namespace, snapshot.Name)
+		default:
+			h.Logger.Printf("  Auto-snapshot already in progress: %s/%s", snapshot.Namespace, snapshot.Name)
+		}
+	}
+
+	return buildAutoSnapshotMessage(pending)
+}
+
 // hasBypassLabel checks if the resource being deleted has the bypass label
 func (h *Handler) hasBypassLabel(request *admissionv1.AdmissionRequest) bool {
+	labels := h.oldObjectLabels(request)
+	if labels == nil {
+		return false
+	}
+
+	value, exists := labels[h.BypassLabel]
+	return exists && value == "true"
+}
+
+// oldObject returns the resource being deleted or updated, or nil when the request
+// carries no old object or it cannot be parsed
+func (h *Handler) oldObject(request *admissionv1.AdmissionRequest) *unstructured.Unstructured {
 	// For DELETE operations, the resource being deleted is in OldObject
 	if request.OldObject.Raw == nil {
-		return false
+		return nil
 	}
 
-	// Parse the OldObject to extract labels
 	var obj unstructured.Unstructured
 	if err := json.Unmarshal(request.OldObject.Raw, &obj); err != nil {
-		h.Logger.Printf("Warning: Failed to parse OldObject for bypass check: %v", err)
-		return false
+		h.Logger.Printf("Warning: Failed to parse OldObject: %v", err)
+		return nil
+	}
+	return &obj
+}
+
+// oldObjectLabels returns the labels of the resource being deleted, or nil when the
+// request carries no old object
+func (h *Handler) oldObjectLabels(request *admissionv1.AdmissionRequest) map[string]string {
+	obj := h.oldObject(request)
+	if obj == nil {
+		return nil
 	}
 
 	labels := obj.GetLabels()
 	if labels == nil {
-		return false
+		// The old object is known to have no labels; don't look it up again
+		return map[string]string{}
 	}
+	return labels
+}
 
-	value, exists := labels[BypassLabel]
-	return exists && value == "true"
+// isExcluded checks if the request targets an excluded namespace or an object inside one
+func (h *Handler) isExcluded(request *admissionv1.AdmissionRequest) bool {
+	if request.Kind.Kind == "Namespace" {
+		return h.ExcludedNamespaces[request.Name]
+	}
+	return request.Namespace != "" && h.ExcludedNamespaces[request.Namespace]
 }
 
 // logDeletion provides specialized logging for DELETE operations on critical resources.
@@ -275,6 +739,8 @@ func (h *Handler) hasBypassLabel(request *admissionv1.AdmissionRequest) bool {
 //   - PersistentVolumeClaim (PVC): Deleting a PVC can cause data loss
 //   - PersistentVolume (PV): Deleting a PV can cause permanent data loss
 //
+// StorageClass and CSIDriver deletions use the generic log line.
+//
 // Parameters:
 //   - request: The admission request containing deletion details
 func (h *Handler) logDeletion(request *admissionv1.AdmissionRequest) {
@@ -314,8 +780,8 @@ func (h *Handler) logDeletion(request *admissionv1.AdmissionRequest) {
 	}
 }
 
-// HealthCheck is a simple health check endpoint that can be used by Kubernetes
-// liveness and readiness probes to verify the webhook service is running.
+// HealthCheck is the liveness endpoint. It only verifies that the webhook process is
+// serving HTTP; dependencies are checked by ReadyCheck.
 // Returns HTTP 200 with "OK" message when the service is healthy.
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,93 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// serveReview sends an admission request through the handler and returns its response
+func serveReview(t *testing.T, handler *Handler, request *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	t.Helper()
+
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  request,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(rec.Body.Bytes(), &review); err != nil {
+		t.Fatal(err)
+	}
+	return review.Response
+}
+
+func TestNamespaceDenialDetails(t *testing.T) {
+	objects := newNamespaceObjects(maxMessagePVCs+2, corev1.PersistentVolumeReclaimDelete)
+	objects = append(objects, newNamespacePVC("lost", corev1.ClaimLost, "pv-lost"))
+
+	handler := NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(objects...), nil)
+	handler.RiskCalculator.SetUnknownPolicy(UnknownPolicyBlock)
+
+	response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+		UID:       "uid-1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+		Operation: admissionv1.Delete,
+		Name:      "app",
+	})
+
+	if response.Allowed || response.Result == nil || response.Result.Details == nil {
+		t.Fatalf("response = %+v, want a denial with details", response)
+	}
+	details := response.Result.Details
+	if details.Kind != "Namespace" || details.Name != "app" {
+		t.Errorf("details identify %s %s, want Namespace app", details.Kind, details.Name)
+	}
+
+	if len(details.Causes) != maxMessagePVCs+3 {
+		t.Fatalf("got %d causes, want one per risky and unassessable PVC (%d)", len(details.Causes), maxMessagePVCs+3)
+	}
+	wantFirst := metav1.StatusCause{
+		Type:    ReasonReclaimPolicy,
+		Field:   "app/data-0000",
+		Message: "pv=pv-0000 snapshot=None: PV has Delete reclaim policy, no snapshot found",
+	}
+	if details.Causes[0] != wantFirst {
+		t.Errorf("first cause = %+v, want %+v", details.Causes[0], wantFirst)
+	}
+	wantLast := metav1.StatusCause{
+		Type:    ReasonUnassessable,
+		Field:   "app/lost",
+		Message: "pv=pv-lost snapshot=Unknown: PVC is Lost",
+	}
+	if last := details.Causes[len(details.Causes)-1]; last != wantLast {
+		t.Errorf("last cause = %+v, want %+v", last, wantLast)
+	}
+
+	message := response.Result.Message
+	if !strings.Contains(message, "  ... and 2 more (all PVCs are listed in the response's status details)\n") {
+		t.Errorf("message does not summarize the omitted PVCs:\n%s", message)
+	}
+	if strings.Contains(message, "data-0011") || strings.Contains(message, "pv-0011") {
+		t.Errorf("message lists PVCs beyond the first %d:\n%s", maxMessagePVCs, message)
+	}
+}
@@ -0,0 +1,234 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// DeletionLockAnnotation locks a Namespace, PVC or PV against deletion; its value is the reason
+	DeletionLockAnnotation = "pv-safe.io/deletion-lock"
+	// DeletionLockHolderAnnotation names who holds a deletion lock
+	DeletionLockHolderAnnotation = "pv-safe.io/deletion-lock-holder"
+
+	// ReasonDeletionLocked is the status cause type of a deletion denied by a lock
+	ReasonDeletionLocked = "DeletionLocked"
+)
+
+// DeletionLock is a deletion lock annotation found on an object
+type DeletionLock struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Holder    string
+	Reason    string
+}
+
+// String identifies the locked object, e.g. "PersistentVolumeClaim app/db"
+func (l DeletionLock) String() string {
+	return l.Kind + " " + l.field()
+}
+
+// field is the namespace/name of a namespaced object, or the name of a cluster-scoped one
+func (l DeletionLock) field() string {
+	if l.Namespace == "" {
+		return l.Name
+	}
+	return l.Namespace + "/" + l.Name
+}
+
+// deletionLockOf returns the lock of an object, or nil when it has none
+func deletionLockOf(kind string, obj metav1.Object) *DeletionLock {
+	annotations := obj.GetAnnotations()
+	reason, locked := annotations[DeletionLockAnnotation]
+	if !locked {
+		return nil
+	}
+
+	return &DeletionLock{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Holder:    annotations[DeletionLockHolderAnnotation],
+		Reason:    reason,
+	}
+}
+
+// FindDeletionLocks returns the locks that forbid deleting an object: its own lock, the
+// locks of the PVCs in a namespace, and the locks of PVs with a Delete reclaim policy that
+// would be deleted along with their claims. oldObject is the object from the admission
+// request; when nil the object is read from the API. Objects that no longer exist have
+// no locks. When a lookup fails, the locks found so far are returned with the error, so
+// that a known lock still denies the deletion.
+func (rc *RiskCalculator) FindDeletionLocks(ctx context.Context, kind, namespace, name string, oldObject *unstructured.Unstructured) ([]DeletionLock, error) {
+	switch kind {
+	case "Namespace":
+		return rc.namespaceDeletionLocks(ctx, name, oldObject)
+	case "PersistentVolumeClaim":
+		pvc, err := decodeOrGet(oldObject, func() (*corev1.PersistentVolumeClaim, error) {
+			return rc.client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+		})
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		return rc.claimDeletionLocks(ctx, []corev1.PersistentVolumeClaim{*pvc}, nil)
+	case "PersistentVolume":
+		pv, err := decodeOrGet(oldObject, func() (*corev1.PersistentVolume, error) {
+			return rc.client.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
+		})
+		if err != nil {
+			return nil, ignoreNotFound(err)
+		}
+		if lock := deletionLockOf(kind, pv); lock != nil {
+			return []DeletionLock{*lock}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// namespaceDeletionLocks returns the locks of a namespace and of its PVCs and their PVs
+func (rc *RiskCalculator) namespaceDeletionLocks(ctx context.Context, namespace string, oldObject *unstructured.Unstructured) ([]DeletionLock, error) {
+	ns, err := decodeOrGet(oldObject, func() (*corev1.Namespace, error) {
+		return rc.client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	})
+	if err != nil {
+		return nil, ignoreNotFound(err)
+	}
+
+	var locks []DeletionLock
+	if lock := deletionLockOf("Namespace", ns); lock != nil {
+		locks = append(locks, *lock)
+	}
+
+	pvcs, err := rc.client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return locks, fmt.Errorf("failed to list PVCs in namespace %s: %w", namespace, err)
+	}
+	if len(pvcs.Items) == 0 {
+		return locks, nil
+	}
+
+	claimLocks, err := rc.claimDeletionLocks(ctx, pvcs.Items, rc.listPVsByName(ctx))
+	return append(locks, claimLocks...), err
+}
+
+// claimDeletionLocks returns the locks of PVCs and of their PVs with a Delete reclaim
+// policy. PVs missing from pvsByName are read individually; PVs that cannot be read are
+// skipped and reported in the error, after all PVCs have been checked.
+func (rc *RiskCalculator) claimDeletionLocks(ctx context.Context, pvcs []corev1.PersistentVolumeClaim, pvsByName map[string]*corev1.PersistentVolume) ([]DeletionLock, error) {
+	var locks []DeletionLock
+	var errs []error
+
+	for i := range pvcs {
+		pvc := &pvcs[i]
+		if lock := deletionLockOf("PersistentVolumeClaim", pvc); lock != nil {
+			locks = append(locks, *lock)
+		}
+
+		if pvc.Spec.VolumeName == "" {
+			continue
+		}
+		pv, found := pvsByName[pvc.Spec.VolumeName]
+		if !found {
+			var err error
+			pv, err = rc.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err))
+				continue
+			}
+		}
+
+		if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimDelete {
+			continue
+		}
+		if lock := deletionLockOf("PersistentVolume", pv); lock != nil {
+			locks = append(locks, *lock)
+		}
+	}
+
+	return locks, errors.Join(errs...)
+}
+
+// decodeOrGet decodes the admission request's old object, or reads the object with get
+// when the request has none
+func decodeOrGet[T any](oldObject *unstructured.Unstructured, get func() (*T, error)) (*T, error) {
+	if oldObject == nil {
+		return get()
+	}
+
+	obj := new(T)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(oldObject.Object, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode old object: %w", err)
+	}
+	return obj, nil
+}
+
+// ignoreNotFound drops NotFound errors: an object that is already gone has no lock
+func ignoreNotFound(err error) error {
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// buildLockedMessage creates the denial message for a deletion forbidden by locks
+func (rc *RiskCalculator) buildLockedMessage(kind, namespace, name string, locks []DeletionLock) string {
+	data := rc.messageData(kind, namespace, name, nil, nil)
+	data.Locks = locks
+
+	return rc.templates.render(TemplateLockBlock, data, func() string {
+		var sb strings.Builder
+
+		object := DeletionLock{Namespace: namespace, Name: name}.field()
+		sb.WriteString(fmt.Sprintf("DELETION LOCKED: %s '%s' cannot be deleted\n\nLocks:\n", kind, object))
+		for _, lock := range locks {
+			sb.WriteString(fmt.Sprintf("  - %s, held by %s: %s\n", lock, lockHolder(lock), lockReason(lock)))
+		}
+
+		sb.WriteString("\nDeletion locks cannot be overridden: snapshots, Retain reclaim policies and labels are ignored.\n")
+		sb.WriteString(fmt.Sprintf("Ask the lock holder: only authorized identities can remove the %s annotation.\n", DeletionLockAnnotation))
+
+		return sb.String()
+	})
+}
+
+// lockCauses returns one status cause per lock
+func lockCauses(locks []DeletionLock) []metav1.StatusCause {
+	causes := make([]metav1.StatusCause, 0, len(locks))
+	for _, lock := range locks {
+		causes = append(causes, metav1.StatusCause{
+			Type:    ReasonDeletionLocked,
+			Field:   lock.field(),
+			Message: fmt.Sprintf("%s holder=%s: %s", lock.Kind, lockHolder(lock), lockReason(lock)),
+		})
+	}
+	return causes
+}
+
+// lockHolder returns the holder of a lock for messages
+func lockHolder(lock DeletionLock) string {
+	if lock.Holder == "" {
+		return "(unknown holder)"
+	}
+	return lock.Holder
+}
+
+// lockReason returns the reason of a lock for messages
+func lockReason(lock DeletionLock) string {
+	if lock.Reason == "" {
+		return "(no reason given)"
+	}
+	return lock.Reason
+}
@@ -0,0 +1,271 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func withLock[T metav1.Object](obj T, holder, reason string) T {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[DeletionLockAnnotation] = reason
+	if holder != "" {
+		annotations[DeletionLockHolderAnnotation] = holder
+	}
+	obj.SetAnnotations(annotations)
+	return obj
+}
+
+func rawPVC(t *testing.T, pvc *corev1.PersistentVolumeClaim) runtime.RawExtension {
+	t.Helper()
+
+	pvc = pvc.DeepCopy()
+	pvc.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "PersistentVolumeClaim"}
+	raw, err := json.Marshal(pvc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestFindDeletionLocks(t *testing.T) {
+	tests := []struct {
+		name      string
+		kind      string
+		namespace string
+		objName   string
+		objects   func() []runtime.Object
+		want      []string
+	}{
+		{
+			name:      "unlocked PVC",
+			kind:      "PersistentVolumeClaim",
+			namespace: "app",
+			objName:   "data-0000",
+			objects: func() []runtime.Object {
+				return newNamespaceObjects(1, corev1.PersistentVolumeReclaimDelete)
+			},
+		},
+		{
+			name:      "PVC locked by its Delete PV",
+			kind:      "PersistentVolumeClaim",
+			namespace: "app",
+			objName:   "data-0000",
+			objects: func() []runtime.Object {
+				objects := newNamespaceObjects(1, corev1.PersistentVolumeReclaimDelete)
+				withLock(objects[1].(*corev1.PersistentVolume), "dba", "primary")
+				return objects
+			},
+			want: []string{"PersistentVolume pv-0000"},
+		},
+		{
+			name:      "Retain PV lock does not lock its PVC",
+			kind:      "PersistentVolumeClaim",
+			namespace: "app",
+			objName:   "data-0000",
+			objects: func() []runtime.Object {
+				objects := newNamespaceObjects(1, corev1.PersistentVolumeReclaimRetain)
+				withLock(objects[1].(*corev1.PersistentVolume), "dba", "primary")
+				return objects
+			},
+		},
+		{
+			name:    "namespace locked by its PVCs and PVs",
+			kind:    "Namespace",
+			objName: "app",
+			objects: func() []runtime.Object {
+				objects := newNamespaceObjects(3, corev1.PersistentVolumeReclaimDelete)
+				withLock(objects[0].(*corev1.PersistentVolumeClaim), "dba", "primary")
+				withLock(objects[5].(*corev1.PersistentVolume), "", "")
+				return append(objects, withLock(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "app"}}, "platform", "shared"))
+			},
+			want: []string{"Namespace app", "PersistentVolumeClaim app/data-0000", "PersistentVolume pv-0002"},
+		},
+		{
+			name:    "locked PV",
+			kind:    "PersistentVolume",
+			objName: "pv-data",
+			objects: func() []runtime.Object {
+				return []runtime.Object{withLock(newCSIPV("vol-1"), "storage", "migration")}
+			},
+			want: []string{"PersistentVolume pv-data"},
+		},
+		{
+			name:      "missing PVC",
+			kind:      "PersistentVolumeClaim",
+			namespace: "app",
+			objName:   "gone",
+			objects:   func() []runtime.Object { return nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := NewRiskCalculator(fake.NewClientset(tt.objects()...), nil)
+			locks, err := rc.FindDeletionLocks(context.Background(), tt.kind, tt.namespace, tt.objName, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]string, 0, len(locks))
+			for _, lock := range locks {
+				got = append(got, lock.String())
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("locks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerDeniesLockedDeletionDespiteBypass(t *testing.T) {
+	pvc := withLock(newNamespacePVC("data", corev1.ClaimBound, "pv-data"), "dba-team", "primary orders database")
+	pvc.Labels = map[string]string{BypassLabel: "true"}
+	client := fake.NewClientset(pvc, newPhasedPV("pv-data", corev1.PersistentVolumeReclaimRetain, corev1.VolumeBound, nil))
+	handler := NewHandler(log.New(io.Discard, "", 0), client, nil)
+
+	response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+		UID:       "uid-1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+		Operation: admissionv1.Delete,
+		Namespace: "app",
+		Name:      "data",
+		OldObject: rawPVC(t, pvc),
+	})
+
+	if response.Allowed {
+		t.Fatal("expected the locked deletion to be denied")
+	}
+	for _, want := range []string{
+		"DELETION LOCKED: PersistentVolumeClaim 'app/data' cannot be deleted",
+		"PersistentVolumeClaim app/data, held by dba-team: primary orders database",
+	} {
+		if !strings.Contains(response.Result.Message, want) {
+			t.Errorf("message = %q, want it to contain %q", response.Result.Message, want)
+		}
+	}
+	if strings.Contains(response.Result.Message, BypassLabel) {
+		t.Errorf("message = %q, want the bypass label left unnamed", response.Result.Message)
+	}
+
+	causes := response.Result.Details.Causes
+	if len(causes) != 1 || causes[0].Type != ReasonDeletionLocked || causes[0].Field != "app/data" ||
+		causes[0].Message != "PersistentVolumeClaim holder=dba-team: primary orders database" {
+		t.Errorf("causes = %+v, want one DeletionLocked cause for app/data", causes)
+	}
+}
+
+func TestHandlerDeletionLockLookupFailureFailsClosed(t *testing.T) {
+	unlocked := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	locked := withLock(unlocked.DeepCopy(), "dba-team", "primary")
+
+	tests := []struct {
+		name        string
+		pvc         *corev1.PersistentVolumeClaim
+		wantMessage string
+		wantCode    int32
+	}{
+		{
+			name:        "known lock still denies",
+			pvc:         locked,
+			wantMessage: "DELETION LOCKED: PersistentVolumeClaim 'app/data' cannot be deleted",
+			wantCode:    403,
+		},
+		{
+			name:        "failed lookup denies despite fail-open",
+			pvc:         unlocked,
+			wantMessage: "DELETION BLOCKED: deletion lock lookup failed",
+			wantCode:    503,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset(tt.pvc)
+			client.PrependReactor("get", "persistentvolumes", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("connection refused")
+			})
+			handler := NewHandler(log.New(io.Discard, "", 0), client, nil)
+			handler.FailClosed = false
+
+			response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+				UID:       "uid-1",
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+				Operation: admissionv1.Delete,
+				Namespace: "app",
+				Name:      "data",
+				OldObject: rawPVC(t, tt.pvc),
+			})
+
+			if response.Allowed {
+				t.Fatal("expected the deletion to be denied")
+			}
+			if !strings.Contains(response.Result.Message, tt.wantMessage) || response.Result.Code != tt.wantCode {
+				t.Errorf("result = %d %q, want %d containing %q", response.Result.Code, response.Result.Message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestHandlerGuardsDeletionLockChanges(t *testing.T) {
+	unlocked := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	locked := withLock(unlocked.DeepCopy(), "dba-team", "primary")
+	relabelled := locked.DeepCopy()
+	relabelled.Labels = map[string]string{"tier": "gold"}
+	reassigned := withLock(locked.DeepCopy(), "web-team", "primary")
+
+	tests := []struct {
+		name        string
+		old, new    *corev1.PersistentVolumeClaim
+		user        authenticationv1.UserInfo
+		wantAllowed bool
+	}{
+		{name: "adding a lock", old: unlocked, new: locked, wantAllowed: true},
+		{name: "other changes to a locked object", old: locked, new: relabelled, wantAllowed: true},
+		{name: "removing a lock", old: locked, new: unlocked},
+		{name: "changing the holder", old: locked, new: reassigned},
+		{name: "lock admin user removes a lock", old: locked, new: unlocked, user: authenticationv1.UserInfo{Username: "jane"}, wantAllowed: true},
+		{name: "lock admin group removes a lock", old: locked, new: unlocked, user: authenticationv1.UserInfo{Username: "bob", Groups: []string{"storage-admins"}}, wantAllowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(), nil)
+			handler.LockAdminUsers = []string{"jane"}
+			handler.LockAdminGroups = []string{"storage-admins"}
+
+			response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+				UID:       "uid-1",
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+				Operation: admissionv1.Update,
+				Namespace: "app",
+				Name:      "data",
+				UserInfo:  tt.user,
+				Object:    rawPVC(t, tt.new),
+				OldObject: rawPVC(t, tt.old),
+			})
+
+			if response.Allowed != tt.wantAllowed {
+				t.Fatalf("Allowed = %v, want %v (%+v)", response.Allowed, tt.wantAllowed, response.Result)
+			}
+			if !tt.wantAllowed && !strings.Contains(response.Result.Message, "PersistentVolumeClaim app/data is locked by dba-team (primary)") {
+				t.Errorf("message = %q, want it to name the lock", response.Result.Message)
+			}
+		})
+	}
+}
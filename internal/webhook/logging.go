@@ -0,0 +1,66 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewLogger creates the webhook logger. The "text" format keeps the classic prefixed log
+// lines; "json" writes one JSON object per line for log aggregation systems.
+func NewLogger(out io.Writer, format string) (*log.Logger, error) {
+	switch format {
+	case "text":
+		return log.New(out, "[pv-safe-webhook] ", log.LstdFlags|log.Lshortfile), nil
+	case "json":
+		return log.New(&jsonLogWriter{out: out}, "", log.Lshortfile), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q", format)
+	}
+}
+
+// jsonLogWriter converts each line written by a log.Logger into a JSON object
+type jsonLogWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// jsonLogEntry is one JSON log line
+type jsonLogEntry struct {
+	Time      string `json:"time"`
+	Component string `json:"component"`
+	Caller    string `json:"caller,omitempty"`
+	Message   string `json:"msg"`
+}
+
+// Write encodes a log line as JSON. With log.Lshortfile the line starts with "file:line: ".
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+
+	entry := jsonLogEntry{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Component: "pv-safe-webhook",
+		Message:   line,
+	}
+	if caller, message, found := strings.Cut(line, ": "); found && !strings.Contains(caller, " ") {
+		entry.Caller = caller
+		entry.Message = message
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.out.Write(append(data, '\n')); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,260 @@
+package webhook
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Names of the templates that replace the built-in denial messages and suggestions
+const (
+	TemplatePVCBlock             = "pvc-block"
+	TemplatePVCSuggestion        = "pvc-suggestion"
+	TemplateNamespaceBlock       = "namespace-block"
+	TemplateNamespaceSuggestion  = "namespace-suggestion"
+	TemplatePVBlock              = "pv-block"
+	TemplatePVSuggestion         = "pv-suggestion"
+	TemplateRetainedPVBlock      = "retained-pv-block"
+	TemplateRetainedPVSuggestion = "retained-pv-suggestion"
+	TemplateStorageBlock         = "storage-block"
+	TemplateStorageSuggestion    = "storage-suggestion"
+	TemplateAssessmentFailed     = "assessment-failed"
+	TemplateLockBlock            = "lock-block"
+	TemplateLockLookupFailed     = "lock-lookup-failed"
+	TemplateChangeClosed         = "change-closed"
+	TemplateChangeClosedNote     = "change-closed-note"
+	TemplateBreakerBlock         = "breaker-block"
+)
+
+// messageTemplateNames lists every template name a message file may define
+var messageTemplateNames = []string{
+	TemplatePVCBlock, TemplatePVCSuggestion,
+	TemplateNamespaceBlock, TemplateNamespaceSuggestion,
+	TemplatePVBlock, TemplatePVSuggestion,
+	TemplateRetainedPVBlock, TemplateRetainedPVSuggestion,
+	TemplateStorageBlock, TemplateStorageSuggestion,
+	TemplateAssessmentFailed,
+	TemplateLockBlock, TemplateLockLookupFailed,
+	TemplateChangeClosed, TemplateChangeClosedNote,
+	TemplateBreakerBlock,
+}
+
+var messageTemplateErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "pv_safe_message_template_errors_total",
+	Help: "Number of times a message template failed to execute and the built-in text was used, by template.",
+}, []string{"template"})
+
+// MessageData is the data a message template is executed with
+type MessageData struct {
+	// Kind is Namespace, PersistentVolumeClaim, PersistentVolume, StorageClass or CSIDriver
+	Kind      string
+	Namespace string
+	Name      string
+	// PV is the PersistentVolume being deleted, or the PVC's volume
+	PV          *corev1.PersistentVolume
+	Assessment  *RiskAssessment
+	BypassLabel string
+	// BoundPVs and UnboundPVs are the PVs still using the StorageClass or CSIDriver being
+	// deleted, and SnapshotClasses the VolumeSnapshotClasses of the CSIDriver, for the
+	// storage-block and storage-suggestion templates
+	BoundPVs        []corev1.PersistentVolume
+	UnboundPVs      []corev1.PersistentVolume
+	SnapshotClasses []string
+	// Error is why the assessment or lock lookup failed, for the assessment-failed and
+	// lock-lookup-failed templates
+	Error string
+	// Locks are the deletion locks forbidding the deletion, for the lock-block template
+	Locks []DeletionLock
+	// Closure is why changes are closed, with the freeze name and the next open time, for
+	// the change-closed and change-closed-note templates
+	Closure *ChangeWindowClosure
+	// Trip is why the circuit breaker tripped, and ResetCommand how an operator resets it,
+	// for the breaker-block template
+	Trip         *BreakerTrip
+	ResetCommand string
+}
+
+// MessageTemplates renders denial messages and suggestions from Go templates. A template
+// that is not defined, or fails to execute, falls back to the built-in text; failures are
+// logged and counted in pv_safe_message_template_errors_total.
+type MessageTemplates struct {
+	tmpl   *template.Template
+	logger *log.Logger
+}
+
+// ParseMessageTemplates parses a set of {{define "name"}} blocks, using the names
+// TemplatePVCBlock and so on. Each defined template is executed once against sample
+// data so that references to unknown fields are reported here rather than on denial.
+func ParseMessageTemplates(text string) (*MessageTemplates, error) {
+	tmpl, err := template.New("messages").Funcs(template.FuncMap{
+		"join": strings.Join,
+	}).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message templates: %w", err)
+	}
+
+	var unknown []string
+	for _, t := range tmpl.Templates() {
+		if t.Name() != "messages" && !isMessageTemplateName(t.Name()) {
+			unknown = append(unknown, t.Name())
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown message templates %v (expected %s)", unknown, strings.Join(messageTemplateNames, ", "))
+	}
+
+	templates := &MessageTemplates{tmpl: tmpl}
+	for _, name := range messageTemplateNames {
+		if tmpl.Lookup(name) == nil {
+			continue
+		}
+		if _, err := templates.execute(name, sampleMessageData()); err != nil {
+			return nil, err
+		}
+	}
+
+	return templates, nil
+}
+
+// LoadMessageTemplates reads message templates from a file, typically mounted from a ConfigMap
+func LoadMessageTemplates(path string) (*MessageTemplates, error) {
+	text, err := os.ReadFile(path) //nolint:gosec // G304: path comes from trusted configuration
+	if err != nil {
+		return nil, fmt.Errorf("failed to read message templates: %w", err)
+	}
+
+	templates, err := ParseMessageTemplates(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// SetLogger sets the logger template failures are reported to
+func (mt *MessageTemplates) SetLogger(logger *log.Logger) {
+	mt.logger = logger
+}
+
+// render executes the named template, or returns the built-in text when the template is
+// not defined or fails
+func (mt *MessageTemplates) render(name string, data *MessageData, builtin func() string) string {
+	if mt == nil || mt.tmpl.Lookup(name) == nil {
+		return builtin()
+	}
+
+	text, err := mt.execute(name, data)
+	if err != nil {
+		messageTemplateErrors.WithLabelValues(name).Inc()
+		if mt.logger != nil {
+			mt.logger.Printf("Warning: %v; using the built-in message", err)
+		}
+		return builtin()
+	}
+	return text
+}
+
+// execute runs the named template
+func (mt *MessageTemplates) execute(name string, data *MessageData) (string, error) {
+	var buf bytes.Buffer
+	if err := mt.tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to execute message template %q: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// isMessageTemplateName reports whether name is one of the supported template names
+func isMessageTemplateName(name string) bool {
+	for _, known := range messageTemplateNames {
+		if name == known {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleMessageData returns data with every field set, used to check templates at load time
+func sampleMessageData() *MessageData {
+	snapshot := &SnapshotInfo{
+		Name:               "data-snapshot",
+		Namespace:          "app",
+		SourcePVC:          "data",
+		IsReady:            true,
+		DeletionPolicy:     "Retain",
+		CreationTime:       metav1.Now(),
+		ContentName:        "snapcontent-data",
+		SourceVolumeHandle: "vol-previous",
+	}
+
+	return &MessageData{
+		Kind:      "PersistentVolumeClaim",
+		Namespace: "app",
+		Name:      "data",
+		PV: &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+			Spec: corev1.PersistentVolumeSpec{
+				PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+				ClaimRef:                      &corev1.ObjectReference{Namespace: "app", Name: "data"},
+			},
+		},
+		Assessment: &RiskAssessment{
+			IsRisky: true,
+			RiskyPVCs: []RiskyPVC{{
+				Name:             "data",
+				Namespace:        "app",
+				PVName:           "pv-data",
+				Reason:           "PV has Delete reclaim policy, no snapshot found",
+				ReasonCode:       ReasonReclaimPolicy,
+				SnapshotState:    SnapshotStatePreviousVolumeOnly,
+				IgnoredSnapshots: []*SnapshotInfo{snapshot},
+				Contacts:         []Contact{{Key: "team", Value: "payments", Source: "Namespace app"}},
+			}},
+			UnknownPVCs: []UnknownPVC{{
+				Name:      "lost",
+				Namespace: "app",
+				Phase:     corev1.ClaimLost,
+				PVName:    "pv-lost",
+				Error:     "PVC is Lost",
+			}},
+		},
+		BoundPVs: []corev1.PersistentVolume{{
+			ObjectMeta: metav1.ObjectMeta{Name: "pv-data"},
+			Spec:       corev1.PersistentVolumeSpec{ClaimRef: &corev1.ObjectReference{Namespace: "app", Name: "data"}},
+		}},
+		UnboundPVs:      []corev1.PersistentVolume{{ObjectMeta: metav1.ObjectMeta{Name: "pv-released"}}},
+		SnapshotClasses: []string{"csi-snapclass"},
+		BypassLabel:     BypassLabel,
+		Error:           "context deadline exceeded",
+		Locks: []DeletionLock{{
+			Kind:      "PersistentVolumeClaim",
+			Namespace: "app",
+			Name:      "data",
+			Holder:    "dba-team",
+			Reason:    "migration in progress",
+		}},
+		Closure: &ChangeWindowClosure{
+			Scope:       ChangeScopeRisky,
+			Reason:      ReasonChangeFreeze,
+			Description: `change freeze "year-end" until Mon 2027-01-04 00:00 CET`,
+			Freeze:      "year-end",
+			NextOpen:    time.Now().Add(time.Hour),
+		},
+		Trip: &BreakerTrip{
+			Limit:     BreakerLimitUser,
+			User:      "alice",
+			Count:     21,
+			Window:    time.Minute,
+			TrippedAt: time.Now(),
+			Replica:   "pv-safe-webhook-0",
+		},
+		ResetCommand: "kubectl delete configmap pv-safe-circuit-breaker -n pv-safe-system",
+	}
+}
@@ -0,0 +1,289 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseMessageTemplatesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{
+			name:    "syntax error",
+			text:    `{{ define "pvc-block" }}{{ .Name }{{ end }}`,
+			wantErr: "failed to parse message templates",
+		},
+		{
+			name:    "unknown template name",
+			text:    `{{ define "pvc-denial" }}blocked{{ end }}`,
+			wantErr: `unknown message templates [pvc-denial]`,
+		},
+		{
+			name:    "unknown field",
+			text:    `{{ define "pvc-block" }}{{ .Assessment.Runbook }}{{ end }}`,
+			wantErr: `failed to execute message template "pvc-block"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseMessageTemplates(tt.text)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseMessageTemplates() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMessageTemplatesRenderPVCDenial(t *testing.T) {
+	templates, err := ParseMessageTemplates(`
+{{- define "pvc-block" -}}
+PVC {{ .Namespace }}/{{ .Name }} on {{ .PV.Name }} is not backed up.
+{{- range .Assessment.RiskyPVCs }}{{ range .IgnoredSnapshots }}
+Snapshot {{ .Name }} ({{ .CreationTime.UTC.Format "2006-01-02" }}) is of volume {{ .SourceVolumeHandle }}.
+{{- end }}{{ end }}
+{{ end -}}
+{{- define "pvc-suggestion" -}}
+Run: backupctl snapshot {{ .Namespace }}/{{ .Name }}
+Runbook: https://runbooks.example.com/storage/pv-safe
+{{ end -}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pvc := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+	pvc.CreationTimestamp = metav1.NewTime(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	pv := newCSIPV("vol-current")
+	oldSnapshot := newBoundVolumeSnapshot("old", "snapcontent-old", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	rc := NewRiskCalculator(fake.NewClientset(pvc, pv),
+		newFakeSnapshotChecker(newVolumeSnapshotClass("retain", "Retain"), oldSnapshot,
+			newVolumeSnapshotContent("snapcontent-old", "vol-previous")))
+	rc.SetMessageTemplates(templates)
+
+	assessment, err := rc.AssessPVCDeletion(context.Background(), "app", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantMessage := "PVC app/data on pv-data is not backed up.\n" +
+		"Snapshot old (2026-01-01) is of volume vol-previous.\n"
+	if assessment.Message != wantMessage {
+		t.Errorf("message = %q, want %q", assessment.Message, wantMessage)
+	}
+	wantSuggestion := "Run: backupctl snapshot app/data\n" +
+		"Runbook: https://runbooks.example.com/storage/pv-safe\n"
+	if assessment.Suggestion != wantSuggestion {
+		t.Errorf("suggestion = %q, want %q", assessment.Suggestion, wantSuggestion)
+	}
+}
+
+func TestMessageTemplatesFallBackToBuiltinText(t *testing.T) {
+	templates, err := ParseMessageTemplates(`{{ define "pvc-suggestion" }}Contact the storage team.{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := NewRiskCalculator(fake.NewClientset(
+		newNamespacePVC("data", corev1.ClaimBound, "pv-data"),
+		newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)), nil)
+	rc.SetMessageTemplates(templates)
+
+	assessment, err := rc.AssessPVCDeletion(context.Background(), "app", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := rc.buildPVCBlockMessage(assessment.RiskyPVCs[0]); assessment.Message != want {
+		t.Errorf("message = %q, want the built-in %q", assessment.Message, want)
+	}
+	if assessment.Suggestion != "Contact the storage team." {
+		t.Errorf("suggestion = %q, want the template text", assessment.Suggestion)
+	}
+}
+
+func TestMessageTemplatesRuntimeFailureIsReported(t *testing.T) {
+	// The sample data has an unknown PVC, so this only fails on a real denial
+	templates, err := ParseMessageTemplates(`{{ define "pvc-block" }}{{ (index .Assessment.UnknownPVCs 0).Name }}{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logs bytes.Buffer
+	templates.SetLogger(log.New(&logs, "", 0))
+
+	rc := NewRiskCalculator(fake.NewClientset(
+		newNamespacePVC("data", corev1.ClaimBound, "pv-data"),
+		newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)), nil)
+	rc.SetMessageTemplates(templates)
+
+	before := testutil.ToFloat64(messageTemplateErrors.WithLabelValues(TemplatePVCBlock))
+	assessment, err := rc.AssessPVCDeletion(context.Background(), "app", "data")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := rc.buildPVCBlockMessage(assessment.RiskyPVCs[0]); assessment.Message != want {
+		t.Errorf("message = %q, want the built-in %q", assessment.Message, want)
+	}
+	if !strings.Contains(logs.String(), `failed to execute message template "pvc-block"`) {
+		t.Errorf("logs = %q, want the template failure", logs.String())
+	}
+	if got := testutil.ToFloat64(messageTemplateErrors.WithLabelValues(TemplatePVCBlock)) - before; got != 1 {
+		t.Errorf("template errors counted = %v, want 1", got)
+	}
+}
+
+func TestMessageTemplatesStorageAndAssessmentFailure(t *testing.T) {
+	templates, err := ParseMessageTemplates(`
+{{ define "storage-suggestion" }}Ask #storage before removing {{ .Kind }} {{ .Name }}.{{ end }}
+{{ define "storage-block" }}{{ .Kind }} {{ .Name }} is used by{{ range .BoundPVs }} {{ .Name }} ({{ .Spec.ClaimRef.Namespace }}/{{ .Spec.ClaimRef.Name }}){{ end }}; {{ len .UnboundPVs }} unbound.{{ end }}
+{{ define "assessment-failed" }}{{ .Kind }} {{ .Namespace }}/{{ .Name }} could not be checked ({{ .Error }}); page #storage.{{ end }}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc := NewRiskCalculator(fake.NewClientset(newStoragePV("pv-1", "fast", "", corev1.VolumeBound),
+		newStoragePV("pv-2", "fast", "", corev1.VolumeReleased)), nil)
+	rc.SetMessageTemplates(templates)
+	assessment, err := rc.AssessStorageClassDeletion(context.Background(), "fast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "StorageClass fast is used by pv-1 (app/claim-pv-1); 1 unbound."; assessment.Message != want {
+		t.Errorf("message = %q, want %q", assessment.Message, want)
+	}
+	if assessment.Suggestion != "Ask #storage before removing StorageClass fast." {
+		t.Errorf("suggestion = %q, want the template text", assessment.Suggestion)
+	}
+
+	handler := NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(), nil)
+	handler.RiskCalculator.SetMessageTemplates(templates)
+	handler.FailClosed = true
+	response := handler.assessmentFailed(&admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+		Namespace: "app",
+		Name:      "data",
+	}, errors.New("connection refused"))
+	if want := "PersistentVolumeClaim app/data could not be checked (connection refused); page #storage."; response.Result.Message != want {
+		t.Errorf("message = %q, want %q", response.Result.Message, want)
+	}
+}
+
+func TestMessageTemplatesDeletionLocks(t *testing.T) {
+	templates, err := ParseMessageTemplates(`
+{{ define "lock-block" }}{{ .Kind }} {{ .Namespace }}/{{ .Name }} is locked by{{ range .Locks }} {{ .Holder }} ({{ .Reason }}){{ end }}.{{ end }}
+{{ define "lock-lookup-failed" }}Locks of {{ .Namespace }}/{{ .Name }} unknown: {{ .Error }}.{{ end }}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pvc := withLock(newNamespacePVC("data", corev1.ClaimBound, "pv-data"), "dba-team", "primary orders database")
+	handler := NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(pvc), nil)
+	handler.RiskCalculator.SetMessageTemplates(templates)
+	request := &admissionv1.AdmissionRequest{
+		UID:       "uid-1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+		Operation: admissionv1.Delete,
+		Namespace: "app",
+		Name:      "data",
+		OldObject: rawPVC(t, pvc),
+	}
+
+	response := serveReview(t, handler, request)
+	if want := "PersistentVolumeClaim app/data is locked by dba-team (primary orders database)."; response.Result.Message != want {
+		t.Errorf("message = %q, want %q", response.Result.Message, want)
+	}
+
+	response = handler.lockLookupFailed(request, errors.New("connection refused"))
+	if want := "Locks of app/data unknown: connection refused."; response.Result.Message != want {
+		t.Errorf("message = %q, want %q", response.Result.Message, want)
+	}
+}
+
+func TestMessageTemplatesChangeWindows(t *testing.T) {
+	templates, err := ParseMessageTemplates(`
+{{ define "change-closed" }}{{ .Name }} waits for {{ .Closure.Freeze }} to end, until {{ .Closure.NextOpen.Format "2006-01-02 15:04" }}.{{ end }}
+{{ define "change-closed-note" }} Frozen by {{ .Closure.Freeze }}.{{ end }}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blackFriday := ChangeFreeze{Name: "black-friday", Start: berlinTime(t, "2026-11-27T00:00"), End: berlinTime(t, "2026-11-30T00:00")}
+	tests := []struct {
+		name        string
+		scope       ChangeScope
+		pvc         string
+		wantMessage string
+	}{
+		{name: "closed to every deletion", scope: ChangeScopeAll, pvc: "logs", wantMessage: "logs waits for black-friday to end, until 2026-12-05 22:00."},
+		{name: "note on a risky deletion", scope: ChangeScopeRisky, pvc: "data", wantMessage: " Frozen by black-friday."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pvc := newNamespacePVC(tt.pvc, corev1.ClaimBound, "pv-"+tt.pvc)
+			handler := NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(pvc,
+				newPhasedPV("pv-"+tt.pvc, corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)), nil)
+			handler.RiskCalculator.SetMessageTemplates(templates)
+			handler.ChangeWindows = newWeekendChangeWindows(t, tt.scope, blackFriday)
+			now := berlinTime(t, "2026-11-28T23:00")
+			handler.now = func() time.Time { return now }
+
+			response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+				UID:       "uid-1",
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+				Operation: admissionv1.Delete,
+				Namespace: "app",
+				Name:      tt.pvc,
+				OldObject: rawPVC(t, pvc),
+			})
+
+			if response.Allowed || !strings.HasSuffix(response.Result.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to end with %q", response.Result.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestMessageTemplatesCircuitBreaker(t *testing.T) {
+	templates, err := ParseMessageTemplates(`
+{{ define "breaker-block" }}{{ .Trip.Count }} deletions by {{ .Trip.User }} in {{ .Trip.Window }} ({{ .Trip.Limit }} limit, {{ .Trip.Replica }}); reset with: {{ .ResetCommand }}{{ end }}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := fake.NewClientset()
+	handler := NewHandler(log.New(io.Discard, "", 0), client, nil)
+	handler.RiskCalculator.SetMessageTemplates(templates)
+	handler.CircuitBreaker, _ = newTestBreaker(client)
+
+	message := handler.breakerMessage(&admissionv1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+		Namespace: "app",
+		Name:      "logs",
+	}, &BreakerTrip{Limit: BreakerLimitUser, User: "alice", Count: 4, Window: time.Minute, TrippedAt: breakerTestNow, Replica: "pv-safe-0"})
+
+	want := "4 deletions by alice in 1m0s (user limit, pv-safe-0); reset with: kubectl delete configmap pv-safe-circuit-breaker -n pv-safe-system"
+	if message != want {
+		t.Errorf("message = %q, want %q", message, want)
+	}
+}
@@ -0,0 +1,169 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// blockingBackupProvider never finds a backup and waits for the context to expire for
+// PVCs listed in slow
+type blockingBackupProvider struct {
+	slow map[string]bool
+}
+
+func (p *blockingBackupProvider) Name() string { return "blocking" }
+
+func (p *blockingBackupProvider) FindBackup(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*BackupInfo, error) {
+	if p.slow[pvc.Name] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, nil
+}
+
+func newNamespaceObjects(count int, policy corev1.PersistentVolumeReclaimPolicy) []runtime.Object {
+	objects := make([]runtime.Object, 0, 2*count)
+	for i := 0; i < count; i++ {
+		pvName := fmt.Sprintf("pv-%04d", i)
+		objects = append(objects,
+			newNamespacePVC(fmt.Sprintf("data-%04d", i), corev1.ClaimBound, pvName),
+			newPhasedPV(pvName, policy, corev1.VolumeBound, nil))
+	}
+	return objects
+}
+
+func newVolumeSnapshot(name, pvcName, className string, ready bool) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "app"},
+		"spec": map[string]interface{}{
+			"volumeSnapshotClassName": className,
+			"source":                  map[string]interface{}{"persistentVolumeClaimName": pvcName},
+		},
+		"status": map[string]interface{}{"readyToUse": ready},
+	}}
+}
+
+func newVolumeSnapshotClass(name, deletionPolicy string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "snapshot.storage.k8s.io/v1",
+		"kind":           "VolumeSnapshotClass",
+		"metadata":       map[string]interface{}{"name": name},
+		"driver":         "hostpath.csi.k8s.io",
+		"deletionPolicy": deletionPolicy,
+	}}
+}
+
+func newFakeSnapshotChecker(objects ...runtime.Object) *SnapshotChecker {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), CustomResourceListKinds(), objects...)
+	return NewSnapshotCheckerForClient(client, nil)
+}
+
+func TestAssessNamespaceDeletionParallel(t *testing.T) {
+	objects := newNamespaceObjects(50, corev1.PersistentVolumeReclaimDelete)
+
+	var snapshots []runtime.Object
+	snapshots = append(snapshots, newVolumeSnapshotClass("retain", "Retain"))
+	for i := 0; i < 50; i += 2 {
+		snapshots = append(snapshots, newVolumeSnapshot(fmt.Sprintf("snap-%04d", i), fmt.Sprintf("data-%04d", i), "retain", true))
+	}
+
+	rc := NewRiskCalculator(fake.NewClientset(objects...), newFakeSnapshotChecker(snapshots...))
+	rc.SetConcurrency(4, time.Second)
+
+	assessment, err := rc.AssessNamespaceDeletion(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("AssessNamespaceDeletion() error = %v", err)
+	}
+
+	if len(assessment.RiskyPVCs) != 25 {
+		t.Fatalf("RiskyPVCs = %d, want 25", len(assessment.RiskyPVCs))
+	}
+	for i, risky := range assessment.RiskyPVCs {
+		if want := fmt.Sprintf("data-%04d", 2*i+1); risky.Name != want {
+			t.Fatalf("RiskyPVCs[%d] = %s, want %s (results must keep PVC order)", i, risky.Name, want)
+		}
+	}
+}
+
+func TestAssessNamespaceDeletionTimeoutAttribution(t *testing.T) {
+	objects := newNamespaceObjects(4, corev1.PersistentVolumeReclaimDelete)
+
+	rc := NewRiskCalculator(fake.NewClientset(objects...), nil)
+	rc.SetConcurrency(2, 50*time.Millisecond)
+	rc.AddBackupProvider(&blockingBackupProvider{slow: map[string]bool{"data-0001": true}})
+	rc.SetUnknownPolicy(UnknownPolicyBlock)
+
+	assessment, err := rc.AssessNamespaceDeletion(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("AssessNamespaceDeletion() error = %v", err)
+	}
+
+	if len(assessment.RiskyPVCs) != 3 {
+		t.Errorf("RiskyPVCs = %+v, want the 3 PVCs that finished", assessment.RiskyPVCs)
+	}
+	if len(assessment.UnknownPVCs) != 1 || assessment.UnknownPVCs[0].Name != "data-0001" {
+		t.Fatalf("UnknownPVCs = %+v, want data-0001", assessment.UnknownPVCs)
+	}
+	if want := "assessment timed out after 50ms"; assessment.UnknownPVCs[0].Error != want {
+		t.Errorf("UnknownPVCs[0].Error = %q, want %q", assessment.UnknownPVCs[0].Error, want)
+	}
+	if !strings.Contains(assessment.Message, "  - data-0001: assessment timed out after 50ms\n") {
+		t.Errorf("Message = %q, want the timed out PVC listed", assessment.Message)
+	}
+}
+
+func BenchmarkAssessNamespaceDeletion(b *testing.B) {
+	objects := newNamespaceObjects(1000, corev1.PersistentVolumeReclaimDelete)
+
+	snapshots := []runtime.Object{newVolumeSnapshotClass("retain", "Retain")}
+	for i := 0; i < 1000; i += 2 {
+		snapshots = append(snapshots, newVolumeSnapshot(fmt.Sprintf("snap-%04d", i), fmt.Sprintf("data-%04d", i), "retain", true))
+	}
+
+	rc := NewRiskCalculator(fake.NewClientset(objects...), newFakeSnapshotChecker(snapshots...))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		assessment, err := rc.AssessNamespaceDeletion(ctx, "app")
+		cancel()
+		if err != nil {
+			b.Fatalf("AssessNamespaceDeletion() error = %v", err)
+		}
+		if len(assessment.RiskyPVCs) != 500 || len(assessment.UnknownPVCs) != 0 {
+			b.Fatalf("got %d risky and %d unknown PVCs, want 500 and 0", len(assessment.RiskyPVCs), len(assessment.UnknownPVCs))
+		}
+	}
+}
+
+func TestSnapshotIndexIgnoresNonRetainSnapshots(t *testing.T) {
+	sc := newFakeSnapshotChecker(
+		newVolumeSnapshotClass("delete", "Delete"),
+		newVolumeSnapshotClass("retain", "Retain"),
+		newVolumeSnapshot("a-delete", "data", "delete", true),
+		newVolumeSnapshot("b-pending", "data", "retain", false),
+		newVolumeSnapshot("c-retain", "data", "retain", true),
+	)
+
+	index := sc.IndexNamespace(context.Background(), "app")
+	pv := newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)
+
+	info, _ := index.ReadySnapshot(newNamespacePVC("data", corev1.ClaimBound, "pv-data"), pv)
+	if info == nil || info.Name != "c-retain" {
+		t.Fatalf("ReadySnapshot() = %+v, want c-retain", info)
+	}
+	if info, _ := index.ReadySnapshot(newNamespacePVC("other", corev1.ClaimBound, "pv-data"), pv); info != nil {
+		t.Error("ReadySnapshot(other) returned a snapshot, want nil")
+	}
+}
@@ -0,0 +1,190 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ProtectionLabel selects how deletions are protected when set on a Namespace, PVC or PV
+const ProtectionLabel = "pv-safe.io/protection"
+
+// ProtectionMode is a value of the protection label
+type ProtectionMode string
+
+const (
+	// ProtectionEnforce blocks risky deletions. This is the default; set on a Namespace, PVC
+	// or PV it also refuses snapshots and backups as evidence, so only a Retain reclaim
+	// policy or the bypass label allows the deletion.
+	ProtectionEnforce ProtectionMode = "enforce"
+	// ProtectionWarn allows risky deletions with an admission warning
+	ProtectionWarn ProtectionMode = "warn"
+	// ProtectionDisabled allows deletions without assessing them
+	ProtectionDisabled ProtectionMode = "disabled"
+)
+
+// Protection is the effective protection of an object. The most specific label wins:
+// a PV's own label, then its claim's, then the claim's namespace's. A PVC deletion is
+// resolved the same way, starting from the PV bound to the claim.
+type Protection struct {
+	Mode ProtectionMode
+	// Source is the object whose label set the mode, e.g. "Namespace app"; it is empty
+	// when no label applies and the mode is the default
+	Source string
+}
+
+// String describes the protection for messages, e.g. "pv-safe.io/protection=warn on Namespace app"
+func (p Protection) String() string {
+	if p.Source == "" {
+		return fmt.Sprintf("default protection (%s)", p.Mode)
+	}
+	return fmt.Sprintf("%s=%s on %s", ProtectionLabel, p.Mode, p.Source)
+}
+
+// defaultProtection applies when no object carries a valid protection label
+var defaultProtection = Protection{Mode: ProtectionEnforce}
+
+// protectionFromLabels reads the protection label. Missing and unrecognized values report false.
+func protectionFromLabels(labels map[string]string, source string) (Protection, bool) {
+	switch mode := ProtectionMode(labels[ProtectionLabel]); mode {
+	case ProtectionEnforce, ProtectionWarn, ProtectionDisabled:
+		return Protection{Mode: mode, Source: source}, true
+	default:
+		return Protection{}, false
+	}
+}
+
+// refusesEvidence reports whether snapshots and backups are refused: the volume, its claim
+// or its namespace is explicitly labelled enforce. The default protection accepts them.
+func (p Protection) refusesEvidence() bool {
+	return p.Mode == ProtectionEnforce && p.Source != ""
+}
+
+// withClaimLabel applies a PVC's protection label over the protection it inherits
+func withClaimLabel(pvc *corev1.PersistentVolumeClaim, inherited Protection) Protection {
+	if protection, found := protectionFromLabels(pvc.Labels, fmt.Sprintf("PersistentVolumeClaim %s/%s", pvc.Namespace, pvc.Name)); found {
+		return protection
+	}
+	return inherited
+}
+
+// withVolumeLabel applies a PV's protection label over the protection it inherits from its claim
+func withVolumeLabel(pv *corev1.PersistentVolume, inherited Protection) Protection {
+	if pv == nil {
+		return inherited
+	}
+	if protection, found := protectionFromLabels(pv.Labels, "PersistentVolume "+pv.Name); found {
+		return protection
+	}
+	return inherited
+}
+
+// ResolveProtection returns the protection of an object being deleted from its own labels,
+// typically those of the admission request's old object, and its namespace's. A PVC's
+// bound PV is read, since its label takes precedence over the claim's. It lets disabled
+// deletions skip assessment; the assessment itself also consults the claim of a PV and
+// the labels of the PVCs and PVs in a namespace.
+func (rc *RiskCalculator) ResolveProtection(ctx context.Context, kind, namespace, name string, oldObject *unstructured.Unstructured) Protection {
+	var labels map[string]string
+	if oldObject != nil {
+		labels = oldObject.GetLabels()
+	}
+
+	switch kind {
+	case "Namespace":
+		if protection, found := protectionFromLabels(labels, "Namespace "+name); found {
+			return protection
+		}
+		if labels == nil {
+			return rc.namespaceProtection(ctx, name)
+		}
+		return defaultProtection
+	case "PersistentVolumeClaim":
+		pvc, err := decodeOrGet(oldObject, func() (*corev1.PersistentVolumeClaim, error) {
+			return rc.client.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+		})
+		if err != nil {
+			return defaultProtection
+		}
+		protection, err := rc.claimProtection(ctx, pvc)
+		if err != nil {
+			// Assess the deletion rather than trust a claim label the PV may override
+			return defaultProtection
+		}
+		return protection
+	default:
+		if protection, found := protectionFromLabels(labels, kindSource(kind, namespace, name)); found {
+			return protection
+		}
+		return defaultProtection
+	}
+}
+
+// kindSource names an object as the source of a protection label, e.g. "PersistentVolume pv-1"
+func kindSource(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + " " + name
+	}
+	return fmt.Sprintf("%s %s/%s", kind, namespace, name)
+}
+
+// namespaceProtection returns the protection set on a namespace, or the default when the
+// namespace has no label or cannot be read
+func (rc *RiskCalculator) namespaceProtection(ctx context.Context, namespace string) Protection {
+	ns, err := rc.client.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return defaultProtection
+	}
+	if protection, found := protectionFromLabels(ns.Labels, "Namespace "+namespace); found {
+		return protection
+	}
+	return defaultProtection
+}
+
+// claimProtection returns the protection of a PVC: the label of its bound PV, then its
+// own, then its namespace's. It fails when the bound PV cannot be read; a PV that no
+// longer exists has no label.
+func (rc *RiskCalculator) claimProtection(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (Protection, error) {
+	protection := withClaimLabel(pvc, rc.namespaceProtection(ctx, pvc.Namespace))
+	if pvc.Spec.VolumeName == "" {
+		return protection, nil
+	}
+
+	pv, err := rc.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return protection, nil
+	}
+	if err != nil {
+		return protection, fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err)
+	}
+	return withVolumeLabel(pv, protection), nil
+}
+
+// volumeProtection returns the protection of a PV: its own label, then its claim's, then
+// the claim's namespace's. A claim that was deleted, or replaced by a PVC with another
+// UID, is skipped.
+func (rc *RiskCalculator) volumeProtection(ctx context.Context, pv *corev1.PersistentVolume) Protection {
+	if protection, found := protectionFromLabels(pv.Labels, "PersistentVolume "+pv.Name); found {
+		return protection
+	}
+
+	claimRef := pv.Spec.ClaimRef
+	if claimRef == nil || claimRef.Namespace == "" {
+		return defaultProtection
+	}
+
+	pvc, err := rc.client.CoreV1().PersistentVolumeClaims(claimRef.Namespace).Get(ctx, claimRef.Name, metav1.GetOptions{})
+	if err == nil && (claimRef.UID == "" || pvc.UID == claimRef.UID) {
+		return withClaimLabel(pvc, rc.namespaceProtection(ctx, claimRef.Namespace))
+	}
+	return rc.namespaceProtection(ctx, claimRef.Namespace)
+}
+
+// warnedDeletion describes a risky deletion allowed by warn protection
+func warnedDeletion(object, reason string, protection Protection) string {
+	return fmt.Sprintf("%s would lose data permanently (%s); allowed by %s", object, reason, protection)
+}
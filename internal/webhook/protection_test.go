@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newProtectedNamespace(mode ProtectionMode) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "app",
+		Labels: map[string]string{ProtectionLabel: string(mode)},
+	}}
+}
+
+func withProtection[T metav1.Object](obj T, mode ProtectionMode) T {
+	obj.SetLabels(map[string]string{ProtectionLabel: string(mode)})
+	return obj
+}
+
+func TestAssessPVCDeletionProtection(t *testing.T) {
+	snapshotted := func() []runtime.Object {
+		return []runtime.Object{newVolumeSnapshotClass("retain", "Retain"), newVolumeSnapshot("snap", "data", "retain", true)}
+	}
+
+	tests := []struct {
+		name          string
+		namespace     ProtectionMode
+		pvc           ProtectionMode
+		pv            ProtectionMode
+		snapshots     []runtime.Object
+		wantRisky     bool
+		wantWarning   string
+		wantMessage   string
+		wantSnapState string
+	}{
+		{
+			name:          "no labels blocks",
+			wantRisky:     true,
+			wantSnapState: SnapshotStateNone,
+		},
+		{
+			name:        "namespace warn allows with a warning",
+			namespace:   ProtectionWarn,
+			wantWarning: "PVC app/data would lose data permanently (PV has Delete reclaim policy, no snapshot found); allowed by pv-safe.io/protection=warn on Namespace app",
+		},
+		{
+			name:        "namespace disabled skips assessment",
+			namespace:   ProtectionDisabled,
+			wantMessage: "Protection disabled by pv-safe.io/protection=disabled on Namespace app",
+		},
+		{
+			name:          "PVC enforce overrides namespace warn",
+			namespace:     ProtectionWarn,
+			pvc:           ProtectionEnforce,
+			wantRisky:     true,
+			wantSnapState: SnapshotStateNotAccepted,
+		},
+		{
+			name:          "PVC enforce refuses snapshots",
+			pvc:           ProtectionEnforce,
+			snapshots:     snapshotted(),
+			wantRisky:     true,
+			wantSnapState: SnapshotStateNotAccepted,
+		},
+		{
+			name:        "snapshot protects without enforce",
+			snapshots:   snapshotted(),
+			wantMessage: "Ready VolumeSnapshot 'snap' exists with Retain policy",
+		},
+		{
+			name:        "PVC disabled overrides namespace enforce",
+			namespace:   ProtectionEnforce,
+			pvc:         ProtectionDisabled,
+			wantMessage: "Protection disabled by pv-safe.io/protection=disabled on PersistentVolumeClaim app/data",
+		},
+		{
+			name:          "namespace enforce refuses snapshots of unlabelled PVCs",
+			namespace:     ProtectionEnforce,
+			snapshots:     snapshotted(),
+			wantRisky:     true,
+			wantSnapState: SnapshotStateNotAccepted,
+		},
+		{
+			name:          "PV enforce refuses the claim's snapshots",
+			pv:            ProtectionEnforce,
+			snapshots:     snapshotted(),
+			wantRisky:     true,
+			wantSnapState: SnapshotStateNotAccepted,
+		},
+		{
+			name:          "PV enforce overrides PVC disabled",
+			pvc:           ProtectionDisabled,
+			pv:            ProtectionEnforce,
+			wantRisky:     true,
+			wantSnapState: SnapshotStateNotAccepted,
+		},
+		{
+			name:        "PV disabled skips assessment of its claim",
+			namespace:   ProtectionEnforce,
+			pvc:         ProtectionEnforce,
+			pv:          ProtectionDisabled,
+			wantMessage: "Protection disabled by pv-safe.io/protection=disabled on PersistentVolume pv-data",
+		},
+		{
+			name:        "PV warn overrides namespace enforce",
+			namespace:   ProtectionEnforce,
+			pv:          ProtectionWarn,
+			wantWarning: "PVC app/data would lose data permanently (PV has Delete reclaim policy, no snapshot found); allowed by pv-safe.io/protection=warn on PersistentVolume pv-data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pvc := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+			if tt.pvc != "" {
+				withProtection(pvc, tt.pvc)
+			}
+			pv := newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)
+			if tt.pv != "" {
+				withProtection(pv, tt.pv)
+			}
+			objects := []runtime.Object{pvc, pv}
+			if tt.namespace != "" {
+				objects = append(objects, newProtectedNamespace(tt.namespace))
+			}
+
+			var checker *SnapshotChecker
+			if tt.snapshots != nil {
+				checker = newFakeSnapshotChecker(tt.snapshots...)
+			}
+
+			assessment, err := NewRiskCalculator(fake.NewClientset(objects...), checker).AssessPVCDeletion(context.Background(), "app", "data")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if assessment.IsRisky != tt.wantRisky {
+				t.Fatalf("IsRisky = %v, want %v (message %q)", assessment.IsRisky, tt.wantRisky, assessment.Message)
+			}
+			if tt.wantRisky && assessment.RiskyPVCs[0].SnapshotState != tt.wantSnapState {
+				t.Errorf("SnapshotState = %s, want %s", assessment.RiskyPVCs[0].SnapshotState, tt.wantSnapState)
+			}
+			if tt.wantWarning != "" && (len(assessment.Warnings) != 1 || assessment.Warnings[0] != tt.wantWarning) {
+				t.Errorf("warnings = %q, want [%q]", assessment.Warnings, tt.wantWarning)
+			}
+			if tt.wantMessage != "" && assessment.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", assessment.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAssessNamespaceDeletionPVCProtection(t *testing.T) {
+	// Objects alternate PVC and PV: data-0000 is disabled, data-0002 warns, data-0003 is
+	// disabled but its PV is enforced, and data-0004's PV is disabled
+	objects := newNamespaceObjects(5, corev1.PersistentVolumeReclaimDelete)
+	withProtection(objects[0].(*corev1.PersistentVolumeClaim), ProtectionDisabled)
+	withProtection(objects[4].(*corev1.PersistentVolumeClaim), ProtectionWarn)
+	withProtection(objects[6].(*corev1.PersistentVolumeClaim), ProtectionDisabled)
+	withProtection(objects[7].(*corev1.PersistentVolume), ProtectionEnforce)
+	withProtection(objects[9].(*corev1.PersistentVolume), ProtectionDisabled)
+	objects = append(objects, newProtectedNamespace(ProtectionEnforce))
+
+	// The snapshots are refused because the namespace is labelled enforce; data-0002's own
+	// warn label would accept one, so it has none
+	snapshots := []runtime.Object{newVolumeSnapshotClass("retain", "Retain")}
+	for _, i := range []int{0, 1, 3, 4} {
+		name := fmt.Sprintf("data-%04d", i)
+		snapshots = append(snapshots, newVolumeSnapshot("snap-"+name, name, "retain", true))
+	}
+
+	assessment, err := NewRiskCalculator(fake.NewClientset(objects...), newFakeSnapshotChecker(snapshots...)).AssessNamespaceDeletion(context.Background(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var risky []string
+	for _, pvc := range assessment.RiskyPVCs {
+		risky = append(risky, pvc.Name)
+		if pvc.SnapshotState != SnapshotStateNotAccepted {
+			t.Errorf("%s snapshot state = %s, want %s", pvc.Name, pvc.SnapshotState, SnapshotStateNotAccepted)
+		}
+	}
+	if !assessment.IsRisky || strings.Join(risky, ",") != "data-0001,data-0003" {
+		t.Fatalf("risky PVCs = %v, want data-0001 and data-0003", risky)
+	}
+	if len(assessment.Warnings) != 1 || !strings.HasPrefix(assessment.Warnings[0], "PVC app/data-0002 would lose data permanently") {
+		t.Errorf("warnings = %q, want one for data-0002", assessment.Warnings)
+	}
+}
+
+func TestAssessPVDeletionProtection(t *testing.T) {
+	claimRef := &corev1.ObjectReference{Namespace: "app", Name: "data", UID: types.UID("uid-1")}
+
+	t.Run("claim label applies to its PV", func(t *testing.T) {
+		pvc := withProtection(newNamespacePVC("data", corev1.ClaimBound, "pv-data"), ProtectionWarn)
+		pvc.UID = claimRef.UID
+		pv := newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, claimRef)
+
+		assessment, err := NewRiskCalculator(fake.NewClientset(pvc, pv), nil).AssessPVDeletion(context.Background(), "pv-data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if assessment.IsRisky || len(assessment.Warnings) != 1 ||
+			!strings.HasSuffix(assessment.Warnings[0], "allowed by pv-safe.io/protection=warn on PersistentVolumeClaim app/data") {
+			t.Errorf("assessment = %+v, want an allowed deletion with the claim's warning", assessment)
+		}
+	})
+
+	t.Run("enforce refuses snapshot content of a retained PV", func(t *testing.T) {
+		pv := withProtection(newCSIPV("vol-1"), ProtectionEnforce)
+		pv.Spec.PersistentVolumeReclaimPolicy = corev1.PersistentVolumeReclaimRetain
+		pv.Spec.ClaimRef = claimRef
+		pv.Status.Phase = corev1.VolumeReleased
+
+		rc := NewRiskCalculator(fake.NewClientset(pv), newFakeSnapshotChecker(newPreProvisionedContent("content-1", "vol-1", "Retain", true)))
+		assessment, err := rc.AssessPVDeletion(context.Background(), "pv-data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assessment.IsRisky || assessment.RiskyPVCs[0].SnapshotState != SnapshotStateNotAccepted {
+			t.Errorf("assessment = %+v, want a block with snapshots not accepted", assessment)
+		}
+	})
+}
+
+func TestHandlerSkipsDisabledProtection(t *testing.T) {
+	objects := newNamespaceObjects(2, corev1.PersistentVolumeReclaimDelete)
+	client := fake.NewClientset(objects...)
+	handler := NewHandler(log.New(io.Discard, "", 0), client, nil)
+
+	raw, err := json.Marshal(withProtection(&corev1.Namespace{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app"},
+	}, ProtectionDisabled))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+		UID:       "uid-1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+		Operation: admissionv1.Delete,
+		Name:      "app",
+		OldObject: runtime.RawExtension{Raw: raw},
+	})
+
+	if !response.Allowed || !strings.Contains(response.Result.Message, "protection disabled by pv-safe.io/protection=disabled on Namespace app") {
+		t.Errorf("response = %+v, want an allowed deletion naming the label", response)
+	}
+	// Only the deletion lock lookup lists the namespace's PVCs and PVs; assessment is skipped
+	for _, action := range client.Actions() {
+		if action.GetVerb() != "list" {
+			t.Errorf("unexpected %s of %s for a disabled namespace", action.GetVerb(), action.GetResource().Resource)
+		}
+	}
+	if lists := len(client.Actions()); lists != 2 {
+		t.Errorf("%d API calls, want the 2 lists of the deletion lock lookup", lists)
+	}
+}
+
+func TestHandlerPVProtectionOverridesDisabledClaim(t *testing.T) {
+	pvc := withProtection(newNamespacePVC("data", corev1.ClaimBound, "pv-data"), ProtectionDisabled)
+	pv := withProtection(newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil), ProtectionEnforce)
+	handler := NewHandler(log.New(io.Discard, "", 0), fake.NewClientset(pvc, pv), nil)
+
+	response := serveReview(t, handler, &admissionv1.AdmissionRequest{
+		UID:       "uid-1",
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+		Operation: admissionv1.Delete,
+		Namespace: "app",
+		Name:      "data",
+		OldObject: rawPVC(t, pvc),
+	})
+
+	if response.Allowed {
+		t.Errorf("response = %+v, want the deletion of a claim on an enforced PV denied", response)
+	}
+}
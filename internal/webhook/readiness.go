@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// readinessTimeout bounds all readiness checks of one probe. It stays below the
+// readiness probe timeoutSeconds of the Helm chart.
+const readinessTimeout = 2 * time.Second
+
+// ReadinessCheck is one named dependency verified by ReadyCheck
+type ReadinessCheck struct {
+	Name string
+	// Optional checks are reported by /readyz?verbose but never make the webhook unready
+	Optional bool
+	// Check returns an error when the dependency is not usable
+	Check func(ctx context.Context) error
+}
+
+// AddReadinessCheck registers a check run by ReadyCheck, e.g. the TLS certificate
+// expiry or the sync of an informer cache
+func (h *Handler) AddReadinessCheck(check ReadinessCheck) {
+	h.ReadinessChecks = append(h.ReadinessChecks, check)
+}
+
+// defaultReadinessChecks verifies API server connectivity, the RBAC permissions risk
+// assessment depends on and, when snapshot support is enabled, the snapshot API.
+// There is no cache sync check: assessments read PVCs, PVs and snapshots from the API
+// server on every admission request rather than from informer caches, so the api-server
+// and rbac checks already cover the data they depend on.
+func defaultReadinessChecks(client kubernetes.Interface, snapshotChecker *SnapshotChecker) []ReadinessCheck {
+	checks := []ReadinessCheck{
+		{
+			Name: "api-server",
+			Check: func(ctx context.Context) error {
+				_, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{Limit: 1})
+				return err
+			},
+		},
+		{
+			Name: "rbac",
+			Check: func(ctx context.Context) error {
+				if _, err := client.CoreV1().PersistentVolumeClaims("").List(ctx, metav1.ListOptions{Limit: 1}); err != nil {
+					return permissionError("persistentvolumeclaims", err)
+				}
+				if _, err := client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{Limit: 1}); err != nil {
+					return permissionError("persistentvolumes", err)
+				}
+				return nil
+			},
+		},
+	}
+
+	if snapshotChecker != nil {
+		// Clusters without the snapshot CRDs are still protected, every risky PVC is just blocked
+		checks = append(checks, ReadinessCheck{
+			Name:     "snapshot-api",
+			Optional: true,
+			Check: func(ctx context.Context) error {
+				if !snapshotChecker.IsSnapshotAPIAvailable(ctx) {
+					return errors.New("VolumeSnapshot API is not available; snapshots are not accepted as evidence")
+				}
+				return nil
+			},
+		})
+	}
+
+	return checks
+}
+
+// permissionError explains a failed list call, calling out missing RBAC permissions
+func permissionError(resource string, err error) error {
+	if apierrors.IsForbidden(err) {
+		return fmt.Errorf("missing permission to list %s", resource)
+	}
+	return fmt.Errorf("failed to list %s: %w", resource, err)
+}
+
+// StartShutdown makes ReadyCheck fail so Kubernetes removes the replica from the
+// Service endpoints before the server stops accepting connections
+func (h *Handler) StartShutdown() {
+	h.shuttingDown.Store(true)
+}
+
+// ReadyCheck is the readiness endpoint. It runs every readiness check and returns
+// HTTP 503 when a required check fails, so Kubernetes stops routing admission traffic
+// to a replica that would fail open. The per-check breakdown is returned on failure
+// and with the "verbose" query parameter.
+func (h *Handler) ReadyCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	var report strings.Builder
+	ready := true
+	if h.shuttingDown.Load() {
+		report.WriteString("[-]shutdown failed: webhook is shutting down\n")
+		ready = false
+	}
+	for _, check := range h.ReadinessChecks {
+		err := check.Check(ctx)
+		switch {
+		case err == nil:
+			fmt.Fprintf(&report, "[+]%s ok\n", check.Name)
+		case check.Optional:
+			fmt.Fprintf(&report, "[!]%s warning: %v\n", check.Name, err)
+		default:
+			fmt.Fprintf(&report, "[-]%s failed: %v\n", check.Name, err)
+			h.Logger.Printf("Readiness check %s failed: %v", check.Name, err)
+			ready = false
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if !ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, "%sreadyz check failed\n", report.String())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if r.URL.Query().Has("verbose") {
+		fmt.Fprintf(w, "%sreadyz check passed\n", report.String())
+		return
+	}
+	fmt.Fprintf(w, "ok")
+}
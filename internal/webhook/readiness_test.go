@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestReadyCheck(t *testing.T) {
+	forbidPVs := func(client *fake.Clientset) {
+		client.PrependReactor("list", "persistentvolumes", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "persistentvolumes"}, "", errors.New("denied"))
+		})
+	}
+	breakAPIServer := func(client *fake.Clientset) {
+		client.PrependReactor("*", "*", func(k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("connection refused")
+		})
+	}
+
+	tests := []struct {
+		name            string
+		breakClient     func(*fake.Clientset)
+		snapshotsBroken bool
+		extraCheck      *ReadinessCheck
+		verbose         bool
+		wantStatus      int
+		wantBody        []string
+	}{
+		{
+			name:       "all checks pass",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"ok"},
+		},
+		{
+			name:       "verbose breakdown",
+			verbose:    true,
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"[+]api-server ok", "[+]rbac ok", "[+]snapshot-api ok", "readyz check passed"},
+		},
+		{
+			name:        "API server unreachable",
+			breakClient: breakAPIServer,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantBody:    []string{"[-]api-server failed: connection refused", "readyz check failed"},
+		},
+		{
+			name:        "missing RBAC permission",
+			breakClient: forbidPVs,
+			wantStatus:  http.StatusServiceUnavailable,
+			wantBody:    []string{"[+]api-server ok", "[-]rbac failed: missing permission to list persistentvolumes"},
+		},
+		{
+			name:            "snapshot API unavailable only warns",
+			snapshotsBroken: true,
+			verbose:         true,
+			wantStatus:      http.StatusOK,
+			wantBody:        []string{"[!]snapshot-api warning: VolumeSnapshot API is not available"},
+		},
+		{
+			name: "registered check fails",
+			extraCheck: &ReadinessCheck{Name: "tls-certificate", Check: func(context.Context) error {
+				return errors.New("TLS certificate expires soon")
+			}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   []string{"[-]tls-certificate failed: TLS certificate expires soon"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewClientset()
+			if tt.breakClient != nil {
+				tt.breakClient(client)
+			}
+
+			dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{volumeSnapshotGVR: "VolumeSnapshotList"})
+			if tt.snapshotsBroken {
+				dynamicClient.PrependReactor("list", "volumesnapshots", func(k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, apierrors.NewNotFound(volumeSnapshotGVR.GroupResource(), "")
+				})
+			}
+
+			handler := NewHandler(log.New(io.Discard, "", 0), client, NewSnapshotCheckerForClient(dynamicClient, nil))
+			if tt.extraCheck != nil {
+				handler.AddReadinessCheck(*tt.extraCheck)
+			}
+
+			target := "/readyz"
+			if tt.verbose {
+				target += "?verbose"
+			}
+			recorder := httptest.NewRecorder()
+			handler.ReadyCheck(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d\n%s", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(recorder.Body.String(), want) {
+					t.Errorf("body does not contain %q:\n%s", want, recorder.Body)
+				}
+			}
+		})
+	}
+}
@@ -4,18 +4,23 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 // RiskAssessment contains the result of analyzing deletion risk
 type RiskAssessment struct {
-	IsRisky    bool
-	RiskyPVCs  []RiskyPVC
-	Message    string
-	Suggestion string
+	IsRisky     bool
+	RiskyPVCs   []RiskyPVC
+	UnknownPVCs []UnknownPVC
+	Message     string
+	Suggestion  string
+	Warnings    []string
 }
 
 // RiskyPVC represents a PVC that would lose data if deleted
@@ -26,12 +31,124 @@ type RiskyPVC struct {
 	Reason       string
 	HasSnapshot  bool
 	SnapshotInfo string
+	// ReasonCode and SnapshotState are the machine-readable form of Reason
+	ReasonCode    string
+	SnapshotState string
+	// IgnoredSnapshots are Ready snapshots of the PVC that were taken of a previous volume
+	IgnoredSnapshots []*SnapshotInfo
+	// Contacts are the owners found in the configured contact annotations and labels
+	Contacts []Contact
+}
+
+// UnknownPVC represents a PVC whose deletion risk could not be determined
+type UnknownPVC struct {
+	Name      string
+	Namespace string
+	Phase     corev1.PersistentVolumeClaimPhase
+	PVName    string
+	Error     string
+}
+
+// Reason codes reported as the type of a denial's status causes
+const (
+	// ReasonReclaimPolicy means the PV's reclaim policy deletes the data and nothing preserves it
+	ReasonReclaimPolicy = "ReclaimPolicyDeletesData"
+	// ReasonRetainedData means a released PV holds the only copy of a deleted PVC's data
+	ReasonRetainedData = "RetainedDataOfDeletedPVC"
+	// ReasonUnassessable means the PVC's risk could not be determined
+	ReasonUnassessable = "Unassessable"
+)
+
+// Snapshot states reported in a denial's status causes
+const (
+	// SnapshotStateNone means no usable snapshot or backup was found
+	SnapshotStateNone = "None"
+	// SnapshotStatePreviousVolumeOnly means the only snapshots found were taken of a previous volume
+	SnapshotStatePreviousVolumeOnly = "PreviousVolumeOnly"
+	// SnapshotStateUnknown means snapshots were not checked
+	SnapshotStateUnknown = "Unknown"
+	// SnapshotStateNotAccepted means the PVC or PV is labelled enforce, so snapshots and
+	// backups were not considered
+	SnapshotStateNotAccepted = "NotAccepted"
+)
+
+// maxMessagePVCs limits how many PVCs are listed in the prose of a namespace denial;
+// the status causes always list all of them
+const maxMessagePVCs = 10
+
+// Causes returns one status cause per risky and unassessable PVC. The field is the PVC's
+// namespace/name, the type its reason code, and the message has the form
+// "pv=<name> snapshot=<state> [<contact key>=<value> ...]: <reason>".
+func (a *RiskAssessment) Causes() []metav1.StatusCause {
+	causes := make([]metav1.StatusCause, 0, len(a.RiskyPVCs)+len(a.UnknownPVCs))
+
+	for _, risky := range a.RiskyPVCs {
+		causes = append(causes, metav1.StatusCause{
+			Type:    metav1.CauseType(risky.ReasonCode),
+			Field:   pvcField(risky.Namespace, risky.Name),
+			Message: fmt.Sprintf("pv=%s snapshot=%s%s: %s", risky.PVName, risky.SnapshotState, causeContacts(risky.Contacts), risky.Reason),
+		})
+	}
+	for _, unknown := range a.UnknownPVCs {
+		causes = append(causes, metav1.StatusCause{
+			Type:    ReasonUnassessable,
+			Field:   pvcField(unknown.Namespace, unknown.Name),
+			Message: fmt.Sprintf("pv=%s snapshot=%s: %s", unknown.PVName, SnapshotStateUnknown, unknown.Error),
+		})
+	}
+
+	return causes
+}
+
+// pvcField identifies a PVC in a status cause; PVs without a claim have no field
+func pvcField(namespace, name string) string {
+	if name == "" {
+		return ""
+	}
+	return namespace + "/" + name
+}
+
+const (
+	// DefaultAssessmentWorkers is the default number of PVCs evaluated in parallel
+	DefaultAssessmentWorkers = 16
+
+	// DefaultPVCAssessmentTimeout is the default deadline for evaluating a single PVC
+	DefaultPVCAssessmentTimeout = 2 * time.Second
+)
+
+// UnknownPolicy controls how PVCs that could not be assessed affect a deletion
+type UnknownPolicy string
+
+const (
+	// UnknownPolicyBlock treats unassessable PVCs like risky ones
+	UnknownPolicyBlock UnknownPolicy = "block"
+	// UnknownPolicyWarn allows the deletion but returns an admission warning per PVC
+	UnknownPolicyWarn UnknownPolicy = "warn"
+	// UnknownPolicyAllow allows the deletion and only logs the PVCs
+	UnknownPolicyAllow UnknownPolicy = "allow"
+)
+
+// ParseUnknownPolicy validates an unknown PVC policy name
+func ParseUnknownPolicy(value string) (UnknownPolicy, error) {
+	switch policy := UnknownPolicy(value); policy {
+	case UnknownPolicyBlock, UnknownPolicyWarn, UnknownPolicyAllow:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid unknown PVC policy %q (expected block, warn or allow)", value)
+	}
 }
 
 // RiskCalculator analyzes deletion risk for PVs and PVCs
 type RiskCalculator struct {
 	client          kubernetes.Interface
 	snapshotChecker *SnapshotChecker
+	backupProviders []BackupProvider
+	unknownPolicy   UnknownPolicy
+	workers         int
+	pvcTimeout      time.Duration
+	bypassLabel     string
+	templates       *MessageTemplates
+	contactKeys     []string
 }
 
 // NewRiskCalculator creates a new risk calculator
@@ -39,9 +156,63 @@ func NewRiskCalculator(client kubernetes.Interface, snapshotChecker *SnapshotChe
 	return &RiskCalculator{
 		client:          client,
 		snapshotChecker: snapshotChecker,
+		unknownPolicy:   UnknownPolicyWarn,
+		workers:         DefaultAssessmentWorkers,
+		pvcTimeout:      DefaultPVCAssessmentTimeout,
+		bypassLabel:     BypassLabel,
 	}
 }
 
+// SetBypassLabel sets the bypass label key shown in force-delete suggestions
+func (rc *RiskCalculator) SetBypassLabel(label string) {
+	rc.bypassLabel = label
+}
+
+// SetMessageTemplates replaces the built-in denial messages and suggestions with the
+// templates defined in mt; nil restores the built-in text
+func (rc *RiskCalculator) SetMessageTemplates(mt *MessageTemplates) {
+	rc.templates = mt
+}
+
+// SetContactKeys sets the annotation and label keys, e.g. "team", whose values are reported
+// as contacts for risky PVCs. No keys disables the lookup.
+func (rc *RiskCalculator) SetContactKeys(keys []string) {
+	rc.contactKeys = keys
+}
+
+// messageData returns the template data for a risky assessment
+func (rc *RiskCalculator) messageData(kind, namespace, name string, pv *corev1.PersistentVolume, assessment *RiskAssessment) *MessageData {
+	return &MessageData{
+		Kind:        kind,
+		Namespace:   namespace,
+		Name:        name,
+		PV:          pv,
+		Assessment:  assessment,
+		BypassLabel: rc.bypassLabel,
+	}
+}
+
+// SetConcurrency sets the number of PVCs evaluated in parallel during namespace assessment
+// and the deadline for each one
+func (rc *RiskCalculator) SetConcurrency(workers int, pvcTimeout time.Duration) {
+	if workers > 0 {
+		rc.workers = workers
+	}
+	if pvcTimeout > 0 {
+		rc.pvcTimeout = pvcTimeout
+	}
+}
+
+// SetUnknownPolicy sets how PVCs that could not be assessed affect namespace deletions
+func (rc *RiskCalculator) SetUnknownPolicy(policy UnknownPolicy) {
+	rc.unknownPolicy = policy
+}
+
+// AddBackupProvider registers a provider consulted for backup evidence when a PVC has no snapshot
+func (rc *RiskCalculator) AddBackupProvider(provider BackupProvider) {
+	rc.backupProviders = append(rc.backupProviders, provider)
+}
+
 // AssessNamespaceDeletion checks if deleting a namespace would lose data
 func (rc *RiskCalculator) AssessNamespaceDeletion(ctx context.Context, namespace string) (*RiskAssessment, error) {
 	pvcs, err := rc.client.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
@@ -56,44 +227,204 @@ func (rc *RiskCalculator) AssessNamespaceDeletion(ctx context.Context, namespace
 		}, nil
 	}
 
+	protection := rc.namespaceProtection(ctx, namespace)
+	if protection.Mode == ProtectionDisabled {
+		return &RiskAssessment{IsRisky: false, Message: "Protection disabled by " + protection.String()}, nil
+	}
+
 	assessment := &RiskAssessment{
 		IsRisky:   false,
 		RiskyPVCs: []RiskyPVC{},
 	}
 
-	for _, pvc := range pvcs.Items {
-		if pvc.Status.Phase != corev1.ClaimBound {
-			continue
+	for _, result := range rc.assessNamespacePVCs(ctx, namespace, pvcs.Items, protection) {
+		switch {
+		case result.risky != nil:
+			assessment.IsRisky = true
+			assessment.RiskyPVCs = append(assessment.RiskyPVCs, *result.risky)
+		case result.unknown != nil:
+			assessment.UnknownPVCs = append(assessment.UnknownPVCs, *result.unknown)
+		case result.warning != "":
+			assessment.Warnings = append(assessment.Warnings, result.warning)
 		}
+	}
 
-		pv, err := rc.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
-		if err != nil {
-			continue
-		}
+	pvcsByName := make(map[string]*corev1.PersistentVolumeClaim, len(pvcs.Items))
+	for i := range pvcs.Items {
+		pvcsByName[pvcs.Items[i].Name] = &pvcs.Items[i]
+	}
+	rc.addContacts(ctx, namespace, assessment.RiskyPVCs, pvcsByName)
 
-		isRisky, reason, snapshotInfo := rc.isPVCRisky(ctx, pvc.Namespace, pvc.Name, pv)
-		if isRisky {
-			assessment.IsRisky = true
-			riskyPVC := RiskyPVC{
-				Name:      pvc.Name,
-				Namespace: pvc.Namespace,
-				PVName:    pv.Name,
-				Reason:    reason,
-			}
-			if snapshotInfo != nil {
-				riskyPVC.HasSnapshot = true
-				riskyPVC.SnapshotInfo = snapshotInfo.Name
+	rc.applyUnknownPolicy(assessment)
+
+	if assessment.IsRisky {
+		data := rc.messageData("Namespace", "", namespace, nil, assessment)
+		assessment.Message = rc.templates.render(TemplateNamespaceBlock, data, func() string {
+			return rc.buildNamespaceBlockMessage(namespace, assessment.RiskyPVCs, assessment.UnknownPVCs)
+		})
+		assessment.Suggestion = rc.templates.render(TemplateNamespaceSuggestion, data, func() string {
+			return rc.buildSuggestions(namespace, assessment.RiskyPVCs)
+		})
+	}
+
+	return assessment, nil
+}
+
+// namespacePVCResult is the outcome for one PVC of a namespace; all fields are empty for safe PVCs
+type namespacePVCResult struct {
+	risky   *RiskyPVC
+	unknown *UnknownPVC
+	// warning is set instead of risky for a risky PVC whose protection is warn
+	warning string
+}
+
+// assessNamespacePVCs evaluates the PVCs of a namespace with a bounded pool of workers.
+// PVs and snapshots are listed once up front, and each PVC gets its own deadline so that
+// a slow lookup only affects that PVC. PVCs that run out of time are reported as unknown
+// rather than risky. Results are returned in the order of the input PVCs. The protection
+// label of a PVC's bound PV, then of the PVC, overrides the namespace's protection.
+func (rc *RiskCalculator) assessNamespacePVCs(ctx context.Context, namespace string, pvcs []corev1.PersistentVolumeClaim, protection Protection) []namespacePVCResult {
+	pvsByName := rc.listPVsByName(ctx)
+
+	var index *SnapshotIndex
+	if rc.snapshotChecker != nil {
+		index = rc.snapshotChecker.IndexNamespace(ctx, namespace)
+	}
+
+	results := make([]namespacePVCResult, len(pvcs))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < min(rc.workers, len(pvcs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = rc.assessNamespacePVC(ctx, &pvcs[i], pvsByName, index, protection)
 			}
-			assessment.RiskyPVCs = append(assessment.RiskyPVCs, riskyPVC)
+		}()
+	}
+
+	for i := range pvcs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// assessNamespacePVC evaluates a single PVC during namespace assessment
+func (rc *RiskCalculator) assessNamespacePVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pvsByName map[string]*corev1.PersistentVolume, index *SnapshotIndex, protection Protection) namespacePVCResult {
+	protection = withClaimLabel(pvc, protection)
+
+	unknown := func(reason string) namespacePVCResult {
+		return namespacePVCResult{unknown: &UnknownPVC{
+			Name:      pvc.Name,
+			Namespace: pvc.Namespace,
+			Phase:     pvc.Status.Phase,
+			PVName:    pvc.Spec.VolumeName,
+			Error:     reason,
+		}}
+	}
+
+	if pvc.Status.Phase != corev1.ClaimBound {
+		// A Pending claim without a volume has no data yet; anything else is unknown
+		if protection.Mode == ProtectionDisabled || (pvc.Status.Phase == corev1.ClaimPending && pvc.Spec.VolumeName == "") {
+			return namespacePVCResult{}
 		}
+		return unknown(fmt.Sprintf("PVC is %s", pvc.Status.Phase))
 	}
 
-	if assessment.IsRisky {
-		assessment.Message = rc.buildNamespaceBlockMessage(namespace, assessment.RiskyPVCs)
-		assessment.Suggestion = rc.buildSuggestions(namespace, assessment.RiskyPVCs)
+	if ctx.Err() != nil {
+		return unknown("not assessed: namespace assessment deadline exceeded")
 	}
 
-	return assessment, nil
+	pvcCtx, cancel := context.WithTimeout(ctx, rc.pvcTimeout)
+	defer cancel()
+
+	pv, found := pvsByName[pvc.Spec.VolumeName]
+	if !found {
+		var err error
+		pv, err = rc.client.CoreV1().PersistentVolumes().Get(pvcCtx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return unknown(fmt.Sprintf("failed to get PV %s: %v", pvc.Spec.VolumeName, err))
+		}
+	}
+
+	// The bound PV's own label is the most specific
+	protection = withVolumeLabel(pv, protection)
+	if protection.Mode == ProtectionDisabled {
+		return namespacePVCResult{}
+	}
+
+	risk := rc.isPVCRisky(pvcCtx, pvc, pv, index, protection)
+	if risk.isRisky && pvcCtx.Err() != nil {
+		// Evidence lookups may have been cut short, so the PVC is unknown rather than risky
+		return unknown(fmt.Sprintf("assessment timed out after %s", rc.pvcTimeout))
+	}
+
+	if !risk.isRisky {
+		return namespacePVCResult{}
+	}
+
+	if protection.Mode == ProtectionWarn {
+		return namespacePVCResult{warning: warnedDeletion(fmt.Sprintf("PVC %s/%s", pvc.Namespace, pvc.Name), risk.reason, protection)}
+	}
+
+	riskyPVC := &RiskyPVC{
+		Name:             pvc.Name,
+		Namespace:        pvc.Namespace,
+		PVName:           pv.Name,
+		Reason:           risk.reason,
+		ReasonCode:       ReasonReclaimPolicy,
+		SnapshotState:    risk.snapshotState(),
+		IgnoredSnapshots: risk.ignoredSnapshots,
+	}
+	if risk.snapshot != nil {
+		riskyPVC.HasSnapshot = true
+		riskyPVC.SnapshotInfo = risk.snapshot.Name
+	}
+
+	return namespacePVCResult{risky: riskyPVC}
+}
+
+// listPVsByName lists all PVs once for namespace assessment. On failure it returns an
+// empty map and PVs are fetched individually instead.
+func (rc *RiskCalculator) listPVsByName(ctx context.Context) map[string]*corev1.PersistentVolume {
+	result := map[string]*corev1.PersistentVolume{}
+
+	pvs, err := rc.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return result
+	}
+
+	for i := range pvs.Items {
+		result[pvs.Items[i].Name] = &pvs.Items[i]
+	}
+
+	return result
+}
+
+// applyUnknownPolicy decides how the unassessable PVCs of an assessment affect the decision
+func (rc *RiskCalculator) applyUnknownPolicy(assessment *RiskAssessment) {
+	if len(assessment.UnknownPVCs) == 0 {
+		return
+	}
+
+	switch rc.unknownPolicy {
+	case UnknownPolicyBlock:
+		assessment.IsRisky = true
+	case UnknownPolicyWarn:
+		for _, unknown := range assessment.UnknownPVCs {
+			assessment.Warnings = append(assessment.Warnings, fmt.Sprintf("PVC %s/%s could not be assessed: %s",
+				unknown.Namespace, unknown.Name, unknown.Error))
+		}
+	}
+
+	if !assessment.IsRisky {
+		assessment.Message = fmt.Sprintf("%d PVC(s) could not be assessed (policy: %s)", len(assessment.UnknownPVCs), rc.unknownPolicy)
+	}
 }
 
 // AssessPVCDeletion checks if deleting a PVC would lose data
@@ -103,41 +434,69 @@ func (rc *RiskCalculator) AssessPVCDeletion(ctx context.Context, namespace, name
 		return nil, fmt.Errorf("failed to get PVC %s/%s: %w", namespace, name, err)
 	}
 
-	if pvc.Status.Phase != corev1.ClaimBound {
+	var pv *corev1.PersistentVolume
+	if pvc.Status.Phase == corev1.ClaimBound {
+		pv, err = rc.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err)
+		}
+	}
+
+	protection := withVolumeLabel(pv, withClaimLabel(pvc, rc.namespaceProtection(ctx, namespace)))
+	if protection.Mode == ProtectionDisabled {
+		return &RiskAssessment{IsRisky: false, Message: "Protection disabled by " + protection.String()}, nil
+	}
+
+	if pv == nil {
 		return &RiskAssessment{
 			IsRisky: false,
 			Message: fmt.Sprintf("PVC %s/%s is not bound to a PV", namespace, name),
 		}, nil
 	}
 
-	pv, err := rc.client.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err)
+	var index *SnapshotIndex
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain && !protection.refusesEvidence() {
+		// Evidence is only consulted for volumes that would be deleted and accept it
+		index = rc.indexVolume(ctx, pvc, pv)
 	}
 
-	isRisky, reason, snapshotInfo := rc.isPVCRisky(ctx, namespace, name, pv)
+	risk := rc.isPVCRisky(ctx, pvc, pv, index, protection)
+	if risk.isRisky && protection.Mode == ProtectionWarn {
+		warning := warnedDeletion(fmt.Sprintf("PVC %s/%s", namespace, name), risk.reason, protection)
+		return &RiskAssessment{IsRisky: false, Message: warning, Warnings: []string{warning}}, nil
+	}
 
 	assessment := &RiskAssessment{
-		IsRisky: isRisky,
+		IsRisky: risk.isRisky,
 	}
 
 	if assessment.IsRisky {
 		riskyPVC := RiskyPVC{
-			Name:      name,
-			Namespace: namespace,
-			PVName:    pv.Name,
-			Reason:    reason,
+			Name:             name,
+			Namespace:        namespace,
+			PVName:           pv.Name,
+			Reason:           risk.reason,
+			ReasonCode:       ReasonReclaimPolicy,
+			SnapshotState:    risk.snapshotState(),
+			IgnoredSnapshots: risk.ignoredSnapshots,
 		}
-		if snapshotInfo != nil {
+		if risk.snapshot != nil {
 			riskyPVC.HasSnapshot = true
-			riskyPVC.SnapshotInfo = snapshotInfo.Name
+			riskyPVC.SnapshotInfo = risk.snapshot.Name
 		}
 		assessment.RiskyPVCs = []RiskyPVC{riskyPVC}
-		assessment.Message = rc.buildPVCBlockMessage(riskyPVC)
-		assessment.Suggestion = rc.buildPVCSuggestions(namespace, name, pv.Name)
-	} else if snapshotInfo != nil {
-		// Not risky because snapshot exists - include this info in the message
-		assessment.Message = reason
+		rc.addContacts(ctx, namespace, assessment.RiskyPVCs, map[string]*corev1.PersistentVolumeClaim{name: pvc})
+		riskyPVC = assessment.RiskyPVCs[0]
+		data := rc.messageData("PersistentVolumeClaim", namespace, name, pv, assessment)
+		assessment.Message = rc.templates.render(TemplatePVCBlock, data, func() string {
+			return rc.buildPVCBlockMessage(riskyPVC)
+		})
+		assessment.Suggestion = rc.templates.render(TemplatePVCSuggestion, data, func() string {
+			return rc.buildPVCSuggestions(namespace, name, pv.Name)
+		})
+	} else if risk.snapshot != nil || risk.snapshotContent != nil || risk.groupSnapshot != nil || risk.backup != nil {
+		// Not risky because a snapshot or backup exists - include this info in the message
+		assessment.Message = risk.reason
 	}
 
 	return assessment, nil
@@ -150,10 +509,24 @@ func (rc *RiskCalculator) AssessPVDeletion(ctx context.Context, pvName string) (
 		return nil, fmt.Errorf("failed to get PV %s: %w", pvName, err)
 	}
 
+	protection := rc.volumeProtection(ctx, pv)
+	if protection.Mode == ProtectionDisabled {
+		return &RiskAssessment{IsRisky: false, Message: "Protection disabled by " + protection.String()}, nil
+	}
+
+	if isOrphanedRetainedPV(pv) {
+		return rc.assessRetainedPVDeletion(ctx, pv, protection)
+	}
+
 	assessment := &RiskAssessment{
 		IsRisky: rc.isPVRisky(pv),
 	}
 
+	if assessment.IsRisky && protection.Mode == ProtectionWarn {
+		warning := warnedDeletion("PV "+pv.Name, fmt.Sprintf("PV has %s reclaim policy", pv.Spec.PersistentVolumeReclaimPolicy), protection)
+		return &RiskAssessment{IsRisky: false, Message: warning, Warnings: []string{warning}}, nil
+	}
+
 	if assessment.IsRisky {
 		namespace := ""
 		pvcName := ""
@@ -163,19 +536,123 @@ func (rc *RiskCalculator) AssessPVDeletion(ctx context.Context, pvName string) (
 		}
 
 		riskyPVC := RiskyPVC{
-			Name:      pvcName,
-			Namespace: namespace,
-			PVName:    pv.Name,
-			Reason:    fmt.Sprintf("PV has %s reclaim policy, no snapshot found", pv.Spec.PersistentVolumeReclaimPolicy),
+			Name:          pvcName,
+			Namespace:     namespace,
+			PVName:        pv.Name,
+			Reason:        fmt.Sprintf("PV has %s reclaim policy, no snapshot found", pv.Spec.PersistentVolumeReclaimPolicy),
+			ReasonCode:    ReasonReclaimPolicy,
+			SnapshotState: SnapshotStateUnknown,
 		}
 		assessment.RiskyPVCs = []RiskyPVC{riskyPVC}
-		assessment.Message = rc.buildPVBlockMessage(pv, riskyPVC)
-		assessment.Suggestion = rc.buildPVSuggestions(pv)
+		rc.addContacts(ctx, namespace, assessment.RiskyPVCs, nil)
+		riskyPVC = assessment.RiskyPVCs[0]
+		data := rc.messageData("PersistentVolume", "", pv.Name, pv, assessment)
+		assessment.Message = rc.templates.render(TemplatePVBlock, data, func() string {
+			return rc.buildPVBlockMessage(pv, riskyPVC)
+		})
+		assessment.Suggestion = rc.templates.render(TemplatePVSuggestion, data, func() string {
+			return rc.buildPVSuggestions(pv)
+		})
+	}
+
+	return assessment, nil
+}
+
+// assessRetainedPVDeletion checks if deleting a PV that outlived its claim would destroy the
+// retained data. Such a PV is the last copy of the deleted PVC's data, so deletion is only
+// safe when that data is also preserved by a snapshot or backup, which is not accepted
+// for PVs labelled enforce.
+func (rc *RiskCalculator) assessRetainedPVDeletion(ctx context.Context, pv *corev1.PersistentVolume, protection Protection) (*RiskAssessment, error) {
+	claimRef := pv.Spec.ClaimRef
+
+	pvc, err := rc.client.CoreV1().PersistentVolumeClaims(claimRef.Namespace).Get(ctx, claimRef.Name, metav1.GetOptions{})
+	switch {
+	case err == nil && pvc.UID == claimRef.UID:
+		// The claim still exists, so the PV is not the last copy of its data
+		return &RiskAssessment{IsRisky: false}, nil
+	case err != nil && !apierrors.IsNotFound(err):
+		return nil, fmt.Errorf("failed to get PVC %s/%s: %w", claimRef.Namespace, claimRef.Name, err)
+	}
+
+	// Evidence lookups work on the claim the data belonged to, even though it is gone
+	deletedPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claimRef.Name,
+			Namespace: claimRef.Namespace,
+			UID:       claimRef.UID,
+		},
+	}
+
+	reason := fmt.Sprintf("PV is %s and holds the retained data of deleted PVC %s/%s", pv.Status.Phase, claimRef.Namespace, claimRef.Name)
+
+	risk := pvcRisk{enforced: protection.refusesEvidence()}
+	if risk.enforced {
+		reason += "; snapshots and backups are not accepted under " + protection.String()
+	} else {
+		var found bool
+		risk, found = rc.findProtection(ctx, deletedPVC, pv, rc.indexVolume(ctx, deletedPVC, pv))
+		if found {
+			return &RiskAssessment{
+				IsRisky: false,
+				Message: risk.reason,
+			}, nil
+		}
+		reason = withIgnoredSnapshots(reason+", no snapshot found", risk.ignored)
+	}
+
+	if protection.Mode == ProtectionWarn {
+		warning := warnedDeletion("PV "+pv.Name, reason, protection)
+		return &RiskAssessment{IsRisky: false, Message: warning, Warnings: []string{warning}}, nil
+	}
+
+	riskyPVC := RiskyPVC{
+		Name:             claimRef.Name,
+		Namespace:        claimRef.Namespace,
+		PVName:           pv.Name,
+		Reason:           reason,
+		ReasonCode:       ReasonRetainedData,
+		SnapshotState:    risk.snapshotState(),
+		IgnoredSnapshots: risk.ignoredSnapshots,
+	}
+
+	assessment := &RiskAssessment{
+		IsRisky:   true,
+		RiskyPVCs: []RiskyPVC{riskyPVC},
 	}
+	// The claim is gone, so only its namespace and any workloads still naming it are asked
+	rc.addContacts(ctx, claimRef.Namespace, assessment.RiskyPVCs, map[string]*corev1.PersistentVolumeClaim{})
+	riskyPVC = assessment.RiskyPVCs[0]
+	data := rc.messageData("PersistentVolume", "", pv.Name, pv, assessment)
+	assessment.Message = rc.templates.render(TemplateRetainedPVBlock, data, func() string {
+		return rc.buildPVBlockMessage(pv, riskyPVC)
+	})
+	assessment.Suggestion = rc.templates.render(TemplateRetainedPVSuggestion, data, func() string {
+		return rc.buildRetainedPVSuggestions(pv)
+	})
 
 	return assessment, nil
 }
 
+// isOrphanedRetainedPV reports whether a Retain PV was once bound to a claim but is no
+// longer bound, e.g. Released after the PVC was deleted. PVs that never had a claim, or
+// were only pre-bound by name, have no claim UID and hold no retained data.
+func isOrphanedRetainedPV(pv *corev1.PersistentVolume) bool {
+	if pv.Spec.PersistentVolumeReclaimPolicy != corev1.PersistentVolumeReclaimRetain {
+		return false
+	}
+
+	if pv.Spec.ClaimRef == nil || pv.Spec.ClaimRef.UID == "" {
+		return false
+	}
+
+	switch pv.Status.Phase {
+	case corev1.VolumeReleased, corev1.VolumeFailed, corev1.VolumeAvailable:
+		return true
+	default:
+		return false
+	}
+}
+
 // isPVRisky determines if a PV deletion would cause data loss
 func (rc *RiskCalculator) isPVRisky(pv *corev1.PersistentVolume) bool {
 	// Safe if reclaim policy is Retain
@@ -192,44 +669,183 @@ func (rc *RiskCalculator) isPVRisky(pv *corev1.PersistentVolume) bool {
 	return true
 }
 
-// isPVCRisky determines if a PVC deletion would cause data loss, considering snapshots
-func (rc *RiskCalculator) isPVCRisky(ctx context.Context, namespace, pvcName string, pv *corev1.PersistentVolume) (bool, string, *SnapshotInfo) {
+// pvcRisk is the outcome of assessing a single PVC, including the evidence that made it safe
+type pvcRisk struct {
+	isRisky         bool
+	reason          string
+	snapshot        *SnapshotInfo
+	snapshotContent *SnapshotContentInfo
+	groupSnapshot   *GroupSnapshotInfo
+	backup          *BackupInfo
+	// ignored describes snapshots that were skipped because they belong to a previous volume
+	ignored          []string
+	ignoredSnapshots []*SnapshotInfo
+	// enforced is set when snapshots and backups were not considered because of the
+	// enforce protection label
+	enforced bool
+}
+
+// snapshotState describes the snapshots of an unprotected PVC for status causes
+func (r pvcRisk) snapshotState() string {
+	if r.enforced {
+		return SnapshotStateNotAccepted
+	}
+	return snapshotState(r.ignored)
+}
+
+// isPVCRisky determines if a PVC deletion would cause data loss, considering snapshots and
+// backups unless the PVC's effective protection refuses them
+func (rc *RiskCalculator) isPVCRisky(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume, index *SnapshotIndex, protection Protection) pvcRisk {
 	// Safe if reclaim policy is Retain
 	if pv.Spec.PersistentVolumeReclaimPolicy == corev1.PersistentVolumeReclaimRetain {
-		return false, "PV has Retain reclaim policy", nil
+		return pvcRisk{reason: "PV has Retain reclaim policy"}
 	}
 
-	// If reclaim policy is Delete, check for snapshots
-	if rc.snapshotChecker != nil {
-		hasSnapshot, snapshotInfo, err := rc.snapshotChecker.HasReadySnapshot(ctx, namespace, pvcName)
-		if err == nil && hasSnapshot && snapshotInfo != nil {
-			// Safe if there's a ready snapshot with Retain policy
-			return false, fmt.Sprintf("Ready VolumeSnapshot '%s' exists with Retain policy", snapshotInfo.Name), snapshotInfo
+	// Evidence does not count when the PV, the PVC or its namespace is labelled enforce
+	if protection.refusesEvidence() {
+		return pvcRisk{
+			isRisky: true,
+			reason: fmt.Sprintf("PV has %s reclaim policy; snapshots and backups are not accepted under %s",
+				pv.Spec.PersistentVolumeReclaimPolicy, protection),
+			enforced: true,
+		}
+	}
+
+	risk, found := rc.findProtection(ctx, pvc, pv, index)
+	if found {
+		return risk
+	}
+
+	// Risky: Delete reclaim policy and no snapshot or backup
+	return pvcRisk{
+		isRisky:          true,
+		reason:           withIgnoredSnapshots(fmt.Sprintf("PV has %s reclaim policy, no snapshot found", pv.Spec.PersistentVolumeReclaimPolicy), risk.ignored),
+		ignored:          risk.ignored,
+		ignoredSnapshots: risk.ignoredSnapshots,
+	}
+}
+
+// withIgnoredSnapshots appends the snapshots of previous volumes to a risk reason, so users
+// can see why an existing snapshot did not protect the PVC
+func withIgnoredSnapshots(reason string, ignored []string) string {
+	if len(ignored) == 0 {
+		return reason
+	}
+	return reason + "; " + strings.Join(ignored, "; ")
+}
+
+// snapshotState describes the snapshots of an unprotected PVC for status causes
+func snapshotState(ignored []string) string {
+	if len(ignored) > 0 {
+		return SnapshotStatePreviousVolumeOnly
+	}
+	return SnapshotStateNone
+}
+
+// indexVolume indexes the snapshot evidence of a single PVC's volume, or returns nil
+// without snapshot support
+func (rc *RiskCalculator) indexVolume(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) *SnapshotIndex {
+	if rc.snapshotChecker == nil {
+		return nil
+	}
+	return rc.snapshotChecker.IndexVolume(ctx, pvc, pv)
+}
+
+// findProtection looks for a snapshot, snapshot content, group snapshot or backup that
+// preserves the PVC's data. The index is built by the caller once per admission request;
+// a nil index means snapshots are not available. When nothing is found, the returned
+// risk lists the snapshots that were ignored as belonging to a previous volume.
+func (rc *RiskCalculator) findProtection(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume, index *SnapshotIndex) (pvcRisk, bool) {
+	var ignored []string
+	var ignoredSnapshots []*SnapshotInfo
+	if index != nil {
+		// Safe if there's a ready snapshot with Retain policy of the current volume
+		snapshotInfo, mismatches := index.ReadySnapshot(pvc, pv)
+		if snapshotInfo != nil {
+			return pvcRisk{
+				reason:   fmt.Sprintf("Ready VolumeSnapshot '%s' exists with Retain policy", snapshotInfo.Name),
+				snapshot: snapshotInfo,
+			}, true
+		}
+		ignored = mismatches
+		ignoredSnapshots = index.snapshots[pvc.Name]
+
+		// A retained content of the PV's volume protects it even without a VolumeSnapshot
+		if contentInfo := index.ReadySnapshotContent(pv); contentInfo != nil {
+			return pvcRisk{
+				reason:          fmt.Sprintf("Ready VolumeSnapshotContent '%s' of this volume exists with Retain policy", contentInfo.Name),
+				snapshotContent: contentInfo,
+			}, true
+		}
+
+		// A ready, retained group snapshot covering the PVC also protects it
+		if groupInfo := index.ReadyGroupSnapshot(pvc, pv); groupInfo != nil {
+			return pvcRisk{
+				reason:        fmt.Sprintf("Ready VolumeGroupSnapshot '%s' covers this PVC with Retain policy", groupInfo.Name),
+				groupSnapshot: groupInfo,
+			}, true
+		}
+	}
+
+	// Then check external backup providers
+	for _, provider := range rc.backupProviders {
+		backupInfo, err := provider.FindBackup(ctx, pvc)
+		if err == nil && backupInfo != nil {
+			// Safe if a completed, unexpired backup covers this PVC
+			return pvcRisk{
+				reason: fmt.Sprintf("%s backup '%s' completed at %s covers this PVC",
+					backupInfo.Provider, backupInfo.Name, backupInfo.CompletionTime.UTC().Format(time.RFC3339)),
+				backup: backupInfo,
+			}, true
 		}
 	}
 
-	// Risky: Delete reclaim policy and no snapshot
-	return true, fmt.Sprintf("PV has %s reclaim policy, no snapshot found", pv.Spec.PersistentVolumeReclaimPolicy), nil
+	return pvcRisk{ignored: ignored, ignoredSnapshots: ignoredSnapshots}, false
 }
 
 // buildNamespaceBlockMessage creates a user-friendly error message for namespace deletion
-func (rc *RiskCalculator) buildNamespaceBlockMessage(namespace string, riskyPVCs []RiskyPVC) string {
+func (rc *RiskCalculator) buildNamespaceBlockMessage(namespace string, riskyPVCs []RiskyPVC, unknownPVCs []UnknownPVC) string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("DELETION BLOCKED: Namespace '%s' contains %d PVC(s) that would lose data permanently\n\n", namespace, len(riskyPVCs)))
-	sb.WriteString("Risky PVCs:\n")
+	if len(riskyPVCs) > 0 {
+		sb.WriteString(fmt.Sprintf("DELETION BLOCKED: Namespace '%s' contains %d PVC(s) that would lose data permanently\n\n", namespace, len(riskyPVCs)))
+		sb.WriteString("Risky PVCs:\n")
+
+		for _, risky := range riskyPVCs[:min(len(riskyPVCs), maxMessagePVCs)] {
+			sb.WriteString(fmt.Sprintf("  - %s: %s", risky.Name, risky.Reason))
+			if len(risky.Contacts) > 0 {
+				sb.WriteString(fmt.Sprintf(" [contacts: %s]", formatContacts(risky.Contacts)))
+			}
+			sb.WriteString("\n")
+		}
+		writeOmitted(&sb, len(riskyPVCs))
+	} else {
+		sb.WriteString(fmt.Sprintf("DELETION BLOCKED: Namespace '%s' contains %d PVC(s) that could not be assessed\n", namespace, len(unknownPVCs)))
+	}
 
-	for _, risky := range riskyPVCs {
-		sb.WriteString(fmt.Sprintf("  - %s: %s\n", risky.Name, risky.Reason))
+	if len(unknownPVCs) > 0 {
+		sb.WriteString("\nUnassessable PVCs:\n")
+
+		for _, unknown := range unknownPVCs[:min(len(unknownPVCs), maxMessagePVCs)] {
+			sb.WriteString(fmt.Sprintf("  - %s: %s\n", unknown.Name, unknown.Error))
+		}
+		writeOmitted(&sb, len(unknownPVCs))
 	}
 
 	return sb.String()
 }
 
+// writeOmitted notes how many PVCs of a list were left out of a message
+func writeOmitted(sb *strings.Builder, total int) {
+	if total > maxMessagePVCs {
+		sb.WriteString(fmt.Sprintf("  ... and %d more (all PVCs are listed in the response's status details)\n", total-maxMessagePVCs))
+	}
+}
+
 // buildPVCBlockMessage creates a user-friendly error message for PVC deletion
 func (rc *RiskCalculator) buildPVCBlockMessage(risky RiskyPVC) string {
 	return fmt.Sprintf("DELETION BLOCKED: PVC '%s/%s' would lose data permanently\n\nReason: %s\n",
-		risky.Namespace, risky.Name, risky.Reason)
+		risky.Namespace, risky.Name, risky.Reason) + contactsLine(risky.Contacts)
 }
 
 // buildPVBlockMessage creates a user-friendly error message for PV deletion
@@ -241,7 +857,7 @@ func (rc *RiskCalculator) buildPVBlockMessage(pv *corev1.PersistentVolume, risky
 		msg += fmt.Sprintf("Bound to: %s/%s\n", risky.Namespace, risky.Name)
 	}
 
-	return msg
+	return msg + contactsLine(risky.Contacts)
 }
 
 // buildPVCSuggestions creates actionable suggestions for PVC deletion
@@ -251,9 +867,9 @@ func (rc *RiskCalculator) buildPVCSuggestions(namespace, pvcName, pvName string)
 		"  2. OR change PV reclaim policy to Retain:\n"+
 		"     kubectl patch pv %s -p '{\"spec\":{\"persistentVolumeReclaimPolicy\":\"Retain\"}}'\n"+
 		"\n  3. OR force delete (will lose data):\n"+
-		"     kubectl label pvc %s -n %s pv-safe.io/force-delete=true\n"+
+		"     kubectl label pvc %s -n %s %s=true\n"+
 		"     kubectl delete pvc %s -n %s\n"+
-		"\n  4. Then retry the deletion\n", pvName, pvcName, namespace, pvcName, namespace)
+		"\n  4. Then retry the deletion\n", pvName, pvcName, namespace, rc.bypassLabel, pvcName, namespace)
 }
 
 // buildSuggestions creates actionable suggestions for safe deletion
@@ -264,12 +880,15 @@ func (rc *RiskCalculator) buildSuggestions(namespace string, riskyPVCs []RiskyPV
 	sb.WriteString("  1. Create VolumeSnapshots for the PVCs\n")
 	sb.WriteString("  2. OR change PV reclaim policy to Retain:\n")
 
-	for _, risky := range riskyPVCs {
+	for _, risky := range riskyPVCs[:min(len(riskyPVCs), maxMessagePVCs)] {
 		sb.WriteString(fmt.Sprintf("     kubectl patch pv %s -p '{\"spec\":{\"persistentVolumeReclaimPolicy\":\"Retain\"}}'\n", risky.PVName))
 	}
+	if len(riskyPVCs) > maxMessagePVCs {
+		sb.WriteString(fmt.Sprintf("     ... and %d more PV(s)\n", len(riskyPVCs)-maxMessagePVCs))
+	}
 
 	sb.WriteString("\n  3. OR force delete (will lose data):\n")
-	sb.WriteString(fmt.Sprintf("     kubectl label namespace %s pv-safe.io/force-delete=true\n", namespace))
+	sb.WriteString(fmt.Sprintf("     kubectl label namespace %s %s=true\n", namespace, rc.bypassLabel))
 	sb.WriteString(fmt.Sprintf("     kubectl delete namespace %s\n", namespace))
 
 	sb.WriteString("\n  4. Then retry the deletion\n")
@@ -277,6 +896,17 @@ func (rc *RiskCalculator) buildSuggestions(namespace string, riskyPVCs []RiskyPV
 	return sb.String()
 }
 
+// buildRetainedPVSuggestions creates actionable suggestions for deleting a PV that holds retained data
+func (rc *RiskCalculator) buildRetainedPVSuggestions(pv *corev1.PersistentVolume) string {
+	return fmt.Sprintf("\nThis PV is the last copy of data retained from a deleted PVC.\n"+
+		"To safely delete this PV:\n"+
+		"  1. Back up or snapshot the data (e.g. bind it to a new PVC and create a VolumeSnapshot)\n"+
+		"\n  2. OR force delete (will lose data):\n"+
+		"     kubectl label pv %s %s=true\n"+
+		"     kubectl delete pv %s\n"+
+		"\n  3. Then retry the deletion\n", pv.Name, rc.bypassLabel, pv.Name)
+}
+
 // buildPVSuggestions creates actionable suggestions for PV deletion
 func (rc *RiskCalculator) buildPVSuggestions(pv *corev1.PersistentVolume) string {
 	return fmt.Sprintf("\nTo safely delete this PV:\n"+
@@ -284,7 +914,7 @@ func (rc *RiskCalculator) buildPVSuggestions(pv *corev1.PersistentVolume) string
 		"  2. OR change reclaim policy to Retain:\n"+
 		"     kubectl patch pv %s -p '{\"spec\":{\"persistentVolumeReclaimPolicy\":\"Retain\"}}'\n"+
 		"\n  3. OR force delete (will lose data):\n"+
-		"     kubectl label pv %s pv-safe.io/force-delete=true\n"+
+		"     kubectl label pv %s %s=true\n"+
 		"     kubectl delete pv %s\n"+
-		"\n  4. Then retry the deletion\n", pv.Name, pv.Name, pv.Name)
+		"\n  4. Then retry the deletion\n", pv.Name, pv.Name, rc.bypassLabel, pv.Name)
 }
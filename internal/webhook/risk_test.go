@@ -0,0 +1,504 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPhasedPV(name string, policy corev1.PersistentVolumeReclaimPolicy, phase corev1.PersistentVolumePhase, claimRef *corev1.ObjectReference) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			PersistentVolumeReclaimPolicy: policy,
+			ClaimRef:                      claimRef,
+		},
+		Status: corev1.PersistentVolumeStatus{Phase: phase},
+	}
+}
+
+func TestAssessPVDeletionPhases(t *testing.T) {
+	deletedClaim := &corev1.ObjectReference{Namespace: "app", Name: "data", UID: types.UID("old-uid")}
+	existingPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "app", UID: types.UID("old-uid")},
+	}
+
+	tests := []struct {
+		name       string
+		pv         *corev1.PersistentVolume
+		objects    []runtime.Object
+		wantRisky  bool
+		wantReason string
+	}{
+		{
+			name:      "bound Retain PV is safe",
+			pv:        newPhasedPV("pv-1", corev1.PersistentVolumeReclaimRetain, corev1.VolumeBound, deletedClaim),
+			objects:   []runtime.Object{existingPVC},
+			wantRisky: false,
+		},
+		{
+			name:       "released Retain PV of deleted claim is blocked",
+			pv:         newPhasedPV("pv-1", corev1.PersistentVolumeReclaimRetain, corev1.VolumeReleased, deletedClaim),
+			wantRisky:  true,
+			wantReason: "PV is Released and holds the retained data of deleted PVC app/data, no snapshot found",
+		},
+		{
+			name:      "failed Retain PV of deleted claim is blocked",
+			pv:        newPhasedPV("pv-1", corev1.PersistentVolumeReclaimRetain, corev1.VolumeFailed, deletedClaim),
+			wantRisky: true,
+		},
+		{
+			name:      "available Retain PV with stale claimRef is blocked",
+			pv:        newPhasedPV("pv-1", corev1.PersistentVolumeReclaimRetain, corev1.VolumeAvailable, deletedClaim),
+			wantRisky: true,
+		},
+		{
+			name:      "fresh available PV without claimRef is safe",
+			pv:        newPhasedPV("pv-1", corev1.PersistentVolumeReclaimRetain, corev1.VolumeAvailable, nil),
+			wantRisky: false,
+		},
+		{
+			name: "pre-bound available PV without claim UID is safe",
+			pv: newPhasedPV("pv-1", corev1.PersistentVolumeReclaimRetain, corev1.VolumeAvailable,
+				&corev1.ObjectReference{Namespace: "app", Name: "data"}),
+			wantRisky: false,
+		},
+		{
+			name:      "released PV whose claim still exists is safe",
+			pv:        newPhasedPV("pv-1", corev1.PersistentVolumeReclaimRetain, corev1.VolumeReleased, deletedClaim),
+			objects:   []runtime.Object{existingPVC},
+			wantRisky: false,
+		},
+		{
+			name:       "bound Delete PV is blocked",
+			pv:         newPhasedPV("pv-1", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, deletedClaim),
+			objects:    []runtime.Object{existingPVC},
+			wantRisky:  true,
+			wantReason: "PV has Delete reclaim policy, no snapshot found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := append([]runtime.Object{tt.pv}, tt.objects...)
+			rc := NewRiskCalculator(fake.NewClientset(objects...), nil)
+
+			assessment, err := rc.AssessPVDeletion(context.Background(), tt.pv.Name)
+			if err != nil {
+				t.Fatalf("AssessPVDeletion() error = %v", err)
+			}
+			if assessment.IsRisky != tt.wantRisky {
+				t.Fatalf("IsRisky = %v, want %v (message %q)", assessment.IsRisky, tt.wantRisky, assessment.Message)
+			}
+			if tt.wantReason != "" {
+				if len(assessment.RiskyPVCs) != 1 || assessment.RiskyPVCs[0].Reason != tt.wantReason {
+					t.Errorf("RiskyPVCs = %+v, want reason %q", assessment.RiskyPVCs, tt.wantReason)
+				}
+				if !strings.Contains(assessment.Message, "DELETION BLOCKED: PV 'pv-1' would lose data permanently") {
+					t.Errorf("Message = %q, want PV block message", assessment.Message)
+				}
+			}
+		})
+	}
+}
+
+func newNamespacePVC(name string, phase corev1.PersistentVolumeClaimPhase, volumeName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "app"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volumeName},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+}
+
+func TestAssessNamespaceDeletionUnknownPVCs(t *testing.T) {
+	objects := []runtime.Object{
+		newNamespacePVC("retained", corev1.ClaimBound, "pv-retained"),
+		newNamespacePVC("missing-pv", corev1.ClaimBound, "pv-missing"),
+		newNamespacePVC("lost", corev1.ClaimLost, "pv-lost"),
+		newNamespacePVC("waiting", corev1.ClaimPending, ""),
+		newPhasedPV("pv-retained", corev1.PersistentVolumeReclaimRetain, corev1.VolumeBound, nil),
+	}
+
+	tests := []struct {
+		policy       UnknownPolicy
+		wantRisky    bool
+		wantWarnings int
+	}{
+		{policy: UnknownPolicyBlock, wantRisky: true},
+		{policy: UnknownPolicyWarn, wantWarnings: 2},
+		{policy: UnknownPolicyAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			rc := NewRiskCalculator(fake.NewClientset(objects...), nil)
+			rc.SetUnknownPolicy(tt.policy)
+
+			assessment, err := rc.AssessNamespaceDeletion(context.Background(), "app")
+			if err != nil {
+				t.Fatalf("AssessNamespaceDeletion() error = %v", err)
+			}
+			if len(assessment.UnknownPVCs) != 2 {
+				t.Fatalf("UnknownPVCs = %+v, want missing-pv and lost", assessment.UnknownPVCs)
+			}
+			if assessment.IsRisky != tt.wantRisky {
+				t.Errorf("IsRisky = %v, want %v", assessment.IsRisky, tt.wantRisky)
+			}
+			if len(assessment.Warnings) != tt.wantWarnings {
+				t.Errorf("Warnings = %v, want %d", assessment.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestBuildNamespaceBlockMessageListsUnknownSeparately(t *testing.T) {
+	rc := NewRiskCalculator(fake.NewClientset(), nil)
+
+	got := rc.buildNamespaceBlockMessage("app",
+		[]RiskyPVC{{Name: "data", Reason: "PV has Delete reclaim policy, no snapshot found"}},
+		[]UnknownPVC{{Name: "lost", Error: "PVC is Lost"}})
+
+	want := "DELETION BLOCKED: Namespace 'app' contains 1 PVC(s) that would lose data permanently\n\n" +
+		"Risky PVCs:\n" +
+		"  - data: PV has Delete reclaim policy, no snapshot found\n" +
+		"\nUnassessable PVCs:\n" +
+		"  - lost: PVC is Lost\n"
+	if got != want {
+		t.Errorf("buildNamespaceBlockMessage() = %q, want %q", got, want)
+	}
+}
+
+// staticBackupProvider returns the same backup, or error, for every PVC
+type staticBackupProvider struct {
+	backup *BackupInfo
+	err    error
+}
+
+func (p *staticBackupProvider) Name() string { return "static" }
+
+func (p *staticBackupProvider) FindBackup(context.Context, *corev1.PersistentVolumeClaim) (*BackupInfo, error) {
+	return p.backup, p.err
+}
+
+func TestIsPVCRisky(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "app", Labels: map[string]string{"app": "db"}},
+	}
+	newPV := func(policy corev1.PersistentVolumeReclaimPolicy) *corev1.PersistentVolume {
+		pv := newPhasedPV("pv-data", policy, corev1.VolumeBound, nil)
+		pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "hostpath.csi.k8s.io", VolumeHandle: "vol-1"}
+		return pv
+	}
+	completed := time.Date(2025, 11, 1, 12, 0, 0, 0, time.UTC)
+	backup := &BackupInfo{Provider: "velero", Name: "nightly", CompletionTime: completed}
+
+	tests := []struct {
+		name         string
+		policy       corev1.PersistentVolumeReclaimPolicy
+		snapshots    []runtime.Object
+		noChecker    bool
+		providers    []BackupProvider
+		wantRisky    bool
+		wantReason   string
+		wantEvidence string
+	}{
+		{
+			name:       "Retain policy is safe without evidence",
+			policy:     corev1.PersistentVolumeReclaimRetain,
+			wantReason: "PV has Retain reclaim policy",
+		},
+		{
+			name:       "Delete policy without snapshot checker is risky",
+			policy:     corev1.PersistentVolumeReclaimDelete,
+			noChecker:  true,
+			wantRisky:  true,
+			wantReason: "PV has Delete reclaim policy, no snapshot found",
+		},
+		{
+			name:       "Recycle policy without evidence is risky",
+			policy:     corev1.PersistentVolumeReclaimRecycle,
+			wantRisky:  true,
+			wantReason: "PV has Recycle reclaim policy, no snapshot found",
+		},
+		{
+			name:   "ready Retain snapshot protects",
+			policy: corev1.PersistentVolumeReclaimDelete,
+			snapshots: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				newVolumeSnapshot("snap", "data", "retain", true),
+			},
+			wantReason:   "Ready VolumeSnapshot 'snap' exists with Retain policy",
+			wantEvidence: "snapshot",
+		},
+		{
+			name:   "snapshot that is not ready does not protect",
+			policy: corev1.PersistentVolumeReclaimDelete,
+			snapshots: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				newVolumeSnapshot("snap", "data", "retain", false),
+			},
+			wantRisky:  true,
+			wantReason: "PV has Delete reclaim policy, no snapshot found",
+		},
+		{
+			name:   "snapshot with Delete class does not protect",
+			policy: corev1.PersistentVolumeReclaimDelete,
+			snapshots: []runtime.Object{
+				newVolumeSnapshotClass("delete", "Delete"),
+				newVolumeSnapshot("snap", "data", "delete", true),
+			},
+			wantRisky:  true,
+			wantReason: "PV has Delete reclaim policy, no snapshot found",
+		},
+		{
+			name:   "snapshot of a previous volume does not protect",
+			policy: corev1.PersistentVolumeReclaimDelete,
+			snapshots: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				newBoundVolumeSnapshot("old", "content-old", completed),
+				newVolumeSnapshotContent("content-old", "vol-old"),
+			},
+			wantRisky: true,
+			wantReason: "PV has Delete reclaim policy, no snapshot found; " +
+				"ignored VolumeSnapshot 'old' of a previous volume (source volume handle vol-old, current vol-1)",
+		},
+		{
+			name:         "pre-provisioned content of the volume protects",
+			policy:       corev1.PersistentVolumeReclaimDelete,
+			snapshots:    []runtime.Object{newPreProvisionedContent("backup-vol-1", "vol-1", "Retain", true)},
+			wantReason:   "Ready VolumeSnapshotContent 'backup-vol-1' of this volume exists with Retain policy",
+			wantEvidence: "content",
+		},
+		{
+			name:   "ready retained group snapshot protects",
+			policy: corev1.PersistentVolumeReclaimDelete,
+			snapshots: []runtime.Object{
+				newGroupSnapshot("db-group", true, map[string]interface{}{"app": "db"}, "content-1"),
+				newGroupSnapshotContent("content-1", "Retain", "vol-1"),
+			},
+			wantReason:   "Ready VolumeGroupSnapshot 'db-group' covers this PVC with Retain policy",
+			wantEvidence: "group",
+		},
+		{
+			name:         "completed backup protects",
+			policy:       corev1.PersistentVolumeReclaimDelete,
+			providers:    []BackupProvider{&staticBackupProvider{backup: backup}},
+			wantReason:   "velero backup 'nightly' completed at 2025-11-01T12:00:00Z covers this PVC",
+			wantEvidence: "backup",
+		},
+		{
+			name:   "failing provider is skipped for the next one",
+			policy: corev1.PersistentVolumeReclaimDelete,
+			providers: []BackupProvider{
+				&staticBackupProvider{err: errors.New("backup API unavailable")},
+				&staticBackupProvider{backup: backup},
+			},
+			wantReason:   "velero backup 'nightly' completed at 2025-11-01T12:00:00Z covers this PVC",
+			wantEvidence: "backup",
+		},
+		{
+			name:       "provider without backup leaves the PVC risky",
+			policy:     corev1.PersistentVolumeReclaimDelete,
+			providers:  []BackupProvider{&staticBackupProvider{}},
+			wantRisky:  true,
+			wantReason: "PV has Delete reclaim policy, no snapshot found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checker *SnapshotChecker
+			if !tt.noChecker {
+				checker = newFakeSnapshotChecker(tt.snapshots...)
+			}
+			rc := NewRiskCalculator(fake.NewClientset(), checker)
+			for _, provider := range tt.providers {
+				rc.AddBackupProvider(provider)
+			}
+
+			pv := newPV(tt.policy)
+			risk := rc.isPVCRisky(context.Background(), pvc, pv, rc.indexVolume(context.Background(), pvc, pv), defaultProtection)
+			if risk.isRisky != tt.wantRisky {
+				t.Errorf("isRisky = %v, want %v", risk.isRisky, tt.wantRisky)
+			}
+			if risk.reason != tt.wantReason {
+				t.Errorf("reason = %q, want %q", risk.reason, tt.wantReason)
+			}
+
+			evidence := map[string]bool{
+				"snapshot": risk.snapshot != nil,
+				"content":  risk.snapshotContent != nil,
+				"group":    risk.groupSnapshot != nil,
+				"backup":   risk.backup != nil,
+			}
+			for kind, present := range evidence {
+				if present != (kind == tt.wantEvidence) {
+					t.Errorf("%s evidence present = %v, want evidence %q", kind, present, tt.wantEvidence)
+				}
+			}
+		})
+	}
+}
+
+func TestBlockMessages(t *testing.T) {
+	rc := NewRiskCalculator(fake.NewClientset(), nil)
+	risky := RiskyPVC{Name: "data", Namespace: "app", PVName: "pv-data", Reason: "PV has Delete reclaim policy, no snapshot found"}
+	pv := newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "PVC",
+			got:  rc.buildPVCBlockMessage(risky),
+			want: "DELETION BLOCKED: PVC 'app/data' would lose data permanently\n\n" +
+				"Reason: PV has Delete reclaim policy, no snapshot found\n",
+		},
+		{
+			name: "bound PV",
+			got:  rc.buildPVBlockMessage(pv, risky),
+			want: "DELETION BLOCKED: PV 'pv-data' would lose data permanently\n\n" +
+				"Reason: PV has Delete reclaim policy, no snapshot found\n" +
+				"Bound to: app/data\n",
+		},
+		{
+			name: "unbound PV",
+			got:  rc.buildPVBlockMessage(pv, RiskyPVC{PVName: "pv-data", Reason: risky.Reason}),
+			want: "DELETION BLOCKED: PV 'pv-data' would lose data permanently\n\n" +
+				"Reason: PV has Delete reclaim policy, no snapshot found\n",
+		},
+		{
+			name: "namespace with only unassessable PVCs",
+			got:  rc.buildNamespaceBlockMessage("app", nil, []UnknownPVC{{Name: "lost", Error: "PVC is Lost"}}),
+			want: "DELETION BLOCKED: Namespace 'app' contains 1 PVC(s) that could not be assessed\n" +
+				"\nUnassessable PVCs:\n" +
+				"  - lost: PVC is Lost\n",
+		},
+		{
+			name: "namespace with several risky PVCs",
+			got: rc.buildNamespaceBlockMessage("app", []RiskyPVC{
+				risky,
+				{Name: "cache", Reason: "PV has Recycle reclaim policy, no snapshot found"},
+			}, nil),
+			want: "DELETION BLOCKED: Namespace 'app' contains 2 PVC(s) that would lose data permanently\n\n" +
+				"Risky PVCs:\n" +
+				"  - data: PV has Delete reclaim policy, no snapshot found\n" +
+				"  - cache: PV has Recycle reclaim policy, no snapshot found\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("message = %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	rc := NewRiskCalculator(fake.NewClientset(), nil)
+	rc.SetBypassLabel("example.com/force")
+	pv := newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)
+	patch := `'{"spec":{"persistentVolumeReclaimPolicy":"Retain"}}'`
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{
+			name: "PVC",
+			got:  rc.buildPVCSuggestions("app", "data", "pv-data"),
+			want: "\nTo safely delete this PVC:\n" +
+				"  1. Create a VolumeSnapshot of the data\n" +
+				"  2. OR change PV reclaim policy to Retain:\n" +
+				"     kubectl patch pv pv-data -p " + patch + "\n" +
+				"\n  3. OR force delete (will lose data):\n" +
+				"     kubectl label pvc data -n app example.com/force=true\n" +
+				"     kubectl delete pvc data -n app\n" +
+				"\n  4. Then retry the deletion\n",
+		},
+		{
+			name: "namespace",
+			got:  rc.buildSuggestions("app", []RiskyPVC{{PVName: "pv-data"}, {PVName: "pv-cache"}}),
+			want: "\nTo safely delete this resource:\n" +
+				"  1. Create VolumeSnapshots for the PVCs\n" +
+				"  2. OR change PV reclaim policy to Retain:\n" +
+				"     kubectl patch pv pv-data -p " + patch + "\n" +
+				"     kubectl patch pv pv-cache -p " + patch + "\n" +
+				"\n  3. OR force delete (will lose data):\n" +
+				"     kubectl label namespace app example.com/force=true\n" +
+				"     kubectl delete namespace app\n" +
+				"\n  4. Then retry the deletion\n",
+		},
+		{
+			name: "PV",
+			got:  rc.buildPVSuggestions(pv),
+			want: "\nTo safely delete this PV:\n" +
+				"  1. Create a VolumeSnapshot of the data\n" +
+				"  2. OR change reclaim policy to Retain:\n" +
+				"     kubectl patch pv pv-data -p " + patch + "\n" +
+				"\n  3. OR force delete (will lose data):\n" +
+				"     kubectl label pv pv-data example.com/force=true\n" +
+				"     kubectl delete pv pv-data\n" +
+				"\n  4. Then retry the deletion\n",
+		},
+		{
+			name: "retained PV",
+			got:  rc.buildRetainedPVSuggestions(pv),
+			want: "\nThis PV is the last copy of data retained from a deleted PVC.\n" +
+				"To safely delete this PV:\n" +
+				"  1. Back up or snapshot the data (e.g. bind it to a new PVC and create a VolumeSnapshot)\n" +
+				"\n  2. OR force delete (will lose data):\n" +
+				"     kubectl label pv pv-data example.com/force=true\n" +
+				"     kubectl delete pv pv-data\n" +
+				"\n  3. Then retry the deletion\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("suggestion = %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssessmentCauses(t *testing.T) {
+	assessment := &RiskAssessment{
+		RiskyPVCs: []RiskyPVC{
+			{Name: "data", Namespace: "app", PVName: "pv-data", Reason: "PV has Delete reclaim policy, no snapshot found; ignored VolumeSnapshot 'old'",
+				ReasonCode: ReasonReclaimPolicy, SnapshotState: SnapshotStatePreviousVolumeOnly},
+			{PVName: "pv-unclaimed", Reason: "PV has Delete reclaim policy, no snapshot found",
+				ReasonCode: ReasonReclaimPolicy, SnapshotState: SnapshotStateUnknown},
+		},
+	}
+
+	want := []metav1.StatusCause{
+		{
+			Type:    ReasonReclaimPolicy,
+			Field:   "app/data",
+			Message: "pv=pv-data snapshot=PreviousVolumeOnly: PV has Delete reclaim policy, no snapshot found; ignored VolumeSnapshot 'old'",
+		},
+		{
+			Type:    ReasonReclaimPolicy,
+			Message: "pv=pv-unclaimed snapshot=Unknown: PV has Delete reclaim policy, no snapshot found",
+		},
+	}
+	if got := assessment.Causes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Causes() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RunServer runs serve (e.g. server.ListenAndServeTLS) until ctx is cancelled and then
+// shuts the server down gracefully:
+//  1. readiness starts failing so the API server stops sending admission reviews here
+//  2. the server keeps serving for drainPeriod while the endpoint removal propagates
+//  3. server.Shutdown stops accepting connections and waits up to shutdownTimeout for
+//     in-flight requests, so admission reviews are answered instead of hitting the
+//     webhook failurePolicy
+func RunServer(ctx context.Context, server *http.Server, serve func() error, handler *Handler, drainPeriod, shutdownTimeout time.Duration) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	handler.Logger.Printf("Shutdown requested, failing readiness and draining for %s", drainPeriod)
+	handler.StartShutdown()
+
+	drain := time.NewTimer(drainPeriod)
+	select {
+	case err := <-serveErr:
+		drain.Stop()
+		return err
+	case <-drain.C:
+	}
+
+	handler.Logger.Printf("Stopping server, waiting up to %s for in-flight requests", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown did not complete: %w", err)
+	}
+
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	handler.Logger.Println("Server stopped")
+
+	return nil
+}
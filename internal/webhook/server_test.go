@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newDeletePVCReview(t *testing.T) []byte {
+	t.Helper()
+
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("review-1"),
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "PersistentVolumeClaim"},
+			Operation: admissionv1.Delete,
+			Namespace: "app",
+			Name:      "data",
+		},
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatalf("failed to marshal review: %v", err)
+	}
+	return body
+}
+
+func TestRunServerDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	// The first PVC lookup of the admission review blocks until released
+	var once sync.Once
+	client := fake.NewClientset()
+	client.PrependReactor("get", "persistentvolumeclaims", func(k8stesting.Action) (bool, runtime.Object, error) {
+		once.Do(func() { close(started) })
+		<-release
+		return false, nil, nil
+	})
+
+	handler := NewHandler(log.New(io.Discard, "", 0), client, nil)
+	handler.AssessmentTimeout = 10 * time.Second
+	// The fake clientset serializes calls, so API-backed checks would wait for the blocked lookup
+	handler.ReadinessChecks = nil
+	mux := http.NewServeMux()
+	mux.Handle("/validate", handler)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: time.Second}
+	url := "http://" + listener.Addr().String() + "/validate"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- RunServer(ctx, server, func() error { return server.Serve(listener) },
+			handler, 100*time.Millisecond, 5*time.Second)
+	}()
+
+	type result struct {
+		review *admissionv1.AdmissionReview
+		err    error
+	}
+	body := newDeletePVCReview(t)
+	done := make(chan result, 1)
+	go func() {
+		resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		var review admissionv1.AdmissionReview
+		err = json.NewDecoder(resp.Body).Decode(&review)
+		done <- result{review: &review, err: err}
+	}()
+
+	<-started
+	cancel()
+
+	// Readiness fails as soon as shutdown starts
+	deadline := time.Now().Add(time.Second)
+	for {
+		recorder := httptest.NewRecorder()
+		handler.ReadyCheck(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		if recorder.Code == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("readiness did not fail after shutdown started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Let the request finish after the drain period, while Shutdown is waiting for it
+	time.Sleep(300 * time.Millisecond)
+	select {
+	case err := <-runErr:
+		t.Fatalf("RunServer() returned %v before the in-flight request completed", err)
+	default:
+	}
+	close(release)
+
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("in-flight request failed: %v", res.err)
+	}
+	if res.review.Response == nil || res.review.Response.UID != "review-1" {
+		t.Fatalf("in-flight request got response %+v, want the admission response", res.review.Response)
+	}
+
+	if err := <-runErr; err != nil {
+		t.Fatalf("RunServer() error = %v", err)
+	}
+
+	if _, err := http.Post(url, "application/json", bytes.NewReader(body)); err == nil {
+		t.Error("server accepted a request after shutdown")
+	}
+}
@@ -3,7 +3,10 @@ package webhook
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -19,8 +22,15 @@ const (
 
 // SnapshotChecker checks for VolumeSnapshots
 type SnapshotChecker struct {
-	dynamicClient dynamic.Interface
-	clientset     kubernetes.Interface
+	dynamicClient         dynamic.Interface
+	clientset             kubernetes.Interface
+	groupSnapshotsEnabled bool
+
+	// mu guards the discovered VolumeGroupSnapshot API version
+	mu                sync.Mutex
+	groupVersion      string
+	groupVersionFinal bool
+	groupVersionAt    time.Time
 }
 
 // NewSnapshotChecker creates a new snapshot checker
@@ -30,10 +40,22 @@ func NewSnapshotChecker(config *rest.Config, clientset kubernetes.Interface) (*S
 		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
 	}
 
+	return NewSnapshotCheckerForClient(dynamicClient, clientset), nil
+}
+
+// NewSnapshotCheckerForClient creates a snapshot checker from an existing dynamic client,
+// e.g. one shared with other components or a fake in tests
+func NewSnapshotCheckerForClient(dynamicClient dynamic.Interface, clientset kubernetes.Interface) *SnapshotChecker {
 	return &SnapshotChecker{
-		dynamicClient: dynamicClient,
-		clientset:     clientset,
-	}, nil
+		dynamicClient:         dynamicClient,
+		clientset:             clientset,
+		groupSnapshotsEnabled: true,
+	}
+}
+
+// SetGroupSnapshotsEnabled controls whether VolumeGroupSnapshots count as evidence
+func (sc *SnapshotChecker) SetGroupSnapshotsEnabled(enabled bool) {
+	sc.groupSnapshotsEnabled = enabled
 }
 
 var volumeSnapshotGVR = schema.GroupVersionResource{
@@ -42,6 +64,18 @@ var volumeSnapshotGVR = schema.GroupVersionResource{
 	Resource: "volumesnapshots",
 }
 
+var volumeSnapshotClassGVR = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshotclasses",
+}
+
+var volumeSnapshotContentGVR = schema.GroupVersionResource{
+	Group:    "snapshot.storage.k8s.io",
+	Version:  "v1",
+	Resource: "volumesnapshotcontents",
+}
+
 // SnapshotInfo contains information about a VolumeSnapshot
 type SnapshotInfo struct {
 	Name           string
@@ -51,22 +85,41 @@ type SnapshotInfo struct {
 	DeletionPolicy string
 	CreationTime   metav1.Time
 	RestoreSize    string
+	// ContentName is the bound VolumeSnapshotContent
+	ContentName string
+	// SourceVolumeHandle is the CSI volume handle the content was taken from, when known
+	SourceVolumeHandle string
 }
 
-// HasReadySnapshot checks if a PVC has a Ready VolumeSnapshot with Retain policy
-func (sc *SnapshotChecker) HasReadySnapshot(ctx context.Context, namespace, pvcName string) (bool, *SnapshotInfo, error) {
-	snapshots, err := sc.dynamicClient.Resource(volumeSnapshotGVR).Namespace(namespace).List(ctx, metav1.ListOptions{})
+// HasReadySnapshot checks if a PVC has a Ready VolumeSnapshot with Retain policy that was
+// taken from the PVC's current volume
+func (sc *SnapshotChecker) HasReadySnapshot(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) (bool, *SnapshotInfo, error) {
+	snapshots, err := sc.dynamicClient.Resource(volumeSnapshotGVR).Namespace(pvc.Namespace).List(ctx, metav1.ListOptions{})
 	if err != nil {
 		// VolumeSnapshot CRD might not be installed
 		return false, nil, fmt.Errorf("failed to list volumesnapshots (CSI snapshots may not be available): %w", err)
 	}
 
-	for _, item := range snapshots.Items {
+	// Contents that cannot be read leave only the creation time to check lineage
+	_, handles, _ := sc.lookupSnapshotContents(ctx, nil, readySnapshotContentNames(snapshots.Items, pvc.Name))
+
+	info, _ := findSnapshot(sc.indexSnapshots(ctx, snapshots.Items, handles)[pvc.Name], pvc, pv)
+	return info != nil, info, nil
+}
+
+// indexSnapshots collects the Ready VolumeSnapshots with Retain policy for each source PVC,
+// in list order, with the source volume of their bound VolumeSnapshotContent taken from
+// contentHandles. VolumeSnapshotClass deletion policies are looked up once per class.
+func (sc *SnapshotChecker) indexSnapshots(ctx context.Context, items []unstructured.Unstructured, contentHandles map[string]string) map[string][]*SnapshotInfo {
+	result := map[string][]*SnapshotInfo{}
+	classPolicies := map[string]string{}
+
+	for _, item := range items {
 		snapshot := item.Object
 
-		// Check if this snapshot is for our PVC
+		// Skip snapshots without a source PVC
 		sourcePVC, found, err := unstructured.NestedString(snapshot, "spec", "source", "persistentVolumeClaimName")
-		if err != nil || !found || sourcePVC != pvcName {
+		if err != nil || !found {
 			continue
 		}
 
@@ -80,13 +133,22 @@ func (sc *SnapshotChecker) HasReadySnapshot(ctx context.Context, namespace, pvcN
 		deletionPolicy := UnknownDeletionPolicy
 		snapshotClassName, found, _ := unstructured.NestedString(snapshot, "spec", "volumeSnapshotClassName")
 		if found && snapshotClassName != "" {
-			policy, err := sc.getSnapshotClassDeletionPolicy(ctx, snapshotClassName)
-			if err == nil {
-				deletionPolicy = policy
+			policy, cached := classPolicies[snapshotClassName]
+			if !cached {
+				policy, err = sc.getSnapshotClassDeletionPolicy(ctx, snapshotClassName)
+				if err != nil {
+					policy = UnknownDeletionPolicy
+				}
+				classPolicies[snapshotClassName] = policy
 			}
+			deletionPolicy = policy
+		}
+
+		// Only a ready snapshot with Retain policy protects the PVC
+		if deletionPolicy != "Retain" {
+			continue
 		}
 
-		// Extract snapshot info
 		info := &SnapshotInfo{
 			Name:           item.GetName(),
 			Namespace:      item.GetNamespace(),
@@ -101,27 +163,96 @@ func (sc *SnapshotChecker) HasReadySnapshot(ctx context.Context, namespace, pvcN
 			info.RestoreSize = restoreSize
 		}
 
-		// If snapshot is ready with Retain policy, return true
-		if deletionPolicy == "Retain" {
-			return true, info, nil
+		// The content records which volume was snapshotted; without it only the
+		// creation time can tell snapshots of a previous volume apart
+		if contentName, found, _ := unstructured.NestedString(snapshot, "status", "boundVolumeSnapshotContentName"); found {
+			info.ContentName = contentName
+			info.SourceVolumeHandle = contentHandles[contentName]
 		}
 
-		// If we found a ready snapshot but policy is not Retain, continue looking
-		// There might be another snapshot with Retain policy
+		result[sourcePVC] = append(result[sourcePVC], info)
 	}
 
-	return false, nil, nil
+	return result
 }
 
-// getSnapshotClassDeletionPolicy gets the deletion policy from a VolumeSnapshotClass
-func (sc *SnapshotChecker) getSnapshotClassDeletionPolicy(ctx context.Context, className string) (string, error) {
-	snapshotClassGVR := schema.GroupVersionResource{
-		Group:    "snapshot.storage.k8s.io",
-		Version:  "v1",
-		Resource: "volumesnapshotclasses",
+// readySnapshotContentNames returns the VolumeSnapshotContents bound to the Ready snapshots
+// of a source PVC, or of every PVC when sourcePVC is empty
+func readySnapshotContentNames(items []unstructured.Unstructured, sourcePVC string) []string {
+	var names []string
+	for _, item := range items {
+		source, _, _ := unstructured.NestedString(item.Object, "spec", "source", "persistentVolumeClaimName")
+		if source == "" || (sourcePVC != "" && source != sourcePVC) {
+			continue
+		}
+		if ready, _, _ := unstructured.NestedBool(item.Object, "status", "readyToUse"); !ready {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(item.Object, "status", "boundVolumeSnapshotContentName"); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// findSnapshot returns the first snapshot taken from the PVC's current volume. It also
+// describes the snapshots that were skipped because they belong to a previous volume
+// of a PVC with the same name.
+func findSnapshot(candidates []*SnapshotInfo, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) (*SnapshotInfo, []string) {
+	var mismatches []string
+
+	for _, candidate := range candidates {
+		if mismatch := snapshotLineageMismatch(candidate, pvc, pv); mismatch != "" {
+			mismatches = append(mismatches, mismatch)
+			continue
+		}
+		return candidate, mismatches
+	}
+
+	return nil, mismatches
+}
+
+// snapshotLineageMismatch explains why a snapshot was not taken from the PVC's current
+// volume, or returns "" if it was. The source volume handle is compared when both sides
+// report one; otherwise a snapshot older than the PVC cannot be of its volume.
+func snapshotLineageMismatch(info *SnapshotInfo, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) string {
+	if info.SourceVolumeHandle != "" && pv != nil && pv.Spec.CSI != nil {
+		if info.SourceVolumeHandle == pv.Spec.CSI.VolumeHandle {
+			return ""
+		}
+		return fmt.Sprintf("ignored VolumeSnapshot '%s' of a previous volume (source volume handle %s, current %s)",
+			info.Name, info.SourceVolumeHandle, pv.Spec.CSI.VolumeHandle)
 	}
 
-	class, err := sc.dynamicClient.Resource(snapshotClassGVR).Get(ctx, className, metav1.GetOptions{})
+	if !pvc.CreationTimestamp.IsZero() && info.CreationTime.Before(&pvc.CreationTimestamp) {
+		return fmt.Sprintf("ignored VolumeSnapshot '%s' taken at %s, before the PVC was created at %s",
+			info.Name, info.CreationTime.UTC().Format(time.RFC3339), pvc.CreationTimestamp.UTC().Format(time.RFC3339))
+	}
+
+	return ""
+}
+
+// ListSnapshotClassesForDriver lists the names of VolumeSnapshotClasses that use a CSI driver
+func (sc *SnapshotChecker) ListSnapshotClassesForDriver(ctx context.Context, driver string) ([]string, error) {
+	classes, err := sc.dynamicClient.Resource(volumeSnapshotClassGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list volumesnapshotclasses: %w", err)
+	}
+
+	var names []string
+	for _, item := range classes.Items {
+		classDriver, _, _ := unstructured.NestedString(item.Object, "driver")
+		if classDriver == driver {
+			names = append(names, item.GetName())
+		}
+	}
+
+	return names, nil
+}
+
+// getSnapshotClassDeletionPolicy gets the deletion policy from a VolumeSnapshotClass
+func (sc *SnapshotChecker) getSnapshotClassDeletionPolicy(ctx context.Context, className string) (string, error) {
+	class, err := sc.dynamicClient.Resource(volumeSnapshotClassGVR).Get(ctx, className, metav1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,326 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newCSIPV(volumeHandle string) *corev1.PersistentVolume {
+	pv := newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil)
+	pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: "hostpath.csi.k8s.io", VolumeHandle: volumeHandle}
+	return pv
+}
+
+// newBoundVolumeSnapshot creates a ready snapshot with Retain class "retain", bound to a content
+// and created at the given time
+func newBoundVolumeSnapshot(name, contentName string, created time.Time) *unstructured.Unstructured {
+	snapshot := newVolumeSnapshot(name, "data", "retain", true)
+	snapshot.SetCreationTimestamp(metav1.NewTime(created))
+	if contentName != "" {
+		_ = unstructured.SetNestedField(snapshot.Object, contentName, "status", "boundVolumeSnapshotContentName")
+	}
+	return snapshot
+}
+
+func newVolumeSnapshotContent(name, volumeHandle string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshotContent",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"deletionPolicy": "Retain",
+			"driver":         "hostpath.csi.k8s.io",
+			"source":         map[string]interface{}{"volumeHandle": volumeHandle},
+		},
+	}}
+}
+
+func TestHasReadySnapshot(t *testing.T) {
+	withRestoreSize := newVolumeSnapshot("sized", "data", "retain", true)
+	_ = unstructured.SetNestedField(withRestoreSize.Object, "5Gi", "status", "restoreSize")
+
+	noClass := newVolumeSnapshot("classless", "data", "", true)
+	unstructured.RemoveNestedField(noClass.Object, "spec", "volumeSnapshotClassName")
+
+	policyless := newVolumeSnapshotClass("policyless", "")
+	unstructured.RemoveNestedField(policyless.Object, "deletionPolicy")
+
+	tests := []struct {
+		name         string
+		objects      []runtime.Object
+		wantSnapshot string
+		wantSize     string
+	}{
+		{
+			name: "ready snapshot with Retain class",
+			objects: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				newVolumeSnapshot("snap", "data", "retain", true),
+			},
+			wantSnapshot: "snap",
+		},
+		{
+			name: "restore size is reported",
+			objects: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				withRestoreSize,
+			},
+			wantSnapshot: "sized",
+			wantSize:     "5Gi",
+		},
+		{
+			name: "snapshot not ready",
+			objects: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				newVolumeSnapshot("snap", "data", "retain", false),
+			},
+		},
+		{
+			name: "snapshot of another PVC",
+			objects: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				newVolumeSnapshot("snap", "other", "retain", true),
+			},
+		},
+		{
+			name: "class has Delete policy",
+			objects: []runtime.Object{
+				newVolumeSnapshotClass("delete", "Delete"),
+				newVolumeSnapshot("snap", "data", "delete", true),
+			},
+		},
+		{
+			name:    "class does not exist so the policy is Unknown",
+			objects: []runtime.Object{newVolumeSnapshot("snap", "data", "missing", true)},
+		},
+		{
+			name: "class without deletionPolicy is Unknown",
+			objects: []runtime.Object{
+				policyless,
+				newVolumeSnapshot("snap", "data", "policyless", true),
+			},
+		},
+		{
+			name: "snapshot without class is Unknown",
+			objects: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				noClass,
+			},
+		},
+		{
+			name: "later Retain snapshot is found after unusable ones",
+			objects: []runtime.Object{
+				newVolumeSnapshotClass("retain", "Retain"),
+				newVolumeSnapshotClass("delete", "Delete"),
+				newVolumeSnapshot("a-pending", "data", "retain", false),
+				newVolumeSnapshot("b-deleted", "data", "delete", true),
+				newVolumeSnapshot("c-retained", "data", "retain", true),
+			},
+			wantSnapshot: "c-retained",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := newFakeSnapshotChecker(tt.objects...)
+
+			found, info, err := sc.HasReadySnapshot(context.Background(), newNamespacePVC("data", corev1.ClaimBound, "pv-data"), newCSIPV("vol-1"))
+			if err != nil {
+				t.Fatalf("HasReadySnapshot() error = %v", err)
+			}
+
+			if tt.wantSnapshot == "" {
+				if found || info != nil {
+					t.Fatalf("HasReadySnapshot() = %v, %+v, want no snapshot", found, info)
+				}
+				return
+			}
+
+			if !found || info == nil {
+				t.Fatalf("HasReadySnapshot() = false, want %q", tt.wantSnapshot)
+			}
+			want := SnapshotInfo{
+				Name:           tt.wantSnapshot,
+				Namespace:      "app",
+				SourcePVC:      "data",
+				IsReady:        true,
+				DeletionPolicy: "Retain",
+				RestoreSize:    tt.wantSize,
+			}
+			if *info != want {
+				t.Errorf("HasReadySnapshot() = %+v, want %+v", *info, want)
+			}
+		})
+	}
+}
+
+func TestHasReadySnapshotListError(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{volumeSnapshotGVR: "VolumeSnapshotList"})
+	client.PrependReactor("list", "volumesnapshots", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("the server could not find the requested resource")
+	})
+	sc := NewSnapshotCheckerForClient(client, nil)
+
+	found, info, err := sc.HasReadySnapshot(context.Background(), newNamespacePVC("data", corev1.ClaimBound, "pv-data"), newCSIPV("vol-1"))
+	if err == nil || !strings.Contains(err.Error(), "CSI snapshots may not be available") {
+		t.Fatalf("HasReadySnapshot() error = %v, want CSI snapshot availability error", err)
+	}
+	if found || info != nil {
+		t.Errorf("HasReadySnapshot() = %v, %+v, want no snapshot on error", found, info)
+	}
+	if sc.IsSnapshotAPIAvailable(context.Background()) {
+		t.Error("IsSnapshotAPIAvailable() = true, want false when listing fails")
+	}
+}
+
+func TestListSnapshotsReportsEverySnapshotOfThePVC(t *testing.T) {
+	sc := newFakeSnapshotChecker(
+		newVolumeSnapshotClass("retain", "Retain"),
+		newVolumeSnapshotClass("delete", "Delete"),
+		newVolumeSnapshot("a-pending", "data", "retain", false),
+		newVolumeSnapshot("b-deleted", "data", "delete", true),
+		newVolumeSnapshot("c-unknown", "data", "missing", true),
+		newVolumeSnapshot("d-other", "other", "retain", true),
+	)
+
+	snapshots, err := sc.ListSnapshots(context.Background(), "app", "data")
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+
+	want := []struct {
+		name   string
+		ready  bool
+		policy string
+	}{
+		{"a-pending", false, "Retain"},
+		{"b-deleted", true, "Delete"},
+		{"c-unknown", true, UnknownDeletionPolicy},
+	}
+	if len(snapshots) != len(want) {
+		t.Fatalf("ListSnapshots() returned %d snapshots, want %d", len(snapshots), len(want))
+	}
+	for i, w := range want {
+		got := snapshots[i]
+		if got.Name != w.name || got.IsReady != w.ready || got.DeletionPolicy != w.policy {
+			t.Errorf("snapshot %d = %+v, want %s ready=%v policy=%s", i, *got, w.name, w.ready, w.policy)
+		}
+	}
+}
+
+func TestSnapshotLineage(t *testing.T) {
+	pvcCreated := time.Date(2025, 11, 1, 12, 0, 0, 0, time.UTC)
+	before := pvcCreated.Add(-30 * 24 * time.Hour)
+	after := pvcCreated.Add(time.Hour)
+
+	tests := []struct {
+		name         string
+		pv           *corev1.PersistentVolume
+		objects      []runtime.Object
+		wantSnapshot string
+		wantIgnored  []string
+	}{
+		{
+			name: "content taken from the current volume",
+			pv:   newCSIPV("vol-1"),
+			objects: []runtime.Object{
+				newBoundVolumeSnapshot("snap", "content-1", after),
+				newVolumeSnapshotContent("content-1", "vol-1"),
+			},
+			wantSnapshot: "snap",
+		},
+		{
+			name: "matching volume handle wins over an older timestamp",
+			pv:   newCSIPV("vol-1"),
+			objects: []runtime.Object{
+				newBoundVolumeSnapshot("snap", "content-1", before),
+				newVolumeSnapshotContent("content-1", "vol-1"),
+			},
+			wantSnapshot: "snap",
+		},
+		{
+			name: "content taken from a previous volume",
+			pv:   newCSIPV("vol-1"),
+			objects: []runtime.Object{
+				newBoundVolumeSnapshot("old", "content-old", after),
+				newVolumeSnapshotContent("content-old", "vol-old"),
+			},
+			wantIgnored: []string{"ignored VolumeSnapshot 'old' of a previous volume (source volume handle vol-old, current vol-1)"},
+		},
+		{
+			name: "snapshot of the current volume after one of a previous volume",
+			pv:   newCSIPV("vol-1"),
+			objects: []runtime.Object{
+				newBoundVolumeSnapshot("a-old", "content-old", before),
+				newVolumeSnapshotContent("content-old", "vol-old"),
+				newBoundVolumeSnapshot("b-new", "content-new", after),
+				newVolumeSnapshotContent("content-new", "vol-1"),
+			},
+			wantSnapshot: "b-new",
+			wantIgnored:  []string{"ignored VolumeSnapshot 'a-old' of a previous volume (source volume handle vol-old, current vol-1)"},
+		},
+		{
+			name:         "unreadable content falls back to a newer timestamp",
+			pv:           newCSIPV("vol-1"),
+			objects:      []runtime.Object{newBoundVolumeSnapshot("snap", "missing", after)},
+			wantSnapshot: "snap",
+		},
+		{
+			name:        "unreadable content and a snapshot older than the PVC",
+			pv:          newCSIPV("vol-1"),
+			objects:     []runtime.Object{newBoundVolumeSnapshot("old", "", before)},
+			wantIgnored: []string{"ignored VolumeSnapshot 'old' taken at 2025-10-02T12:00:00Z, before the PVC was created at 2025-11-01T12:00:00Z"},
+		},
+		{
+			name: "non-CSI volume compares timestamps",
+			pv:   newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil),
+			objects: []runtime.Object{
+				newBoundVolumeSnapshot("old", "content-old", before),
+				newVolumeSnapshotContent("content-old", "vol-old"),
+			},
+			wantIgnored: []string{"ignored VolumeSnapshot 'old' taken at 2025-10-02T12:00:00Z, before the PVC was created at 2025-11-01T12:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := append([]runtime.Object{newVolumeSnapshotClass("retain", "Retain")}, tt.objects...)
+			sc := newFakeSnapshotChecker(objects...)
+			pvc := newNamespacePVC("data", corev1.ClaimBound, "pv-data")
+			pvc.CreationTimestamp = metav1.NewTime(pvcCreated)
+
+			info, ignored := sc.IndexNamespace(context.Background(), "app").ReadySnapshot(pvc, tt.pv)
+
+			gotSnapshot := ""
+			if info != nil {
+				gotSnapshot = info.Name
+			}
+			if gotSnapshot != tt.wantSnapshot {
+				t.Errorf("ReadySnapshot() = %q, want %q", gotSnapshot, tt.wantSnapshot)
+			}
+			if strings.Join(ignored, "\n") != strings.Join(tt.wantIgnored, "\n") {
+				t.Errorf("ignored = %q, want %q", ignored, tt.wantIgnored)
+			}
+
+			found, _, err := sc.HasReadySnapshot(context.Background(), pvc, tt.pv)
+			if err != nil {
+				t.Fatalf("HasReadySnapshot() error = %v", err)
+			}
+			if found != (tt.wantSnapshot != "") {
+				t.Errorf("HasReadySnapshot() = %v, want %v", found, tt.wantSnapshot != "")
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SnapshotContentInfo contains information about a VolumeSnapshotContent
+type SnapshotContentInfo struct {
+	Name           string
+	VolumeHandle   string
+	SnapshotHandle string
+	IsReady        bool
+	DeletionPolicy string
+	CreationTime   metav1.Time
+}
+
+// snapshotContentPageSize is how many VolumeSnapshotContents are read per list call
+const snapshotContentPageSize = 500
+
+// HasReadySnapshotContent checks if a Ready VolumeSnapshotContent with Retain policy was
+// taken from the PV's CSI volume. Backup tools may create such contents directly, without a
+// namespaced VolumeSnapshot, so they are found by volume handle rather than through a PVC.
+func (sc *SnapshotChecker) HasReadySnapshotContent(ctx context.Context, pv *corev1.PersistentVolume) (bool, *SnapshotContentInfo, error) {
+	info, err := sc.findVolumeSnapshotContent(ctx, pv)
+	return info != nil, info, err
+}
+
+// findVolumeSnapshotContent looks for the Ready, retained VolumeSnapshotContent of the PV's
+// CSI volume. PVs without a CSI volume handle are not looked up.
+func (sc *SnapshotChecker) findVolumeSnapshotContent(ctx context.Context, pv *corev1.PersistentVolume) (*SnapshotContentInfo, error) {
+	info, _, err := sc.lookupSnapshotContents(ctx, pv, nil)
+	return info, err
+}
+
+// lookupSnapshotContents reads VolumeSnapshotContents until it has found the Ready, retained
+// content of the PV's CSI volume and the source volume handle of every named content.
+// Contents are cluster-scoped and cannot be selected by volume handle, so the pages are
+// read in one pass that stops as soon as nothing is left to find; when nothing is wanted,
+// nothing is listed.
+func (sc *SnapshotChecker) lookupSnapshotContents(ctx context.Context, pv *corev1.PersistentVolume, contentNames []string) (*SnapshotContentInfo, map[string]string, error) {
+	wantContent := pv != nil && pv.Spec.CSI != nil && pv.Spec.CSI.VolumeHandle != ""
+	pending := map[string]bool{}
+	for _, name := range contentNames {
+		pending[name] = true
+	}
+
+	var info *SnapshotContentInfo
+	handles := map[string]string{}
+	if !wantContent && len(pending) == 0 {
+		return nil, handles, nil
+	}
+
+	err := sc.listSnapshotContents(ctx, func(items []unstructured.Unstructured) bool {
+		for name, handle := range snapshotContentVolumeHandles(items) {
+			if pending[name] {
+				handles[name] = handle
+				delete(pending, name)
+			}
+		}
+		if wantContent && info == nil {
+			info = findSnapshotContent(indexSnapshotContents(items), pv)
+		}
+		return len(pending) > 0 || (wantContent && info == nil)
+	})
+	return info, handles, err
+}
+
+// listSnapshotContents reads the VolumeSnapshotContents page by page, passing each page to
+// visit until it returns false or the last page was read
+func (sc *SnapshotChecker) listSnapshotContents(ctx context.Context, visit func([]unstructured.Unstructured) bool) error {
+	options := metav1.ListOptions{Limit: snapshotContentPageSize}
+	for {
+		contents, err := sc.dynamicClient.Resource(volumeSnapshotContentGVR).List(ctx, options)
+		if err != nil {
+			return fmt.Errorf("failed to list volumesnapshotcontents (CSI snapshots may not be available): %w", err)
+		}
+
+		if !visit(contents.Items) {
+			return nil
+		}
+
+		options.Continue = contents.GetContinue()
+		if options.Continue == "" {
+			return nil
+		}
+	}
+}
+
+// snapshotContentVolumeHandles maps each VolumeSnapshotContent to the CSI volume handle it was
+// taken from, or "" for contents pre-provisioned from a snapshot handle
+func snapshotContentVolumeHandles(items []unstructured.Unstructured) map[string]string {
+	result := make(map[string]string, len(items))
+	for _, item := range items {
+		result[item.GetName()], _, _ = unstructured.NestedString(item.Object, "spec", "source", "volumeHandle")
+	}
+	return result
+}
+
+// indexSnapshotContents keeps the Ready VolumeSnapshotContents with Retain policy that
+// record their source volume, keyed by CSI volume handle. The first content per handle wins.
+func indexSnapshotContents(items []unstructured.Unstructured) map[string]*SnapshotContentInfo {
+	result := map[string]*SnapshotContentInfo{}
+
+	for _, item := range items {
+		volumeHandle, found, _ := unstructured.NestedString(item.Object, "spec", "source", "volumeHandle")
+		if !found || volumeHandle == "" || result[volumeHandle] != nil {
+			continue
+		}
+
+		ready, _, _ := unstructured.NestedBool(item.Object, "status", "readyToUse")
+		if !ready {
+			continue
+		}
+
+		policy, _, _ := unstructured.NestedString(item.Object, "spec", "deletionPolicy")
+		if policy != "Retain" {
+			continue
+		}
+
+		info := &SnapshotContentInfo{
+			Name:           item.GetName(),
+			VolumeHandle:   volumeHandle,
+			IsReady:        true,
+			DeletionPolicy: policy,
+			CreationTime:   item.GetCreationTimestamp(),
+		}
+		info.SnapshotHandle, _, _ = unstructured.NestedString(item.Object, "status", "snapshotHandle")
+
+		result[volumeHandle] = info
+	}
+
+	return result
+}
+
+// findSnapshotContent returns the indexed content taken from the PV's CSI volume, if any
+func findSnapshotContent(contents map[string]*SnapshotContentInfo, pv *corev1.PersistentVolume) *SnapshotContentInfo {
+	if pv == nil || pv.Spec.CSI == nil || pv.Spec.CSI.VolumeHandle == "" {
+		return nil
+	}
+
+	return contents[pv.Spec.CSI.VolumeHandle]
+}
@@ -0,0 +1,258 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newPreProvisionedContent creates a VolumeSnapshotContent as written by backup tooling,
+// without a VolumeSnapshot bound to it
+func newPreProvisionedContent(name, volumeHandle, deletionPolicy string, ready bool) *unstructured.Unstructured {
+	content := newVolumeSnapshotContent(name, volumeHandle)
+	_ = unstructured.SetNestedField(content.Object, deletionPolicy, "spec", "deletionPolicy")
+	_ = unstructured.SetNestedField(content.Object, map[string]interface{}{
+		"readyToUse":     ready,
+		"snapshotHandle": "snap-" + volumeHandle,
+	}, "status")
+	return content
+}
+
+func TestHasReadySnapshotContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		pv          *corev1.PersistentVolume
+		objects     []runtime.Object
+		wantContent string
+	}{
+		{
+			name:        "ready Retain content of the volume",
+			pv:          newCSIPV("vol-1"),
+			objects:     []runtime.Object{newPreProvisionedContent("backup-1", "vol-1", "Retain", true)},
+			wantContent: "backup-1",
+		},
+		{
+			name:    "content not ready",
+			pv:      newCSIPV("vol-1"),
+			objects: []runtime.Object{newPreProvisionedContent("backup-1", "vol-1", "Retain", false)},
+		},
+		{
+			name:    "content with Delete policy",
+			pv:      newCSIPV("vol-1"),
+			objects: []runtime.Object{newPreProvisionedContent("backup-1", "vol-1", "Delete", true)},
+		},
+		{
+			name:    "content of another volume",
+			pv:      newCSIPV("vol-1"),
+			objects: []runtime.Object{newPreProvisionedContent("backup-1", "vol-2", "Retain", true)},
+		},
+		{
+			name:    "non-CSI volume",
+			pv:      newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil),
+			objects: []runtime.Object{newPreProvisionedContent("backup-1", "vol-1", "Retain", true)},
+		},
+		{
+			name: "usable content after unusable ones",
+			pv:   newCSIPV("vol-1"),
+			objects: []runtime.Object{
+				newPreProvisionedContent("a-pending", "vol-1", "Retain", false),
+				newPreProvisionedContent("b-deleted", "vol-1", "Delete", true),
+				newPreProvisionedContent("c-retained", "vol-1", "Retain", true),
+			},
+			wantContent: "c-retained",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc := newFakeSnapshotChecker(tt.objects...)
+
+			found, info, err := sc.HasReadySnapshotContent(context.Background(), tt.pv)
+			if err != nil {
+				t.Fatalf("HasReadySnapshotContent() error = %v", err)
+			}
+
+			if tt.wantContent == "" {
+				if found {
+					t.Fatalf("HasReadySnapshotContent() = true (%s), want false", info.Name)
+				}
+				return
+			}
+
+			if !found || info == nil {
+				t.Fatalf("HasReadySnapshotContent() = false, want %q", tt.wantContent)
+			}
+			if info.Name != tt.wantContent || info.DeletionPolicy != "Retain" || info.SnapshotHandle != "snap-vol-1" {
+				t.Errorf("HasReadySnapshotContent() = %+v, want %q with Retain policy", info, tt.wantContent)
+			}
+		})
+	}
+}
+
+// pagedContentsClient serves VolumeSnapshotContents in pages keyed by continue token and
+// records the options of every list call; the dynamic fake ignores limit and continue
+type pagedContentsClient struct {
+	dynamic.Interface
+	pages map[string]*unstructured.UnstructuredList
+	lists []metav1.ListOptions
+}
+
+func (c *pagedContentsClient) Resource(resource schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	if resource == volumeSnapshotContentGVR {
+		return pagedContents{NamespaceableResourceInterface: c.Interface.Resource(resource), client: c}
+	}
+	return c.Interface.Resource(resource)
+}
+
+type pagedContents struct {
+	dynamic.NamespaceableResourceInterface
+	client *pagedContentsClient
+}
+
+func (p pagedContents) List(_ context.Context, options metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	p.client.lists = append(p.client.lists, options)
+	return p.client.pages[options.Continue], nil
+}
+
+func TestAssessPVCDeletionPagesThroughSnapshotContents(t *testing.T) {
+	// The volume's content is on the second page
+	second := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*newPreProvisionedContent("backup", "vol-1", "Retain", true)}}
+	first := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*newPreProvisionedContent("other", "vol-2", "Retain", true)}}
+	first.SetContinue("page-2")
+
+	tests := []struct {
+		name      string
+		pv        *corev1.PersistentVolume
+		wantLists int
+		wantRisky bool
+	}{
+		{name: "CSI volume", pv: newCSIPV("vol-1"), wantLists: 2},
+		{name: "CSI volume without content", pv: newCSIPV("vol-3"), wantLists: 2, wantRisky: true},
+		{name: "volume without CSI handle", pv: newPhasedPV("pv-data", corev1.PersistentVolumeReclaimDelete, corev1.VolumeBound, nil), wantRisky: true},
+		{name: "Retain volume", pv: newPhasedPV("pv-data", corev1.PersistentVolumeReclaimRetain, corev1.VolumeBound, nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dynamicClient := &pagedContentsClient{
+				Interface: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), CustomResourceListKinds()),
+				pages:     map[string]*unstructured.UnstructuredList{"": first, "page-2": second},
+			}
+			rc := NewRiskCalculator(fake.NewClientset(newNamespacePVC("data", corev1.ClaimBound, "pv-data"), tt.pv),
+				NewSnapshotCheckerForClient(dynamicClient, nil))
+
+			assessment, err := rc.AssessPVCDeletion(context.Background(), "app", "data")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if assessment.IsRisky != tt.wantRisky {
+				t.Errorf("IsRisky = %v, want %v (%s)", assessment.IsRisky, tt.wantRisky, assessment.Message)
+			}
+			if len(dynamicClient.lists) != tt.wantLists {
+				t.Fatalf("listed volumesnapshotcontents %d times, want %d", len(dynamicClient.lists), tt.wantLists)
+			}
+			for _, options := range dynamicClient.lists {
+				if options.Limit != snapshotContentPageSize {
+					t.Errorf("list limit = %d, want %d", options.Limit, snapshotContentPageSize)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotIndexResolvesSnapshotContentsInOnePass(t *testing.T) {
+	created := time.Date(2025, 11, 1, 12, 0, 0, 0, time.UTC)
+	objects := []runtime.Object{
+		newVolumeSnapshotClass("retain", "Retain"),
+		newBoundVolumeSnapshot("a-old", "content-old", created),
+		newBoundVolumeSnapshot("b-new", "content-new", created),
+	}
+	logs := newVolumeSnapshot("c-logs", "logs", "retain", true)
+	_ = unstructured.SetNestedField(logs.Object, "content-logs", "status", "boundVolumeSnapshotContentName")
+	objects = append(objects, logs)
+
+	// The contents of both snapshots of data span two pages
+	first := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+		*newVolumeSnapshotContent("content-old", "vol-0"),
+		*newVolumeSnapshotContent("content-logs", "vol-9"),
+	}}
+	first.SetContinue("page-2")
+	second := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{*newVolumeSnapshotContent("content-new", "vol-1")}}
+
+	tests := []struct {
+		name         string
+		index        func(ctx context.Context, sc *SnapshotChecker) *SnapshotIndex
+		pvc          string
+		pv           *corev1.PersistentVolume
+		wantSnapshot string
+		wantLists    int
+	}{
+		{
+			name:         "namespace",
+			index:        func(ctx context.Context, sc *SnapshotChecker) *SnapshotIndex { return sc.IndexNamespace(ctx, "app") },
+			pvc:          "data",
+			pv:           newCSIPV("vol-1"),
+			wantSnapshot: "b-new",
+			wantLists:    2,
+		},
+		{
+			name: "volume",
+			index: func(ctx context.Context, sc *SnapshotChecker) *SnapshotIndex {
+				return sc.IndexVolume(ctx, newNamespacePVC("data", corev1.ClaimBound, "pv-data"), newCSIPV("vol-1"))
+			},
+			pvc:          "data",
+			pv:           newCSIPV("vol-1"),
+			wantSnapshot: "b-new",
+			wantLists:    2,
+		},
+		{
+			name: "volume whose snapshot content is on the first page",
+			index: func(ctx context.Context, sc *SnapshotChecker) *SnapshotIndex {
+				return sc.IndexVolume(ctx, newNamespacePVC("logs", corev1.ClaimBound, "pv-logs"), nil)
+			},
+			pvc:          "logs",
+			wantSnapshot: "c-logs",
+			wantLists:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), CustomResourceListKinds(), objects...)
+			dynamicClient := &pagedContentsClient{
+				Interface: fakeClient,
+				pages:     map[string]*unstructured.UnstructuredList{"": first, "page-2": second},
+			}
+
+			index := tt.index(context.Background(), NewSnapshotCheckerForClient(dynamicClient, nil))
+
+			info, _ := index.ReadySnapshot(newNamespacePVC(tt.pvc, corev1.ClaimBound, "pv-data"), tt.pv)
+			if info == nil || info.Name != tt.wantSnapshot {
+				t.Fatalf("ReadySnapshot() = %+v, want %s", info, tt.wantSnapshot)
+			}
+			if len(dynamicClient.lists) != tt.wantLists {
+				t.Errorf("listed volumesnapshotcontents %d times, want %d", len(dynamicClient.lists), tt.wantLists)
+			}
+			for _, options := range dynamicClient.lists {
+				if options.Limit != snapshotContentPageSize {
+					t.Errorf("list limit = %d, want %d", options.Limit, snapshotContentPageSize)
+				}
+			}
+			for _, action := range fakeClient.Actions() {
+				if action.GetVerb() == "get" && action.GetResource().Resource == "volumesnapshotcontents" {
+					t.Errorf("unexpected get of a volumesnapshotcontent: %v", action)
+				}
+			}
+		})
+	}
+}
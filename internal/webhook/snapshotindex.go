@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SnapshotIndex holds the snapshot evidence of one namespace or volume, listed once per
+// admission request so that PVCs can be evaluated without further API calls. It is safe
+// for concurrent reads.
+type SnapshotIndex struct {
+	snapshots map[string][]*SnapshotInfo
+	contents  map[string]*SnapshotContentInfo
+	groups    []*indexedGroupSnapshot
+}
+
+// IndexNamespace lists the VolumeSnapshots and VolumeGroupSnapshots of a namespace, and the
+// cluster-scoped VolumeSnapshotContents that may hold its volumes' data without a VolumeSnapshot.
+// The contents are read once, page by page, and also give the source volume of each snapshot.
+// Snapshot APIs that are unavailable simply contribute no evidence.
+func (sc *SnapshotChecker) IndexNamespace(ctx context.Context, namespace string) *SnapshotIndex {
+	index, snapshots := sc.newSnapshotIndex(ctx, namespace)
+
+	pending := map[string]bool{}
+	for _, name := range readySnapshotContentNames(snapshots, "") {
+		pending[name] = true
+	}
+
+	handles := map[string]string{}
+	_ = sc.listSnapshotContents(ctx, func(items []unstructured.Unstructured) bool {
+		for name, handle := range snapshotContentVolumeHandles(items) {
+			if pending[name] {
+				handles[name] = handle
+			}
+		}
+		for handle, info := range indexSnapshotContents(items) {
+			if index.contents[handle] == nil {
+				index.contents[handle] = info
+			}
+		}
+		return true
+	})
+
+	index.snapshots = sc.indexSnapshots(ctx, snapshots, handles)
+	return index
+}
+
+// IndexVolume indexes the snapshot evidence of a single PVC and its PV. Unlike IndexNamespace
+// it does not read every VolumeSnapshotContent of the cluster: contents are only read until the
+// content of the PV's CSI volume and those bound to the PVC's snapshots have been found.
+func (sc *SnapshotChecker) IndexVolume(ctx context.Context, pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) *SnapshotIndex {
+	index, snapshots := sc.newSnapshotIndex(ctx, pvc.Namespace)
+
+	info, handles, _ := sc.lookupSnapshotContents(ctx, pv, readySnapshotContentNames(snapshots, pvc.Name))
+	if info != nil {
+		index.contents[info.VolumeHandle] = info
+	}
+
+	index.snapshots = sc.indexSnapshots(ctx, snapshots, handles)
+	return index
+}
+
+// newSnapshotIndex lists the VolumeSnapshots of a namespace, which the caller indexes once their
+// contents are resolved, and indexes its VolumeGroupSnapshots when enabled
+func (sc *SnapshotChecker) newSnapshotIndex(ctx context.Context, namespace string) (*SnapshotIndex, []unstructured.Unstructured) {
+	index := &SnapshotIndex{
+		snapshots: map[string][]*SnapshotInfo{},
+		contents:  map[string]*SnapshotContentInfo{},
+	}
+
+	var items []unstructured.Unstructured
+	if snapshots, err := sc.dynamicClient.Resource(volumeSnapshotGVR).Namespace(namespace).List(ctx, metav1.ListOptions{}); err == nil {
+		items = snapshots.Items
+	}
+
+	if !sc.groupSnapshotsEnabled {
+		return index, items
+	}
+
+	if groups, err := sc.listGroupSnapshots(ctx, namespace); err == nil {
+		index.groups = groups
+	}
+
+	return index, items
+}
+
+// ReadySnapshot returns the Ready VolumeSnapshot with Retain policy taken from the PVC's
+// current volume, if any, and describes the snapshots of previous volumes it ignored
+func (si *SnapshotIndex) ReadySnapshot(pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) (*SnapshotInfo, []string) {
+	return findSnapshot(si.snapshots[pvc.Name], pvc, pv)
+}
+
+// ReadySnapshotContent returns the Ready, retained VolumeSnapshotContent taken from the PV's
+// CSI volume, if any
+func (si *SnapshotIndex) ReadySnapshotContent(pv *corev1.PersistentVolume) *SnapshotContentInfo {
+	return findSnapshotContent(si.contents, pv)
+}
+
+// ReadyGroupSnapshot returns the Ready, retained VolumeGroupSnapshot covering a PVC, if any
+func (si *SnapshotIndex) ReadyGroupSnapshot(pvc *corev1.PersistentVolumeClaim, pv *corev1.PersistentVolume) *GroupSnapshotInfo {
+	return findGroupSnapshot(si.groups, pvc, pv)
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxMessageExamples limits how many referencing objects are listed in a message
+const maxMessageExamples = 5
+
+// storageReferences groups the objects that still reference a StorageClass or CSIDriver
+type storageReferences struct {
+	boundPVs        []corev1.PersistentVolume
+	unboundPVs      []corev1.PersistentVolume
+	snapshotClasses []string
+}
+
+// AssessStorageClassDeletion checks if deleting a StorageClass would orphan the reclaim of its PVs
+func (rc *RiskCalculator) AssessStorageClassDeletion(ctx context.Context, name string) (*RiskAssessment, error) {
+	refs, err := rc.findPVReferences(ctx, func(pv *corev1.PersistentVolume) bool {
+		return pv.Spec.StorageClassName == name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rc.buildStorageAssessment("StorageClass", "storageclass", name, refs), nil
+}
+
+// AssessCSIDriverDeletion checks if deleting a CSIDriver would break the PVs and
+// VolumeSnapshotClasses that use the driver
+func (rc *RiskCalculator) AssessCSIDriverDeletion(ctx context.Context, name string) (*RiskAssessment, error) {
+	refs, err := rc.findPVReferences(ctx, func(pv *corev1.PersistentVolume) bool {
+		return pv.Spec.CSI != nil && pv.Spec.CSI.Driver == name
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rc.snapshotChecker != nil {
+		classes, err := rc.snapshotChecker.ListSnapshotClassesForDriver(ctx, name)
+		if err == nil {
+			refs.snapshotClasses = classes
+		}
+	}
+
+	return rc.buildStorageAssessment("CSIDriver", "csidriver", name, refs), nil
+}
+
+// findPVReferences lists PVs matching the filter, split by whether they are bound
+func (rc *RiskCalculator) findPVReferences(ctx context.Context, matches func(*corev1.PersistentVolume) bool) (*storageReferences, error) {
+	pvs, err := rc.client.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVs: %w", err)
+	}
+
+	refs := &storageReferences{}
+	for i := range pvs.Items {
+		pv := pvs.Items[i]
+		if !matches(&pv) {
+			continue
+		}
+		if pv.Status.Phase == corev1.VolumeBound {
+			refs.boundPVs = append(refs.boundPVs, pv)
+		} else {
+			refs.unboundPVs = append(refs.unboundPVs, pv)
+		}
+	}
+
+	return refs, nil
+}
+
+// buildStorageAssessment blocks deletion while bound PVs reference the object and
+// warns about any remaining unbound PVs or VolumeSnapshotClasses
+func (rc *RiskCalculator) buildStorageAssessment(kind, resource, name string, refs *storageReferences) *RiskAssessment {
+	assessment := &RiskAssessment{}
+
+	if len(refs.unboundPVs) > 0 {
+		assessment.Warnings = append(assessment.Warnings, fmt.Sprintf("%s '%s' is still referenced by %d unbound PV(s): %s",
+			kind, name, len(refs.unboundPVs), pvExamples(refs.unboundPVs)))
+	}
+	if len(refs.snapshotClasses) > 0 {
+		assessment.Warnings = append(assessment.Warnings, fmt.Sprintf("%s '%s' is still referenced by %d VolumeSnapshotClass(es): %s",
+			kind, name, len(refs.snapshotClasses), nameExamples(refs.snapshotClasses)))
+	}
+
+	if len(refs.boundPVs) == 0 {
+		if len(assessment.Warnings) == 0 {
+			assessment.Message = fmt.Sprintf("%s %s is not referenced by any PV", kind, name)
+		}
+		return assessment
+	}
+
+	assessment.IsRisky = true
+	data := rc.messageData(kind, "", name, nil, assessment)
+	data.BoundPVs = refs.boundPVs
+	data.UnboundPVs = refs.unboundPVs
+	data.SnapshotClasses = refs.snapshotClasses
+	assessment.Message = rc.templates.render(TemplateStorageBlock, data, func() string {
+		return rc.buildStorageBlockMessage(kind, name, refs)
+	})
+	assessment.Suggestion = rc.templates.render(TemplateStorageSuggestion, data, func() string {
+		return rc.buildStorageSuggestions(kind, resource, name)
+	})
+
+	return assessment
+}
+
+// buildStorageBlockMessage creates a user-friendly error message for StorageClass and CSIDriver deletion
+func (rc *RiskCalculator) buildStorageBlockMessage(kind, name string, refs *storageReferences) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("DELETION BLOCKED: %s '%s' is still used by %d bound PV(s)\n\n", kind, name, len(refs.boundPVs)))
+	sb.WriteString("Reason: reclaim and snapshot operations for these volumes depend on it\n")
+
+	sb.WriteString(fmt.Sprintf("Bound PVs (showing %d of %d):\n", min(len(refs.boundPVs), maxMessageExamples), len(refs.boundPVs)))
+	for _, pv := range refs.boundPVs[:min(len(refs.boundPVs), maxMessageExamples)] {
+		if pv.Spec.ClaimRef != nil {
+			sb.WriteString(fmt.Sprintf("  - %s (bound to %s/%s)\n", pv.Name, pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name))
+		} else {
+			sb.WriteString(fmt.Sprintf("  - %s\n", pv.Name))
+		}
+	}
+
+	if len(refs.unboundPVs) > 0 {
+		sb.WriteString(fmt.Sprintf("Unbound PVs: %d (%s)\n", len(refs.unboundPVs), pvExamples(refs.unboundPVs)))
+	}
+	if len(refs.snapshotClasses) > 0 {
+		sb.WriteString(fmt.Sprintf("VolumeSnapshotClasses: %d (%s)\n", len(refs.snapshotClasses), nameExamples(refs.snapshotClasses)))
+	}
+
+	return sb.String()
+}
+
+// buildStorageSuggestions creates actionable suggestions for StorageClass and CSIDriver deletion
+func (rc *RiskCalculator) buildStorageSuggestions(kind, resource, name string) string {
+	return fmt.Sprintf("\nTo safely delete this %s:\n"+
+		"  1. Delete or migrate the PVCs and PVs that use it\n"+
+		"\n  2. OR force delete (reclaim of existing volumes may fail):\n"+
+		"     kubectl label %s %s %s=true\n"+
+		"     kubectl delete %s %s\n"+
+		"\n  3. Then retry the deletion\n", kind, resource, name, rc.bypassLabel, resource, name)
+}
+
+// pvExamples formats up to maxMessageExamples PV names
+func pvExamples(pvs []corev1.PersistentVolume) string {
+	names := make([]string, 0, len(pvs))
+	for _, pv := range pvs {
+		names = append(names, pv.Name)
+	}
+	return nameExamples(names)
+}
+
+// nameExamples formats up to maxMessageExamples names, noting how many were left out
+func nameExamples(names []string) string {
+	if len(names) <= maxMessageExamples {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s, ... and %d more", strings.Join(names[:maxMessageExamples], ", "), len(names)-maxMessageExamples)
+}
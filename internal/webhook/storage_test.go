@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newStoragePV(name, storageClass, driver string, phase corev1.PersistentVolumePhase) *corev1.PersistentVolume {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.PersistentVolumeSpec{StorageClassName: storageClass},
+		Status:     corev1.PersistentVolumeStatus{Phase: phase},
+	}
+	if driver != "" {
+		pv.Spec.CSI = &corev1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: name}
+	}
+	if phase == corev1.VolumeBound {
+		pv.Spec.ClaimRef = &corev1.ObjectReference{Namespace: "app", Name: "claim-" + name}
+	}
+	return pv
+}
+
+func TestAssessStorageClassDeletion(t *testing.T) {
+	tests := []struct {
+		name         string
+		objects      []runtime.Object
+		wantRisky    bool
+		wantWarnings int
+		wantMessage  string
+	}{
+		{
+			name:        "unused storage class",
+			objects:     []runtime.Object{newStoragePV("pv-1", "other", "", corev1.VolumeBound)},
+			wantMessage: "StorageClass standard is not referenced by any PV",
+		},
+		{
+			name:        "bound PV blocks deletion",
+			objects:     []runtime.Object{newStoragePV("pv-1", "standard", "", corev1.VolumeBound)},
+			wantRisky:   true,
+			wantMessage: "  - pv-1 (bound to app/claim-pv-1)\n",
+		},
+		{
+			name:         "released PV only warns",
+			objects:      []runtime.Object{newStoragePV("pv-1", "standard", "", corev1.VolumeReleased)},
+			wantWarnings: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := NewRiskCalculator(fake.NewClientset(tt.objects...), nil)
+
+			assessment, err := rc.AssessStorageClassDeletion(context.Background(), "standard")
+			if err != nil {
+				t.Fatalf("AssessStorageClassDeletion() error = %v", err)
+			}
+			if assessment.IsRisky != tt.wantRisky {
+				t.Errorf("IsRisky = %v, want %v", assessment.IsRisky, tt.wantRisky)
+			}
+			if len(assessment.Warnings) != tt.wantWarnings {
+				t.Errorf("Warnings = %v, want %d", assessment.Warnings, tt.wantWarnings)
+			}
+			if !strings.Contains(assessment.Message, tt.wantMessage) {
+				t.Errorf("Message = %q, want it to contain %q", assessment.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestAssessCSIDriverDeletionListsExamples(t *testing.T) {
+	var objects []runtime.Object
+	for _, name := range []string{"pv-1", "pv-2", "pv-3", "pv-4", "pv-5", "pv-6", "pv-7"} {
+		objects = append(objects, newStoragePV(name, "fast", "ebs.csi.aws.com", corev1.VolumeBound))
+	}
+	rc := NewRiskCalculator(fake.NewClientset(objects...), nil)
+
+	assessment, err := rc.AssessCSIDriverDeletion(context.Background(), "ebs.csi.aws.com")
+	if err != nil {
+		t.Fatalf("AssessCSIDriverDeletion() error = %v", err)
+	}
+	if !assessment.IsRisky {
+		t.Fatal("IsRisky = false, want true")
+	}
+
+	wantHeader := "DELETION BLOCKED: CSIDriver 'ebs.csi.aws.com' is still used by 7 bound PV(s)\n"
+	if !strings.HasPrefix(assessment.Message, wantHeader) {
+		t.Errorf("Message = %q, want prefix %q", assessment.Message, wantHeader)
+	}
+	if !strings.Contains(assessment.Message, "Bound PVs (showing 5 of 7):\n") {
+		t.Errorf("Message = %q, want it to cap examples at 5", assessment.Message)
+	}
+	if !strings.Contains(assessment.Suggestion, "kubectl label csidriver ebs.csi.aws.com pv-safe.io/force-delete=true") {
+		t.Errorf("Suggestion = %q, want force-delete instructions", assessment.Suggestion)
+	}
+}
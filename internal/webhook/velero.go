@@ -0,0 +1,189 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// VeleroProviderName is the name used to enable the Velero backup provider
+	VeleroProviderName = "velero"
+
+	// veleroBackupNameLabel links a PodVolumeBackup to its Backup
+	veleroBackupNameLabel = "velero.io/backup-name"
+
+	// veleroPVCUIDLabel records the UID of the PVC backing a PodVolumeBackup's volume
+	veleroPVCUIDLabel = "velero.io/pvc-uid"
+
+	veleroPhaseCompleted = "Completed"
+)
+
+var (
+	veleroBackupGVR = schema.GroupVersionResource{
+		Group:    "velero.io",
+		Version:  "v1",
+		Resource: "backups",
+	}
+
+	veleroPodVolumeBackupGVR = schema.GroupVersionResource{
+		Group:    "velero.io",
+		Version:  "v1",
+		Resource: "podvolumebackups",
+	}
+)
+
+// VeleroProvider finds Velero file-system backups (PodVolumeBackups) covering a PVC
+type VeleroProvider struct {
+	dynamicClient dynamic.Interface
+	namespace     string
+	maxAge        time.Duration
+	now           func() time.Time
+}
+
+// NewVeleroProvider creates a Velero backup provider reading CRs from the given namespace.
+// A non-zero maxAge rejects backups that completed longer ago than that.
+func NewVeleroProvider(dynamicClient dynamic.Interface, namespace string, maxAge time.Duration) *VeleroProvider {
+	return &VeleroProvider{
+		dynamicClient: dynamicClient,
+		namespace:     namespace,
+		maxAge:        maxAge,
+		now:           time.Now,
+	}
+}
+
+// Name returns the provider name
+func (vp *VeleroProvider) Name() string {
+	return VeleroProviderName
+}
+
+// FindBackup returns the most recent completed, unexpired Velero backup that includes
+// a completed PodVolumeBackup of the PVC's volume
+func (vp *VeleroProvider) FindBackup(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*BackupInfo, error) {
+	if pvc.UID == "" {
+		return nil, nil
+	}
+
+	podVolumeBackups, err := vp.dynamicClient.Resource(veleroPodVolumeBackupGVR).Namespace(vp.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", veleroPVCUIDLabel, pvc.UID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list velero podvolumebackups: %w", err)
+	}
+
+	var latest *BackupInfo
+	checked := map[string]bool{}
+
+	for _, item := range podVolumeBackups.Items {
+		phase, _, _ := unstructured.NestedString(item.Object, "status", "phase")
+		if phase != veleroPhaseCompleted {
+			continue
+		}
+
+		podNamespace, _, _ := unstructured.NestedString(item.Object, "spec", "pod", "namespace")
+		if podNamespace != pvc.Namespace {
+			continue
+		}
+
+		backupName := item.GetLabels()[veleroBackupNameLabel]
+		if backupName == "" || checked[backupName] {
+			continue
+		}
+		checked[backupName] = true
+
+		info, err := vp.validBackup(ctx, backupName, pvc.Namespace)
+		if err != nil || info == nil {
+			continue
+		}
+
+		if latest == nil || info.CompletionTime.After(latest.CompletionTime) {
+			latest = info
+		}
+	}
+
+	return latest, nil
+}
+
+// validBackup returns the named Backup if it completed, has not expired, is fresh enough
+// and includes the given namespace
+func (vp *VeleroProvider) validBackup(ctx context.Context, name, namespace string) (*BackupInfo, error) {
+	backup, err := vp.dynamicClient.Resource(veleroBackupGVR).Namespace(vp.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	phase, _, _ := unstructured.NestedString(backup.Object, "status", "phase")
+	if phase != veleroPhaseCompleted {
+		return nil, nil
+	}
+
+	if !backupIncludesNamespace(backup, namespace) {
+		return nil, nil
+	}
+
+	completionTime, ok := nestedTime(backup, "status", "completionTimestamp")
+	if !ok {
+		return nil, nil
+	}
+
+	now := vp.now()
+	if vp.maxAge > 0 && now.Sub(completionTime) > vp.maxAge {
+		return nil, nil
+	}
+
+	expiration, hasExpiration := nestedTime(backup, "status", "expiration")
+	if hasExpiration && !expiration.After(now) {
+		return nil, nil
+	}
+
+	return &BackupInfo{
+		Provider:       VeleroProviderName,
+		Name:           backup.GetName(),
+		Namespace:      backup.GetNamespace(),
+		CompletionTime: completionTime,
+		Expiration:     expiration,
+	}, nil
+}
+
+// backupIncludesNamespace checks the Backup's included and excluded namespace lists
+func backupIncludesNamespace(backup *unstructured.Unstructured, namespace string) bool {
+	excluded, _, _ := unstructured.NestedStringSlice(backup.Object, "spec", "excludedNamespaces")
+	for _, ns := range excluded {
+		if ns == namespace || ns == "*" {
+			return false
+		}
+	}
+
+	included, _, _ := unstructured.NestedStringSlice(backup.Object, "spec", "includedNamespaces")
+	if len(included) == 0 {
+		return true
+	}
+	for _, ns := range included {
+		if ns == namespace || ns == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nestedTime parses an RFC3339 timestamp field from an unstructured object
+func nestedTime(obj *unstructured.Unstructured, fields ...string) (time.Time, bool) {
+	value, found, err := unstructured.NestedString(obj.Object, fields...)
+	if err != nil || !found || value == "" {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+var veleroTestNow = time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+func newVeleroBackup(name, phase string, completed, expiration time.Time, included []string) *unstructured.Unstructured {
+	spec := map[string]interface{}{}
+	if included != nil {
+		values := make([]interface{}, 0, len(included))
+		for _, ns := range included {
+			values = append(values, ns)
+		}
+		spec["includedNamespaces"] = values
+	}
+
+	status := map[string]interface{}{"phase": phase}
+	if !completed.IsZero() {
+		status["completionTimestamp"] = completed.Format(time.RFC3339)
+	}
+	if !expiration.IsZero() {
+		status["expiration"] = expiration.Format(time.RFC3339)
+	}
+
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "Backup",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "velero"},
+		"spec":       spec,
+		"status":     status,
+	}}
+}
+
+func newPodVolumeBackup(name, backupName, pvcUID, podNamespace, phase string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "PodVolumeBackup",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "velero",
+			"labels": map[string]interface{}{
+				veleroBackupNameLabel: backupName,
+				veleroPVCUIDLabel:     pvcUID,
+			},
+		},
+		"spec": map[string]interface{}{
+			"pod":    map[string]interface{}{"kind": "Pod", "namespace": podNamespace, "name": "app-0"},
+			"volume": "data",
+		},
+		"status": map[string]interface{}{"phase": phase},
+	}}
+}
+
+func newVeleroTestProvider(maxAge time.Duration, objects ...runtime.Object) *VeleroProvider {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			veleroBackupGVR:          "BackupList",
+			veleroPodVolumeBackupGVR: "PodVolumeBackupList",
+		}, objects...)
+
+	provider := NewVeleroProvider(client, "velero", maxAge)
+	provider.now = func() time.Time { return veleroTestNow }
+	return provider
+}
+
+func TestVeleroProviderFindBackup(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "app", UID: types.UID("pvc-uid-1")},
+	}
+
+	hourAgo := veleroTestNow.Add(-time.Hour)
+	dayAgo := veleroTestNow.Add(-24 * time.Hour)
+	nextMonth := veleroTestNow.Add(30 * 24 * time.Hour)
+
+	tests := []struct {
+		name       string
+		maxAge     time.Duration
+		objects    []runtime.Object
+		wantBackup string
+	}{
+		{
+			name: "completed backup covers the PVC",
+			objects: []runtime.Object{
+				newVeleroBackup("nightly", "Completed", hourAgo, nextMonth, []string{"app"}),
+				newPodVolumeBackup("nightly-abc", "nightly", "pvc-uid-1", "app", "Completed"),
+			},
+			wantBackup: "nightly",
+		},
+		{
+			name: "no podvolumebackup for the PVC",
+			objects: []runtime.Object{
+				newVeleroBackup("nightly", "Completed", hourAgo, nextMonth, nil),
+				newPodVolumeBackup("nightly-abc", "nightly", "other-uid", "app", "Completed"),
+			},
+		},
+		{
+			name: "podvolumebackup not completed",
+			objects: []runtime.Object{
+				newVeleroBackup("nightly", "Completed", hourAgo, nextMonth, nil),
+				newPodVolumeBackup("nightly-abc", "nightly", "pvc-uid-1", "app", "InProgress"),
+			},
+		},
+		{
+			name: "backup partially failed",
+			objects: []runtime.Object{
+				newVeleroBackup("nightly", "PartiallyFailed", hourAgo, nextMonth, nil),
+				newPodVolumeBackup("nightly-abc", "nightly", "pvc-uid-1", "app", "Completed"),
+			},
+		},
+		{
+			name: "backup expired",
+			objects: []runtime.Object{
+				newVeleroBackup("nightly", "Completed", dayAgo, veleroTestNow.Add(-time.Minute), nil),
+				newPodVolumeBackup("nightly-abc", "nightly", "pvc-uid-1", "app", "Completed"),
+			},
+		},
+		{
+			name:   "backup older than max age",
+			maxAge: 12 * time.Hour,
+			objects: []runtime.Object{
+				newVeleroBackup("nightly", "Completed", dayAgo, nextMonth, nil),
+				newPodVolumeBackup("nightly-abc", "nightly", "pvc-uid-1", "app", "Completed"),
+			},
+		},
+		{
+			name: "backup excludes the namespace",
+			objects: []runtime.Object{
+				newVeleroBackup("nightly", "Completed", hourAgo, nextMonth, []string{"other"}),
+				newPodVolumeBackup("nightly-abc", "nightly", "pvc-uid-1", "app", "Completed"),
+			},
+		},
+		{
+			name: "most recent valid backup wins",
+			objects: []runtime.Object{
+				newVeleroBackup("older", "Completed", dayAgo, nextMonth, []string{"*"}),
+				newVeleroBackup("newer", "Completed", hourAgo, nextMonth, []string{"*"}),
+				newPodVolumeBackup("older-abc", "older", "pvc-uid-1", "app", "Completed"),
+				newPodVolumeBackup("newer-abc", "newer", "pvc-uid-1", "app", "Completed"),
+			},
+			wantBackup: "newer",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newVeleroTestProvider(tt.maxAge, tt.objects...)
+
+			info, err := provider.FindBackup(context.Background(), pvc)
+			if err != nil {
+				t.Fatalf("FindBackup() error = %v", err)
+			}
+
+			if tt.wantBackup == "" {
+				if info != nil {
+					t.Fatalf("FindBackup() = %q, want no backup", info.Name)
+				}
+				return
+			}
+
+			if info == nil {
+				t.Fatalf("FindBackup() = nil, want %q", tt.wantBackup)
+			}
+			if info.Name != tt.wantBackup {
+				t.Errorf("FindBackup() = %q, want %q", info.Name, tt.wantBackup)
+			}
+			if info.Provider != VeleroProviderName {
+				t.Errorf("Provider = %q, want %q", info.Provider, VeleroProviderName)
+			}
+		})
+	}
+}
+
+func TestNewBackupProviders(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+
+	providers, err := NewBackupProviders([]string{"velero", ""}, client, "velero", 0)
+	if err != nil {
+		t.Fatalf("NewBackupProviders() error = %v", err)
+	}
+	if len(providers) != 1 || providers[0].Name() != VeleroProviderName {
+		t.Fatalf("NewBackupProviders() = %v, want one velero provider", providers)
+	}
+
+	if _, err := NewBackupProviders([]string{"kasten"}, client, "velero", 0); err == nil {
+		t.Fatal("NewBackupProviders() with unknown provider succeeded, want error")
+	}
+}
@@ -0,0 +1,329 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+var (
+	volumeSnapshotGVR        = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshots"}
+	volumeSnapshotClassGVR   = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshotclasses"}
+	volumeSnapshotContentGVR = schema.GroupVersionResource{Group: "snapshot.storage.k8s.io", Version: "v1", Resource: "volumesnapshotcontents"}
+)
+
+// dryRun makes every deletion in these tests go through admission without removing the
+// object, so each scenario can be checked more than once
+var dryRun = metav1.DeleteOptions{DryRun: []string{metav1.DryRunAll}}
+
+func createNamespace(t *testing.T, name string, labels map[string]string) {
+	t.Helper()
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	if _, err := clientset.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("failed to create namespace %s: %v", name, err)
+	}
+}
+
+// createBoundPVC creates a PV and a PVC bound to each other. envtest runs no
+// controllers, so the Bound phase is written through the status subresource.
+func createBoundPVC(t *testing.T, namespace, name string, policy corev1.PersistentVolumeReclaimPolicy, labels map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+	pvName := namespace + "-" + name
+
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: pvName},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			PersistentVolumeReclaimPolicy: policy,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{Driver: "hostpath.csi.k8s.io", VolumeHandle: "handle-" + pvName},
+			},
+			ClaimRef: &corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: namespace, Name: name},
+		},
+	}
+	pv, err := clientset.CoreV1().PersistentVolumes().Create(ctx, pv, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create PV %s: %v", pvName, err)
+	}
+	pv.Status.Phase = corev1.VolumeBound
+	if _, err := clientset.CoreV1().PersistentVolumes().UpdateStatus(ctx, pv, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to bind PV %s: %v", pvName, err)
+	}
+
+	storageClass := ""
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClass,
+			VolumeName:       pvName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+	}
+	pvc, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, pvc, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create PVC %s/%s: %v", namespace, name, err)
+	}
+	pvc.Status.Phase = corev1.ClaimBound
+	if _, err := clientset.CoreV1().PersistentVolumeClaims(namespace).UpdateStatus(ctx, pvc, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to bind PVC %s/%s: %v", namespace, name, err)
+	}
+}
+
+// ensureSnapshotClass creates a VolumeSnapshotClass unless it already exists
+func ensureSnapshotClass(t *testing.T, name, deletionPolicy string) {
+	t.Helper()
+
+	class := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":     "snapshot.storage.k8s.io/v1",
+		"kind":           "VolumeSnapshotClass",
+		"metadata":       map[string]interface{}{"name": name},
+		"driver":         "hostpath.csi.k8s.io",
+		"deletionPolicy": deletionPolicy,
+	}}
+	_, err := dynamicClient.Resource(volumeSnapshotClassGVR).Create(context.Background(), class, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		t.Fatalf("failed to create VolumeSnapshotClass %s: %v", name, err)
+	}
+}
+
+// createSnapshot creates a VolumeSnapshot of a PVC and sets its readiness, standing in
+// for the snapshot controller
+func createSnapshot(t *testing.T, namespace, name, pvcName, className string, ready bool) {
+	t.Helper()
+	ctx := context.Background()
+
+	snapshot := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshot",
+		"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+		"spec": map[string]interface{}{
+			"volumeSnapshotClassName": className,
+			"source":                  map[string]interface{}{"persistentVolumeClaimName": pvcName},
+		},
+	}}
+	created, err := dynamicClient.Resource(volumeSnapshotGVR).Namespace(namespace).Create(ctx, snapshot, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create VolumeSnapshot %s/%s: %v", namespace, name, err)
+	}
+
+	created.Object["status"] = map[string]interface{}{
+		"readyToUse":                     ready,
+		"boundVolumeSnapshotContentName": "snapcontent-" + namespace + "-" + name,
+	}
+	if _, err := dynamicClient.Resource(volumeSnapshotGVR).Namespace(namespace).UpdateStatus(ctx, created, metav1.UpdateOptions{}); err != nil {
+		t.Fatalf("failed to update VolumeSnapshot status %s/%s: %v", namespace, name, err)
+	}
+}
+
+// createSnapshotContent creates the VolumeSnapshotContent a snapshot is bound to, recording
+// the volume handle it was taken from
+func createSnapshotContent(t *testing.T, namespace, snapshotName, volumeHandle string) *unstructured.Unstructured {
+	t.Helper()
+
+	content := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "snapshot.storage.k8s.io/v1",
+		"kind":       "VolumeSnapshotContent",
+		"metadata":   map[string]interface{}{"name": "snapcontent-" + namespace + "-" + snapshotName},
+		"spec": map[string]interface{}{
+			"deletionPolicy":    "Retain",
+			"driver":            "hostpath.csi.k8s.io",
+			"source":            map[string]interface{}{"volumeHandle": volumeHandle},
+			"volumeSnapshotRef": map[string]interface{}{"name": snapshotName, "namespace": namespace},
+		},
+	}}
+	created, err := dynamicClient.Resource(volumeSnapshotContentGVR).Create(context.Background(), content, metav1.CreateOptions{})
+	if err != nil {
+		t.Fatalf("failed to create VolumeSnapshotContent for %s/%s: %v", namespace, snapshotName, err)
+	}
+	return created
+}
+
+// expectBlocked asserts that the webhook denied the deletion with a message containing want
+func expectBlocked(t *testing.T, what string, err error, want ...string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("deleting %s succeeded, want it blocked", what)
+	}
+	if !strings.Contains(err.Error(), `admission webhook "validate.pv-safe.io" denied the request`) {
+		t.Fatalf("deleting %s failed for another reason: %v", what, err)
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("denial for %s does not mention %q:\n%v", what, w, err)
+		}
+	}
+}
+
+// expectCauses checks the status causes of a denial, as field → reason code
+func expectCauses(t *testing.T, what string, err error, want map[string]string) {
+	t.Helper()
+
+	var statusErr *apierrors.StatusError
+	if !errors.As(err, &statusErr) || statusErr.ErrStatus.Details == nil {
+		t.Fatalf("denial for %s has no status details: %v", what, err)
+	}
+
+	got := map[string]string{}
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		got[cause.Field] = string(cause.Type)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("denial causes for %s = %v, want %v", what, got, want)
+	}
+}
+
+func expectAllowed(t *testing.T, what string, err error) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("deleting %s failed, want it allowed: %v", what, err)
+	}
+}
+
+func deletePVC(namespace, name string) error {
+	return clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(context.Background(), name, dryRun)
+}
+
+func deleteNamespace(name string) error {
+	return clientset.CoreV1().Namespaces().Delete(context.Background(), name, dryRun)
+}
+
+func TestRiskyPVCsAreBlocked(t *testing.T) {
+	createNamespace(t, "test-risky", nil)
+	createBoundPVC(t, "test-risky", "database-data", corev1.PersistentVolumeReclaimDelete, nil)
+	createBoundPVC(t, "test-risky", "redis-data", corev1.PersistentVolumeReclaimDelete, nil)
+
+	expectBlocked(t, "PVC database-data", deletePVC("test-risky", "database-data"),
+		"DELETION BLOCKED", "database-data", webhook.BypassLabel)
+	expectBlocked(t, "namespace test-risky", deleteNamespace("test-risky"),
+		"database-data", "redis-data")
+}
+
+func TestSafePVCsAreAllowed(t *testing.T) {
+	createNamespace(t, "test-safe", nil)
+	createBoundPVC(t, "test-safe", "logs-data", corev1.PersistentVolumeReclaimRetain, nil)
+
+	expectAllowed(t, "PVC logs-data", deletePVC("test-safe", "logs-data"))
+	expectAllowed(t, "namespace test-safe", deleteNamespace("test-safe"))
+}
+
+func TestPartialSnapshotsKeepNamespaceBlocked(t *testing.T) {
+	ensureSnapshotClass(t, "csi-hostpath-snapclass", "Retain")
+	createNamespace(t, "test-partial", nil)
+	createBoundPVC(t, "test-partial", "database-data", corev1.PersistentVolumeReclaimDelete, nil)
+	createBoundPVC(t, "test-partial", "redis-data", corev1.PersistentVolumeReclaimDelete, nil)
+
+	createSnapshot(t, "test-partial", "database-data-snapshot", "database-data", "csi-hostpath-snapclass", true)
+
+	expectAllowed(t, "snapshotted PVC database-data", deletePVC("test-partial", "database-data"))
+	err := deleteNamespace("test-partial")
+	expectBlocked(t, "namespace test-partial", err, "redis-data")
+	expectCauses(t, "namespace test-partial", err, map[string]string{"test-partial/redis-data": webhook.ReasonReclaimPolicy})
+
+	createSnapshot(t, "test-partial", "redis-data-snapshot", "redis-data", "csi-hostpath-snapclass", true)
+
+	expectAllowed(t, "fully snapshotted namespace test-partial", deleteNamespace("test-partial"))
+}
+
+func TestUnusableSnapshotsDoNotProtect(t *testing.T) {
+	ensureSnapshotClass(t, "csi-hostpath-snapclass", "Retain")
+	ensureSnapshotClass(t, "csi-hostpath-delete", "Delete")
+	createNamespace(t, "test-unusable-snapshots", nil)
+	createBoundPVC(t, "test-unusable-snapshots", "pending-data", corev1.PersistentVolumeReclaimDelete, nil)
+	createBoundPVC(t, "test-unusable-snapshots", "deleted-with-data", corev1.PersistentVolumeReclaimDelete, nil)
+
+	createSnapshot(t, "test-unusable-snapshots", "pending-snapshot", "pending-data", "csi-hostpath-snapclass", false)
+	createSnapshot(t, "test-unusable-snapshots", "delete-policy-snapshot", "deleted-with-data", "csi-hostpath-delete", true)
+
+	expectBlocked(t, "PVC with a snapshot that is not ready", deletePVC("test-unusable-snapshots", "pending-data"))
+	expectBlocked(t, "PVC with a Delete-policy snapshot", deletePVC("test-unusable-snapshots", "deleted-with-data"))
+}
+
+func TestBypassLabelAllowsDeletion(t *testing.T) {
+	createNamespace(t, "test-bypass", map[string]string{webhook.BypassLabel: "true"})
+	createBoundPVC(t, "test-bypass", "forced-data", corev1.PersistentVolumeReclaimDelete,
+		map[string]string{webhook.BypassLabel: "true"})
+	createBoundPVC(t, "test-bypass", "kept-data", corev1.PersistentVolumeReclaimDelete, nil)
+
+	expectAllowed(t, "labelled PVC forced-data", deletePVC("test-bypass", "forced-data"))
+	expectBlocked(t, "unlabelled PVC kept-data", deletePVC("test-bypass", "kept-data"))
+	expectAllowed(t, "labelled namespace test-bypass", deleteNamespace("test-bypass"))
+}
+
+func TestSnapshotOfPreviousVolumeDoesNotProtect(t *testing.T) {
+	ensureSnapshotClass(t, "csi-hostpath-snapclass", "Retain")
+	createNamespace(t, "test-recreated", nil)
+	createBoundPVC(t, "test-recreated", "app-data", corev1.PersistentVolumeReclaimDelete, nil)
+	createBoundPVC(t, "test-recreated", "logs-data", corev1.PersistentVolumeReclaimDelete, nil)
+
+	// app-data was deleted and recreated; the snapshot still points at the old volume
+	createSnapshotContent(t, "test-recreated", "app-data-snapshot", "handle-previous-volume")
+	createSnapshot(t, "test-recreated", "app-data-snapshot", "app-data", "csi-hostpath-snapclass", true)
+	createSnapshotContent(t, "test-recreated", "logs-data-snapshot", "handle-test-recreated-logs-data")
+	createSnapshot(t, "test-recreated", "logs-data-snapshot", "logs-data", "csi-hostpath-snapclass", true)
+
+	expectBlocked(t, "PVC app-data", deletePVC("test-recreated", "app-data"),
+		"ignored VolumeSnapshot 'app-data-snapshot' of a previous volume")
+	expectAllowed(t, "PVC logs-data", deletePVC("test-recreated", "logs-data"))
+}
+
+func TestPreProvisionedSnapshotContentProtects(t *testing.T) {
+	createNamespace(t, "test-preprovisioned", nil)
+	createBoundPVC(t, "test-preprovisioned", "archive-data", corev1.PersistentVolumeReclaimDelete, nil)
+
+	// Backup tooling writes the content directly; no VolumeSnapshot is ever bound to it
+	content := createSnapshotContent(t, "test-preprovisioned", "external-backup", "handle-test-preprovisioned-archive-data")
+
+	expectBlocked(t, "PVC with a content that is not ready", deletePVC("test-preprovisioned", "archive-data"))
+
+	content.Object["status"] = map[string]interface{}{"readyToUse": true, "snapshotHandle": "backup-archive-data"}
+	_, err := dynamicClient.Resource(volumeSnapshotContentGVR).UpdateStatus(context.Background(), content, metav1.UpdateOptions{})
+	if err != nil {
+		t.Fatalf("failed to update VolumeSnapshotContent status: %v", err)
+	}
+
+	expectAllowed(t, "PVC with a ready retained content", deletePVC("test-preprovisioned", "archive-data"))
+	expectAllowed(t, "namespace test-preprovisioned", deleteNamespace("test-preprovisioned"))
+}
+
+func TestDeletionLockOverridesBypassAndRetain(t *testing.T) {
+	ctx := context.Background()
+	createNamespace(t, "test-locked", nil)
+	createBoundPVC(t, "test-locked", "orders-data", corev1.PersistentVolumeReclaimRetain,
+		map[string]string{webhook.BypassLabel: "true"})
+
+	lock := []byte(`{"metadata":{"annotations":{"pv-safe.io/deletion-lock":"primary orders database","pv-safe.io/deletion-lock-holder":"dba-team"}}}`)
+	if _, err := clientset.CoreV1().PersistentVolumeClaims("test-locked").Patch(ctx, "orders-data", types.MergePatchType, lock, metav1.PatchOptions{}); err != nil {
+		t.Fatalf("adding a deletion lock should be allowed: %v", err)
+	}
+
+	err := deletePVC("test-locked", "orders-data")
+	expectBlocked(t, "locked PVC orders-data", err, "DELETION LOCKED", "dba-team", "primary orders database")
+	expectCauses(t, "locked PVC orders-data", err, map[string]string{"test-locked/orders-data": webhook.ReasonDeletionLocked})
+	expectBlocked(t, "namespace test-locked", deleteNamespace("test-locked"), "DELETION LOCKED")
+
+	unlock := []byte(`{"metadata":{"annotations":{"pv-safe.io/deletion-lock":null}}}`)
+	_, err = clientset.CoreV1().PersistentVolumeClaims("test-locked").Patch(ctx, "orders-data", types.MergePatchType, unlock, metav1.PatchOptions{})
+	expectBlocked(t, "removing the lock without being a lock admin", err, "only deletion lock admins")
+}
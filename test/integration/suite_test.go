@@ -0,0 +1,173 @@
+//go:build integration
+
+// Package integration runs the pv-safe admission flow against a real kube-apiserver
+// and etcd started by controller-runtime envtest. The API server calls the webhook
+// over HTTPS exactly as in a cluster, so the tests cover registration, the
+// AdmissionReview round trip and the messages returned to kubectl.
+//
+// Run with:
+//
+//	KUBEBUILDER_ASSETS=$(setup-envtest use -p path) go test -tags integration ./test/integration/...
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	"github.com/automationpi/pv-safe/internal/webhook"
+)
+
+var (
+	clientset     kubernetes.Interface
+	dynamicClient dynamic.Interface
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		fmt.Println("Skipping integration tests: KUBEBUILDER_ASSETS is not set (see setup-envtest)")
+		os.Exit(0)
+	}
+
+	os.Exit(run(m))
+}
+
+// run starts the API server and the webhook, runs the tests and tears both down
+func run(m *testing.M) int {
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("testdata", "crds")},
+		ErrorIfCRDPathMissing: true,
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			ValidatingWebhooks: []*admissionregistrationv1.ValidatingWebhookConfiguration{webhookConfiguration()},
+		},
+	}
+
+	restConfig, err := testEnv.Start()
+	if err != nil {
+		log.Printf("failed to start envtest: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := testEnv.Stop(); err != nil {
+			log.Printf("failed to stop envtest: %v", err)
+		}
+	}()
+
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		log.Printf("failed to create client: %v", err)
+		return 1
+	}
+	clientset = client
+	dynamicClient, err = dynamic.NewForConfig(restConfig)
+	if err != nil {
+		log.Printf("failed to create dynamic client: %v", err)
+		return 1
+	}
+
+	snapshotChecker := webhook.NewSnapshotCheckerForClient(dynamicClient, client)
+	logger := log.New(io.Discard, "", 0)
+	if testing.Verbose() {
+		logger = log.New(os.Stderr, "[pv-safe-webhook] ", log.Lshortfile)
+	}
+	handler := webhook.NewHandler(logger, client, snapshotChecker)
+
+	options := testEnv.WebhookInstallOptions
+	mux := http.NewServeMux()
+	mux.Handle("/validate", handler)
+	server := &http.Server{
+		Addr:              net.JoinHostPort(options.LocalServingHost, strconv.Itoa(options.LocalServingPort)),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.ListenAndServeTLS(
+			filepath.Join(options.LocalServingCertDir, "tls.crt"),
+			filepath.Join(options.LocalServingCertDir, "tls.key"))
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("webhook server failed: %v", err)
+		}
+	}()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}()
+
+	if err := waitForServer(server.Addr); err != nil {
+		log.Printf("webhook server did not start: %v", err)
+		return 1
+	}
+
+	return m.Run()
+}
+
+// webhookConfiguration mirrors the chart's ValidatingWebhookConfiguration. envtest
+// rewrites the service reference into a URL pointing at the local server.
+func webhookConfiguration() *admissionregistrationv1.ValidatingWebhookConfiguration {
+	path := "validate"
+	failurePolicy := admissionregistrationv1.Fail
+	sideEffects := admissionregistrationv1.SideEffectClassNone
+	matchPolicy := admissionregistrationv1.Exact
+	scope := admissionregistrationv1.AllScopes
+	timeout := int32(10)
+
+	return &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-safe-validating-webhook"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:                    "validate.pv-safe.io",
+			AdmissionReviewVersions: []string{"v1"},
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{Name: "pv-safe-webhook", Namespace: "pv-safe-system", Path: &path},
+			},
+			FailurePolicy: &failurePolicy,
+			MatchPolicy:   &matchPolicy,
+			SideEffects:   &sideEffects,
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Delete, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"namespaces", "persistentvolumeclaims", "persistentvolumes"},
+					Scope:       &scope,
+				},
+			}},
+			MatchConditions: []admissionregistrationv1.MatchCondition{{
+				Name: "deletes-or-locked-updates",
+				Expression: "request.operation != 'UPDATE' || " +
+					"(has(oldObject.metadata.annotations) && 'pv-safe.io/deletion-lock' in oldObject.metadata.annotations)",
+			}},
+			TimeoutSeconds: &timeout,
+		}},
+	}
+}
+
+// waitForServer waits until the webhook accepts TCP connections
+func waitForServer(addr string) error {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			return conn.Close()
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}